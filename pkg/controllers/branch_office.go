@@ -1,38 +1,120 @@
 package controllers
 
 import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jangkartech/twin-branch-office/pkg/dto"
+	"github.com/jangkartech/twin-branch-office/pkg/exports"
+	"github.com/jangkartech/twin-branch-office/pkg/models"
 	"github.com/jangkartech/twin-branch-office/pkg/services"
 	"github.com/jangkartech/twin-branch-office/pkg/validators"
 	utilDTO "github.com/jangkartech/twin-util/pkg/dto"
+	utilErrors "github.com/jangkartech/twin-util/pkg/errors"
+	"github.com/jangkartech/twin-util/pkg/logger"
 	"github.com/jangkartech/twin-util/pkg/util"
 )
 
 type BranchOfficeControllerInterface interface {
 	GetBranchOffices(ctx *gin.Context)
+	ExportBranchOffices(ctx *gin.Context)
 	ShowBranchOffice(ctx *gin.Context)
 	CreateBranchOffice(ctx *gin.Context)
+	BulkCreateBranchOffices(ctx *gin.Context)
+	ImportBranchOffices(ctx *gin.Context)
 	UpdateBranchOffice(ctx *gin.Context)
+	PatchBranchOffice(ctx *gin.Context)
 	SoftDeleteBranchOffice(ctx *gin.Context)
 	RestoreBranchOffice(ctx *gin.Context)
+	TransitionBranchOffice(ctx *gin.Context)
+	UpdateBranchOfficeHours(ctx *gin.Context)
+	GetBranchOfficeAvailability(ctx *gin.Context)
+	UpdateBranchOfficeLocation(ctx *gin.Context)
+	GetNearbyBranchOffices(ctx *gin.Context)
+	UpdateBranchOfficeServiceArea(ctx *gin.Context)
+	ResolveBranchOffice(ctx *gin.Context)
 	HardDeleteBranchOffice(ctx *gin.Context)
+	BulkSoftDeleteBranchOffices(ctx *gin.Context)
+	BulkRestoreBranchOffices(ctx *gin.Context)
+	BulkHardDeleteBranchOffices(ctx *gin.Context)
+	MigrateBranchOfficeCityCodes(ctx *gin.Context)
+	MigrateBranchOfficeCodes(ctx *gin.Context)
+	MigrateBranchOfficeAddresses(ctx *gin.Context)
 
 	GetSimpleBranchOffices(ctx *gin.Context)
+	GetBranchOfficeHistory(ctx *gin.Context)
 }
 
 type branchOfficeController struct {
-	branchOfficeService services.BranchOfficeServiceInterface
+	branchOfficeService      services.BranchOfficeServiceInterface
+	branchOfficeAuditService services.BranchOfficeAuditServiceInterface
 }
 
-func NewBranchOfficeController(branchOfficeService services.BranchOfficeServiceInterface) BranchOfficeControllerInterface {
+func NewBranchOfficeController(branchOfficeService services.BranchOfficeServiceInterface, branchOfficeAuditService services.BranchOfficeAuditServiceInterface) BranchOfficeControllerInterface {
 	return &branchOfficeController{
-		branchOfficeService: branchOfficeService,
+		branchOfficeService:      branchOfficeService,
+		branchOfficeAuditService: branchOfficeAuditService,
 	}
 }
 
+// handleBranchOfficeError maps service errors to their HTTP status.
+func handleBranchOfficeError(ctx *gin.Context, err error) {
+	var conflictErr *services.BranchOfficeConflictError
+	var validationErr *utilErrors.DBValidationError
+	switch {
+	case errors.As(err, &conflictErr):
+		ctx.AbortWithStatusJSON(http.StatusConflict, dto.BranchOfficeConflictResponse{
+			Error:    conflictErr.Error(),
+			Conflict: conflictErr.BranchOffice.ToDtoResponse(),
+		})
+	case errors.As(err, &validationErr):
+		util.HandleErrorResponse(ctx, http.StatusUnprocessableEntity, validationErr)
+	case errors.Is(err, services.ErrBranchOfficeInvalidTransition):
+		util.HandleErrorResponse(ctx, http.StatusConflict, err)
+	case errors.Is(err, services.ErrBranchOfficeNotFound):
+		util.HandleErrorResponse(ctx, http.StatusNotFound, err)
+	case errors.Is(err, services.ErrBranchOfficeVersionMismatch):
+		util.HandleErrorResponse(ctx, http.StatusPreconditionFailed, err)
+	default:
+		util.HandleErrorResponse(ctx, http.StatusInternalServerError, err)
+	}
+}
+
+// setETag exposes the branch office version as a strong entity tag.
+func setETag(ctx *gin.Context, version int64) {
+	ctx.Header("ETag", strconv.Quote(strconv.FormatInt(version, 10)))
+}
+
+// ifMatchVersion returns the version the If-Match header requires, or nil when the
+// header is absent or "*". If-Match compares tags strongly (RFC 9110, 13.1.1), so a
+// weak tag never matches, and neither does a tag that is not one of ours.
+func ifMatchVersion(ctx *gin.Context) (*int64, error) {
+	header := strings.TrimSpace(ctx.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return nil, nil
+	}
+	if strings.HasPrefix(header, "W/") {
+		return nil, services.ErrBranchOfficeVersionMismatch
+	}
+	tag, err := strconv.Unquote(header)
+	if err != nil {
+		return nil, services.ErrBranchOfficeVersionMismatch
+	}
+	version, err := strconv.ParseInt(tag, 10, 64)
+	if err != nil {
+		return nil, services.ErrBranchOfficeVersionMismatch
+	}
+	return &version, nil
+}
+
 // GetBranchOffices godoc
 // @Summary       Retrieve a list of branch offices
 // @Description   Fetches a filtered list of branch offices and returns the results in JSON format.
@@ -40,6 +122,7 @@ func NewBranchOfficeController(branchOfficeService services.BranchOfficeServiceI
 // @Produce       json
 // @Param         branch_office body dto.GetBranchOfficeRequest true "JSON payload for branch office filtering"
 // @Success       200 {object} dto.GetBranchOfficeResponse
+// @Success       200 {object} dto.GetSparseBranchOfficeResponse "When select limits the returned fields"
 // @Failure       500 {object} dto.InternalServerErrorResponse
 // @Failure       422 {object} dto.UnprocessableEntityResponse{error=dto.GetBranchOfficeValidationResponse}
 // @Failure       400 {object} dto.BadRequestResponse{error=dto.GetBranchOfficeValidationResponse}
@@ -51,6 +134,11 @@ func (c *branchOfficeController) GetBranchOffices(ctx *gin.Context) {
 		return
 	}
 
+	if req.Pagination != nil && *req.Pagination == services.BranchOfficePaginationCursor {
+		c.getBranchOfficesByCursor(ctx, req)
+		return
+	}
+
 	totalRows, totalPages, err := c.branchOfficeService.GetTotalRowsAndPages(ctx, *req)
 	if err != nil {
 		util.HandleErrorResponse(ctx, http.StatusInternalServerError, err)
@@ -63,24 +151,123 @@ func (c *branchOfficeController) GetBranchOffices(ctx *gin.Context) {
 		return
 	}
 
+	respondBranchOffices(ctx, req, data, &dto.BranchOfficeMeta{
+		Pagination: &utilDTO.Pagination{
+			Limit:      *req.Limit,
+			Page:       *req.Page,
+			TotalRows:  totalRows,
+			TotalPages: totalPages,
+		},
+		Sort: *req.Sort,
+	})
+	return
+}
+
+// respondBranchOffices writes a branch office list, limited to the fields of the select
+// parameter when the client sent one.
+func respondBranchOffices(ctx *gin.Context, req *dto.GetBranchOfficeRequest, data []*models.BranchOffice, meta *dto.BranchOfficeMeta) {
+	if req.Select != nil && len(*req.Select) > 0 {
+		var responseData []dto.SparseBranchOfficeResource
+		for _, item := range data {
+			responseData = append(responseData, item.ToDtoResponse().Select(*req.Select))
+		}
+
+		ctx.JSON(http.StatusOK, dto.GetSparseBranchOfficeResponse{
+			Data:    responseData,
+			Meta:    meta,
+			Message: util.ResponseMessage(http.StatusOK),
+		})
+		return
+	}
+
 	var responseData []*dto.BranchOfficeResource
 	for _, item := range data {
 		responseData = append(responseData, item.ToDtoResponse())
 	}
 
 	ctx.JSON(http.StatusOK, dto.GetBranchOfficeResponse{
-		Data: responseData,
-		Meta: &dto.BranchOfficeMeta{
-			Pagination: &utilDTO.Pagination{
-				Limit:      *req.Limit,
-				Page:       *req.Page,
-				TotalRows:  totalRows,
-				TotalPages: totalPages,
-			},
-		},
+		Data:    responseData,
+		Meta:    meta,
 		Message: util.ResponseMessage(http.StatusOK),
 	})
-	return
+}
+
+// getBranchOfficesByCursor serves GET /branch-offices in keyset pagination mode. The
+// total is only counted when the client asks for it.
+func (c *branchOfficeController) getBranchOfficesByCursor(ctx *gin.Context, req *dto.GetBranchOfficeRequest) {
+	var totalRows *int64
+	if req.WithTotal != nil && *req.WithTotal {
+		total, _, err := c.branchOfficeService.GetTotalRowsAndPages(ctx, *req)
+		if err != nil {
+			util.HandleErrorResponse(ctx, http.StatusInternalServerError, err)
+			return
+		}
+		totalRows = &total
+	}
+
+	data, nextCursor, prevCursor, err := c.branchOfficeService.GetBranchOfficeListByCursor(ctx, *req)
+	if err != nil {
+		util.HandleErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	respondBranchOffices(ctx, req, data, &dto.BranchOfficeMeta{
+		Sort:       *req.Sort,
+		NextCursor: nextCursor,
+		PrevCursor: prevCursor,
+		TotalRows:  totalRows,
+	})
+}
+
+// ExportBranchOffices godoc
+// @Summary       Export the list of branch offices
+// @Description   Streams every branch office matching the filters of GET /branch-offices, without paging, as a CSV, XLSX or JSON Lines download.
+// @Tags          Branch Offices
+// @Produce       text/csv,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet,application/x-ndjson
+// @Param         format  query  string  false  "csv, xlsx or jsonl"
+// @Param         branch_office query dto.GetBranchOfficeRequest true "Filters of the exported branch offices"
+// @Success       200 {file} file
+// @Failure       500 {object} dto.InternalServerErrorResponse
+// @Failure       422 {object} dto.UnprocessableEntityResponse{error=dto.ExportBranchOfficeValidationResponse}
+// @Failure       400 {object} dto.BadRequestResponse{error=dto.ExportBranchOfficeValidationResponse}
+// @Router        /branch-offices/export [get]
+func (c *branchOfficeController) ExportBranchOffices(ctx *gin.Context) {
+	req, err := validators.ValidateExportBranchOfficeRequest(ctx)
+	if err != nil {
+		util.HandleErrorResponse(ctx, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	columns := services.BranchOfficeSelectColumns
+	if req.Select != nil && len(*req.Select) > 0 {
+		columns = *req.Select
+	}
+	format := *req.Format
+	filename := fmt.Sprintf("branch-offices-%s.%s", time.Now().UTC().Format("20060102-150405"), format)
+	ctx.Header("Content-Type", exports.ContentTypes[format])
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	ctx.Status(http.StatusOK)
+
+	writer, err := exports.NewBranchOfficeWriter(ctx.Writer, format, columns)
+	if err == nil {
+		err = c.branchOfficeService.ExportBranchOffices(ctx, req.GetBranchOfficeRequest, func(branchOffice *models.BranchOffice) error {
+			return writer.Write(branchOffice.ToDtoResponse())
+		})
+		if err == nil {
+			err = writer.Close()
+		}
+	}
+	if err != nil {
+		if !ctx.Writer.Written() {
+			ctx.Writer.Header().Del("Content-Type")
+			ctx.Writer.Header().Del("Content-Disposition")
+			util.HandleErrorResponse(ctx, http.StatusInternalServerError, err)
+			return
+		}
+		// Once rows went out the status can no longer change.
+		logger.Log.Error(err.Error())
+		ctx.Abort()
+	}
 }
 
 // ShowBranchOffice godoc
@@ -90,6 +277,7 @@ func (c *branchOfficeController) GetBranchOffices(ctx *gin.Context) {
 // @Produce       json
 // @Param         id  path  string  true "Unique identifier for the branch office"
 // @Success       200 {object} dto.ShowBranchOfficeResponse
+// @Header        200 {string} ETag "Current version of the branch office"
 // @Failure       500 {object} dto.InternalServerErrorResponse
 // @Failure       404 {object} dto.NotFoundResponse
 // @Router        /branch-office/{id} [get]
@@ -109,6 +297,7 @@ func (c *branchOfficeController) ShowBranchOffice(ctx *gin.Context) {
 		return
 	}
 
+	setETag(ctx, data.Version)
 	ctx.JSON(http.StatusOK, dto.ShowBranchOfficeResponse{
 		Data:    data.ToDtoResponse(),
 		Message: util.ResponseMessage(http.StatusOK),
@@ -125,6 +314,7 @@ func (c *branchOfficeController) ShowBranchOffice(ctx *gin.Context) {
 // @Success       201 {object} dto.CreateBranchOfficeResponse
 // @Failure       500 {object} dto.InternalServerErrorResponse
 // @Failure       422 {object} dto.UnprocessableEntityResponse{error=dto.CreateBranchOfficeValidationResponse}
+// @Failure       409 {object} dto.BranchOfficeConflictResponse
 // @Failure       400 {object} dto.BadRequestResponse{error=dto.CreateBranchOfficeValidationResponse}
 // @Router        /branch-office [post]
 func (c *branchOfficeController) CreateBranchOffice(ctx *gin.Context) {
@@ -135,7 +325,7 @@ func (c *branchOfficeController) CreateBranchOffice(ctx *gin.Context) {
 	}
 	data, err := c.branchOfficeService.CreateBranchOffice(ctx, *req)
 	if err != nil {
-		util.HandleErrorResponse(ctx, http.StatusInternalServerError, err)
+		handleBranchOfficeError(ctx, err)
 		return
 	}
 
@@ -146,18 +336,197 @@ func (c *branchOfficeController) CreateBranchOffice(ctx *gin.Context) {
 	return
 }
 
+// Statuses of the items of a bulk create.
+const (
+	bulkItemCreated = "created"
+	bulkItemFailed  = "failed"
+	bulkItemSkipped = "skipped"
+)
+
+// BulkCreateBranchOffices godoc
+// @Summary       Create several branch offices at once
+// @Description   Creates up to 500 branch offices, all or nothing unless in partial mode, and reports the outcome of every item by its index in the request.
+// @Tags          Branch Offices
+// @Produce       json
+// @Param         mode  query  string  false  "atomic or partial"
+// @Param         branch_offices  body  []dto.CreateBranchOfficeRequest  true  "JSON array of branch offices to create"
+// @Success       201 {object} dto.BulkCreateBranchOfficeResponse
+// @Success       207 {object} dto.BulkCreateBranchOfficeResponse
+// @Failure       500 {object} dto.InternalServerErrorResponse
+// @Failure       422 {object} dto.BulkCreateBranchOfficeResponse
+// @Failure       400 {object} dto.BadRequestResponse{error=dto.BulkCreateBranchOfficeValidationResponse}
+// @Router        /branch-offices/bulk [post]
+func (c *branchOfficeController) BulkCreateBranchOffices(ctx *gin.Context) {
+	req, itemErrors, err := validators.ValidateBulkCreateBranchOfficeRequest(ctx, c.branchOfficeService)
+	if err != nil {
+		util.HandleErrorResponse(ctx, http.StatusUnprocessableEntity, err)
+		return
+	}
+	atomic := *req.Mode == services.BulkModeAtomic
+
+	results := make([]*dto.BulkCreateBranchOfficeItemResult, len(req.Items))
+	var valid []dto.CreateBranchOfficeRequest
+	var validIndexes []int
+	for i, item := range req.Items {
+		results[i] = &dto.BulkCreateBranchOfficeItemResult{
+			Index:  i,
+			Id:     item.Id,
+			Status: bulkItemSkipped,
+		}
+		if itemErrors[i] != nil {
+			results[i].Status = bulkItemFailed
+			results[i].Error = itemErrors[i]
+			continue
+		}
+		valid = append(valid, item)
+		validIndexes = append(validIndexes, i)
+	}
+
+	if len(valid) > 0 && (!atomic || len(valid) == len(req.Items)) {
+		created, err := c.branchOfficeService.BulkCreateBranchOffice(ctx, valid, atomic)
+		if err != nil {
+			handleBranchOfficeError(ctx, err)
+			return
+		}
+		for i, item := range created {
+			result := results[validIndexes[i]]
+			if item.BranchOffice != nil {
+				result.Status = bulkItemCreated
+				result.Id = item.BranchOffice.Id
+				result.Data = item.BranchOffice.ToDtoResponse()
+			} else if item.Err != nil {
+				result.Status = bulkItemFailed
+				result.Message = item.Err.Error()
+			}
+		}
+	}
+
+	meta := &dto.BulkCreateBranchOfficeMeta{
+		Mode:  *req.Mode,
+		Total: len(results),
+	}
+	for _, result := range results {
+		switch result.Status {
+		case bulkItemCreated:
+			meta.Created++
+		case bulkItemFailed:
+			meta.Failed++
+		default:
+			meta.Skipped++
+		}
+	}
+
+	status := http.StatusCreated
+	if meta.Created == 0 {
+		status = http.StatusUnprocessableEntity
+	} else if meta.Created < meta.Total {
+		status = http.StatusMultiStatus
+	}
+	ctx.JSON(status, dto.BulkCreateBranchOfficeResponse{
+		Data:    results,
+		Meta:    meta,
+		Message: util.ResponseMessage(status),
+	})
+}
+
+// ImportBranchOffices godoc
+// @Summary       Import branch offices from a spreadsheet
+// @Description   Creates and updates branch offices from the rows of a CSV or XLSX file, all or nothing, and returns the errors of the invalid rows by row and column.
+// @Tags          Branch Offices
+// @Accept        multipart/form-data
+// @Produce       json,text/csv
+// @Param         file  formData  file  true  "CSV or XLSX file"
+// @Param         dry_run  formData  boolean  false  "Validate without importing"
+// @Param         report  formData  string  false  "Format of the error report, json or csv"
+// @Success       200 {object} dto.ImportBranchOfficeResponse
+// @Failure       500 {object} dto.InternalServerErrorResponse
+// @Failure       422 {object} dto.ImportBranchOfficeResponse
+// @Failure       400 {object} dto.BadRequestResponse{error=dto.ImportBranchOfficeValidationResponse}
+// @Router        /branch-offices/import [post]
+func (c *branchOfficeController) ImportBranchOffices(ctx *gin.Context) {
+	req, items, rowErrors, err := validators.ValidateImportBranchOfficeRequest(ctx, c.branchOfficeService)
+	if err != nil {
+		util.HandleErrorResponse(ctx, http.StatusUnprocessableEntity, err)
+		return
+	}
+	dryRun := req.DryRun != nil && *req.DryRun
+
+	if len(rowErrors) > 0 {
+		if req.Report != nil && *req.Report == "csv" {
+			writeImportErrorReport(ctx, rowErrors)
+			return
+		}
+		ctx.JSON(http.StatusUnprocessableEntity, dto.ImportBranchOfficeResponse{
+			Data: &dto.ImportBranchOfficeResource{
+				DryRun: dryRun,
+				Total:  len(items) + countImportErrorRows(rowErrors),
+				Errors: rowErrors,
+			},
+			Message: util.ResponseMessage(http.StatusUnprocessableEntity),
+		})
+		return
+	}
+
+	res, err := c.branchOfficeService.ImportBranchOffices(ctx, items, dryRun)
+	if err != nil {
+		handleBranchOfficeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.ImportBranchOfficeResponse{
+		Data: &dto.ImportBranchOfficeResource{
+			DryRun:    dryRun,
+			Total:     len(items),
+			Created:   res.Created,
+			Updated:   res.Updated,
+			Unchanged: res.Unchanged,
+			Errors:    []*dto.ImportBranchOfficeError{},
+		},
+		Message: util.ResponseMessage(http.StatusOK),
+	})
+}
+
+// countImportErrorRows counts the distinct rows of rowErrors, which are grouped by row.
+func countImportErrorRows(rowErrors []*dto.ImportBranchOfficeError) int {
+	var res, last int
+	for _, rowError := range rowErrors {
+		if rowError.Row != last {
+			res++
+			last = rowError.Row
+		}
+	}
+	return res
+}
+
+// writeImportErrorReport sends the errors of an import as a CSV attachment.
+func writeImportErrorReport(ctx *gin.Context, rowErrors []*dto.ImportBranchOfficeError) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	_ = writer.Write([]string{"row", "column", "reason"})
+	for _, rowError := range rowErrors {
+		_ = writer.Write([]string{strconv.Itoa(rowError.Row), rowError.Column, rowError.Reason})
+	}
+	writer.Flush()
+
+	ctx.Header("Content-Disposition", `attachment; filename="branch-office-import-errors.csv"`)
+	ctx.Data(http.StatusUnprocessableEntity, "text/csv; charset=utf-8", buf.Bytes())
+}
+
 // UpdateBranchOffice godoc
-// @Summary       Update information of a specific branch office by ID
-// @Description   Updates the information of a specific branch office based on the provided data and returns the updated branch office details in JSON format.
+// @Summary       Replace information of a specific branch office by ID
+// @Description   Replaces every editable field of a specific branch office with the provided data and returns the updated branch office details in JSON format.
 // @Tags          Branch Offices
 // @Produce       json
 // @Param         id  path  string  true  "ID of the branch office to be updated"
+// @Param         If-Match  header  string  false  "ETag of the branch office version being updated"
 // @Param         branch_office  body  dto.UpdateBranchOfficeRequest  true  "JSON object containing updated branch office data"
 // @Success       200 {object} dto.UpdateBranchOfficeResponse
+// @Header        200 {string} ETag "Current version of the branch office"
 // @Failure       500 {object} dto.InternalServerErrorResponse
 // @Failure       422 {object} dto.UnprocessableEntityResponse{error=dto.UpdateBranchOfficeValidationResponse}
 // @Failure       400 {object} dto.BadRequestResponse{error=dto.UpdateBranchOfficeValidationResponse}
 // @Failure       404 {object} dto.NotFoundResponse
+// @Failure       412 {object} dto.PreconditionFailedResponse
 // @Router        /branch-office/{id} [put]
 func (c *branchOfficeController) UpdateBranchOffice(ctx *gin.Context) {
 	id := ctx.Param("id")
@@ -176,12 +545,79 @@ func (c *branchOfficeController) UpdateBranchOffice(ctx *gin.Context) {
 		return
 	}
 
-	data, err := c.branchOfficeService.UpdateBranchOfficeById(ctx, id, *req)
+	version, err := ifMatchVersion(ctx)
+	if err != nil {
+		handleBranchOfficeError(ctx, err)
+		return
+	}
+
+	data, err := c.branchOfficeService.UpdateBranchOfficeById(ctx, id, *req, version)
+	if err != nil {
+		handleBranchOfficeError(ctx, err)
+		return
+	}
+
+	setETag(ctx, data.Version)
+	ctx.JSON(http.StatusOK, dto.UpdateBranchOfficeResponse{
+		Data:    data.ToDtoResponse(),
+		Message: util.ResponseMessage(http.StatusOK),
+	})
+	return
+}
+
+// PatchBranchOffice godoc
+// @Summary       Partially update a specific branch office by ID
+// @Description   Applies a JSON merge patch (RFC 7396) to a specific branch office, where null clears an optional field, and returns the updated branch office details in JSON format.
+// @Tags          Branch Offices
+// @Accept        application/merge-patch+json
+// @Produce       json
+// @Param         id  path  string  true  "ID of the branch office to be updated"
+// @Param         If-Match  header  string  false  "ETag of the branch office version being updated"
+// @Param         branch_office  body  dto.PatchBranchOfficeRequest  true  "JSON merge patch of the branch office"
+// @Success       200 {object} dto.UpdateBranchOfficeResponse
+// @Header        200 {string} ETag "Current version of the branch office"
+// @Failure       500 {object} dto.InternalServerErrorResponse
+// @Failure       422 {object} dto.UnprocessableEntityResponse{error=dto.PatchBranchOfficeValidationResponse}
+// @Failure       404 {object} dto.NotFoundResponse
+// @Failure       412 {object} dto.PreconditionFailedResponse
+// @Router        /branch-office/{id} [patch]
+func (c *branchOfficeController) PatchBranchOffice(ctx *gin.Context) {
+	// The route restored branch offices before it took merge patches, and still does for
+	// clients that send anything but a merge patch.
+	if ctx.ContentType() != validators.MergePatchContentType {
+		c.RestoreBranchOffice(ctx)
+		return
+	}
+
+	id := ctx.Param("id")
+	branchExists, err := c.branchOfficeService.ExistsBranchOfficeById(ctx, id, false)
 	if err != nil {
 		util.HandleErrorResponse(ctx, http.StatusInternalServerError, err)
 		return
+	} else if !branchExists {
+		util.HandleErrorResponse(ctx, http.StatusNotFound, err)
+		return
+	}
+
+	req, err := validators.ValidatePatchBranchOfficeRequest(ctx, c.branchOfficeService, id)
+	if err != nil {
+		util.HandleErrorResponse(ctx, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	version, err := ifMatchVersion(ctx)
+	if err != nil {
+		handleBranchOfficeError(ctx, err)
+		return
 	}
 
+	data, err := c.branchOfficeService.PatchBranchOfficeById(ctx, id, *req, version)
+	if err != nil {
+		handleBranchOfficeError(ctx, err)
+		return
+	}
+
+	setETag(ctx, data.Version)
 	ctx.JSON(http.StatusOK, dto.UpdateBranchOfficeResponse{
 		Data:    data.ToDtoResponse(),
 		Message: util.ResponseMessage(http.StatusOK),
@@ -195,23 +631,22 @@ func (c *branchOfficeController) UpdateBranchOffice(ctx *gin.Context) {
 // @Tags          Branch Offices
 // @Produce       json
 // @Param         id  path  string  true  "ID of the branch office to be soft deleted"
+// @Param         If-Match  header  string  false  "ETag of the branch office version being deleted"
 // @Success       200 {object} dto.DeleteBranchOfficeResponse
 // @Failure       500 {object} dto.InternalServerErrorResponse
 // @Failure       404 {object} dto.NotFoundResponse
+// @Failure       412 {object} dto.PreconditionFailedResponse
 // @Router        /branch-office/{id} [delete]
 func (c *branchOfficeController) SoftDeleteBranchOffice(ctx *gin.Context) {
-	branchExists, err := c.branchOfficeService.ExistsBranchOfficeById(ctx, ctx.Param("id"), false)
+	version, err := ifMatchVersion(ctx)
 	if err != nil {
-		util.HandleErrorResponse(ctx, http.StatusInternalServerError, err)
-		return
-	} else if !branchExists {
-		util.HandleErrorResponse(ctx, http.StatusNotFound, err)
+		handleBranchOfficeError(ctx, err)
 		return
 	}
 
-	err = c.branchOfficeService.SoftDeleteBranchOfficeById(ctx, ctx.Param("id"))
+	err = c.branchOfficeService.SoftDeleteBranchOfficeById(ctx, ctx.Param("id"), version)
 	if err != nil {
-		util.HandleErrorResponse(ctx, http.StatusInternalServerError, err)
+		handleBranchOfficeError(ctx, err)
 		return
 	}
 
@@ -227,23 +662,22 @@ func (c *branchOfficeController) SoftDeleteBranchOffice(ctx *gin.Context) {
 // @Tags          Branch Offices
 // @Produce       json
 // @Param         id  path  string  true "ID of the branch office to be permanently deleted"
+// @Param         If-Match  header  string  false "ETag of the branch office version being deleted"
 // @Success       200 {object} dto.HardDeleteBranchOfficeResponse
 // @Failure       500 {object} dto.InternalServerErrorResponse
 // @Failure       404 {object} dto.NotFoundResponse
+// @Failure       412 {object} dto.PreconditionFailedResponse
 // @Router        /branch-office/hard-delete/{id} [delete]
 func (c *branchOfficeController) HardDeleteBranchOffice(ctx *gin.Context) {
-	branchExists, err := c.branchOfficeService.ExistsBranchOfficeById(ctx, ctx.Param("id"), true)
+	version, err := ifMatchVersion(ctx)
 	if err != nil {
-		util.HandleErrorResponse(ctx, http.StatusInternalServerError, err)
-		return
-	} else if !branchExists {
-		util.HandleErrorResponse(ctx, http.StatusNotFound, err)
+		handleBranchOfficeError(ctx, err)
 		return
 	}
 
-	err = c.branchOfficeService.HardDeleteBranchOfficeById(ctx, ctx.Param("id"))
+	err = c.branchOfficeService.HardDeleteBranchOfficeById(ctx, ctx.Param("id"), version)
 	if err != nil {
-		util.HandleErrorResponse(ctx, http.StatusInternalServerError, err)
+		handleBranchOfficeError(ctx, err)
 		return
 	}
 
@@ -255,36 +689,533 @@ func (c *branchOfficeController) HardDeleteBranchOffice(ctx *gin.Context) {
 
 // RestoreBranchOffice godoc
 // @Summary       Restore a branch office by ID
-// @Description   Restores a previously soft-deleted branch office based on the provided ID and returns a confirmation message in JSON format.
+// @Description   Restores a previously soft-deleted branch office based on the provided ID and returns the restored branch office in JSON format.
 // @Tags          Branch Offices
+// @Accept        json
 // @Produce       json
 // @Param         id  path  string  true "ID of the branch office to be restored"
+// @Param         If-Match  header  string  false "ETag of the branch office version being restored"
+// @Param         branch_office  body  dto.RestoreBranchOfficeRequest  false  "New name of the restored branch office"
 // @Success       200 {object} dto.RestoreBranchOfficeResponse
+// @Header        200 {string} ETag "Current version of the branch office"
 // @Failure       500 {object} dto.InternalServerErrorResponse
+// @Failure       422 {object} dto.UnprocessableEntityResponse{error=dto.RestoreBranchOfficeValidationResponse}
+// @Failure       409 {object} dto.BranchOfficeConflictResponse
 // @Failure       404 {object} dto.NotFoundResponse
-// @Router        /branch-office/{id} [patch]
+// @Failure       412 {object} dto.PreconditionFailedResponse
+// @Router        /branch-office/restore/{id} [patch]
 func (c *branchOfficeController) RestoreBranchOffice(ctx *gin.Context) {
-	branchExists, err := c.branchOfficeService.ExistsBranchOfficeById(ctx, ctx.Param("id"), true)
+	req, err := validators.ValidateRestoreBranchOfficeRequest(ctx)
 	if err != nil {
-		util.HandleErrorResponse(ctx, http.StatusInternalServerError, err)
+		util.HandleErrorResponse(ctx, http.StatusUnprocessableEntity, err)
 		return
-	} else if !branchExists {
-		util.HandleErrorResponse(ctx, http.StatusNotFound, err)
+	}
+
+	version, err := ifMatchVersion(ctx)
+	if err != nil {
+		handleBranchOfficeError(ctx, err)
 		return
 	}
 
-	err = c.branchOfficeService.RestoreBranchOfficeById(ctx, ctx.Param("id"))
+	data, err := c.branchOfficeService.RestoreBranchOfficeById(ctx, ctx.Param("id"), *req, version)
 	if err != nil {
-		util.HandleErrorResponse(ctx, http.StatusInternalServerError, err)
+		handleBranchOfficeError(ctx, err)
 		return
 	}
 
+	setETag(ctx, data.Version)
 	ctx.JSON(http.StatusOK, dto.RestoreBranchOfficeResponse{
+		Data:    data.ToDtoResponse(),
 		Message: util.ResponseMessage(http.StatusOK),
 	})
 	return
 }
 
+// TransitionBranchOffice godoc
+// @Summary       Change the lifecycle status of a branch office
+// @Description   Moves a branch office to another lifecycle status, recording the reason and the time the change took effect in its history.
+// @Tags          Branch Offices
+// @Accept        json
+// @Produce       json
+// @Param         id  path  string  true "ID of the branch office to transition"
+// @Param         If-Match  header  string  false "ETag of the branch office version being transitioned"
+// @Param         transition  body  dto.TransitionBranchOfficeRequest  true  "Target status, reason and effective time"
+// @Success       200 {object} dto.TransitionBranchOfficeResponse
+// @Header        200 {string} ETag "Current version of the branch office"
+// @Failure       500 {object} dto.InternalServerErrorResponse
+// @Failure       422 {object} dto.UnprocessableEntityResponse{error=dto.TransitionBranchOfficeValidationResponse}
+// @Failure       409 {object} dto.ConflictResponse
+// @Failure       404 {object} dto.NotFoundResponse
+// @Failure       412 {object} dto.PreconditionFailedResponse
+// @Router        /branch-office/{id}/transitions [post]
+func (c *branchOfficeController) TransitionBranchOffice(ctx *gin.Context) {
+	req, err := validators.ValidateTransitionBranchOfficeRequest(ctx)
+	if err != nil {
+		util.HandleErrorResponse(ctx, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	version, err := ifMatchVersion(ctx)
+	if err != nil {
+		handleBranchOfficeError(ctx, err)
+		return
+	}
+
+	data, err := c.branchOfficeService.TransitionBranchOfficeById(ctx, ctx.Param("id"), *req, version)
+	if err != nil {
+		handleBranchOfficeError(ctx, err)
+		return
+	}
+
+	setETag(ctx, data.Version)
+	ctx.JSON(http.StatusOK, dto.TransitionBranchOfficeResponse{
+		Data:    data.ToDtoResponse(),
+		Message: util.ResponseMessage(http.StatusOK),
+	})
+	return
+}
+
+// UpdateBranchOfficeHours godoc
+// @Summary       Replace the opening hours of a branch office
+// @Description   Replaces the timezone, weekly opening hours and dated exceptions of a branch office.
+// @Tags          Branch Offices
+// @Accept        json
+// @Produce       json
+// @Param         id  path  string  true "ID of the branch office"
+// @Param         If-Match  header  string  false "ETag of the branch office version being updated"
+// @Param         hours  body  dto.UpdateBranchOfficeHoursRequest  true  "Timezone, opening hours and exceptions"
+// @Success       200 {object} dto.UpdateBranchOfficeResponse
+// @Header        200 {string} ETag "Current version of the branch office"
+// @Failure       500 {object} dto.InternalServerErrorResponse
+// @Failure       422 {object} dto.UnprocessableEntityResponse{error=dto.UpdateBranchOfficeHoursValidationResponse}
+// @Failure       404 {object} dto.NotFoundResponse
+// @Failure       412 {object} dto.PreconditionFailedResponse
+// @Router        /branch-office/{id}/opening-hours [put]
+func (c *branchOfficeController) UpdateBranchOfficeHours(ctx *gin.Context) {
+	req, err := validators.ValidateUpdateBranchOfficeHoursRequest(ctx)
+	if err != nil {
+		util.HandleErrorResponse(ctx, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	version, err := ifMatchVersion(ctx)
+	if err != nil {
+		handleBranchOfficeError(ctx, err)
+		return
+	}
+
+	data, err := c.branchOfficeService.UpdateBranchOfficeHoursById(ctx, ctx.Param("id"), *req, version)
+	if err != nil {
+		handleBranchOfficeError(ctx, err)
+		return
+	}
+
+	setETag(ctx, data.Version)
+	ctx.JSON(http.StatusOK, dto.UpdateBranchOfficeResponse{
+		Data:    data.ToDtoResponse(),
+		Message: util.ResponseMessage(http.StatusOK),
+	})
+	return
+}
+
+// GetBranchOfficeAvailability godoc
+// @Summary       Tell whether a branch office is open
+// @Description   Works out whether a branch office is open at a unix time, now by default, from its opening hours and exceptions in its own timezone.
+// @Tags          Branch Offices
+// @Produce       json
+// @Param         id  path  string  true "ID of the branch office"
+// @Param         at  query  int  false "Unix time to check, now by default"
+// @Success       200 {object} dto.GetBranchOfficeAvailabilityResponse
+// @Failure       500 {object} dto.InternalServerErrorResponse
+// @Failure       422 {object} dto.UnprocessableEntityResponse{error=dto.GetBranchOfficeAvailabilityValidationResponse}
+// @Failure       404 {object} dto.NotFoundResponse
+// @Router        /branch-office/{id}/availability [get]
+func (c *branchOfficeController) GetBranchOfficeAvailability(ctx *gin.Context) {
+	req, err := validators.ValidateGetBranchOfficeAvailabilityRequest(ctx)
+	if err != nil {
+		util.HandleErrorResponse(ctx, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	data, err := c.branchOfficeService.GetBranchOfficeAvailability(ctx, ctx.Param("id"), time.Unix(*req.At, 0))
+	if err != nil {
+		handleBranchOfficeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.GetBranchOfficeAvailabilityResponse{
+		Data:    availabilityToDto(data),
+		Message: util.ResponseMessage(http.StatusOK),
+	})
+	return
+}
+
+func availabilityToDto(m *services.BranchOfficeAvailability) *dto.BranchOfficeAvailabilityResource {
+	res := &dto.BranchOfficeAvailabilityResource{
+		Id:              m.BranchOffice.Id,
+		Open:            m.Open,
+		At:              m.At.Unix(),
+		LocalTime:       m.At.Format(time.RFC3339),
+		Timezone:        m.At.Location().String(),
+		LifecycleStatus: m.BranchOffice.LifecycleStatus,
+		Hours:           models.HoursIntervalsToDto(m.Hours),
+	}
+	if m.Exception != nil {
+		res.Exception = m.Exception.ToDtoResponse()
+	}
+	if m.ClosesAt != nil {
+		closesAt := m.ClosesAt.Unix()
+		res.ClosesAt = &closesAt
+	}
+	if m.NextOpensAt != nil {
+		nextOpensAt := m.NextOpensAt.Unix()
+		res.NextOpensAt = &nextOpensAt
+	}
+	return res
+}
+
+// UpdateBranchOfficeLocation godoc
+// @Summary       Set the coordinates of a branch office
+// @Description   Sets or clears the latitude and longitude of a branch office in decimal degrees, which geocoding never replaces.
+// @Tags          Branch Offices
+// @Accept        json
+// @Produce       json
+// @Param         id  path  string  true "ID of the branch office"
+// @Param         If-Match  header  string  false "ETag of the branch office version being updated"
+// @Param         location  body  dto.UpdateBranchOfficeLocationRequest  true  "Latitude and longitude"
+// @Success       200 {object} dto.UpdateBranchOfficeResponse
+// @Header        200 {string} ETag "Current version of the branch office"
+// @Failure       500 {object} dto.InternalServerErrorResponse
+// @Failure       422 {object} dto.UnprocessableEntityResponse{error=dto.UpdateBranchOfficeLocationValidationResponse}
+// @Failure       404 {object} dto.NotFoundResponse
+// @Failure       412 {object} dto.PreconditionFailedResponse
+// @Router        /branch-office/{id}/location [put]
+func (c *branchOfficeController) UpdateBranchOfficeLocation(ctx *gin.Context) {
+	req, err := validators.ValidateUpdateBranchOfficeLocationRequest(ctx)
+	if err != nil {
+		util.HandleErrorResponse(ctx, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	version, err := ifMatchVersion(ctx)
+	if err != nil {
+		handleBranchOfficeError(ctx, err)
+		return
+	}
+
+	data, err := c.branchOfficeService.UpdateBranchOfficeLocationById(ctx, ctx.Param("id"), *req, version)
+	if err != nil {
+		handleBranchOfficeError(ctx, err)
+		return
+	}
+
+	setETag(ctx, data.Version)
+	ctx.JSON(http.StatusOK, dto.UpdateBranchOfficeResponse{
+		Data:    data.ToDtoResponse(),
+		Message: util.ResponseMessage(http.StatusOK),
+	})
+	return
+}
+
+// GetNearbyBranchOffices godoc
+// @Summary       Find the branch offices nearest to a point
+// @Description   Lists the branch offices nearest to a latitude and longitude by great-circle distance, nearest first, each with its distance_km.
+// @Tags          Branch Offices
+// @Produce       json
+// @Param         branch_office query dto.GetNearbyBranchOfficeRequest true "Point, radius and filters of the search"
+// @Success       200 {object} dto.GetNearbyBranchOfficeResponse
+// @Failure       500 {object} dto.InternalServerErrorResponse
+// @Failure       422 {object} dto.UnprocessableEntityResponse{error=dto.GetNearbyBranchOfficeValidationResponse}
+// @Router        /branch-offices/nearby [get]
+func (c *branchOfficeController) GetNearbyBranchOffices(ctx *gin.Context) {
+	req, err := validators.ValidateGetNearbyBranchOfficeRequest(ctx)
+	if err != nil {
+		util.HandleErrorResponse(ctx, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	data, err := c.branchOfficeService.GetNearbyBranchOfficeList(ctx, *req)
+	if err != nil {
+		util.HandleErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	responseData := make([]*dto.BranchOfficeResource, 0, len(data))
+	for _, item := range data {
+		resource := item.ToDtoResponse()
+		resource.DistanceKm = &item.DistanceKm
+		responseData = append(responseData, resource)
+	}
+
+	ctx.JSON(http.StatusOK, dto.GetNearbyBranchOfficeResponse{
+		Data:    responseData,
+		Message: util.ResponseMessage(http.StatusOK),
+	})
+	return
+}
+
+// UpdateBranchOfficeServiceArea godoc
+// @Summary       Replace the service area of a branch office
+// @Description   Replaces the GeoJSON service area of a branch office and the postal codes it serves.
+// @Tags          Branch Offices
+// @Accept        json
+// @Produce       json
+// @Param         id  path  string  true "ID of the branch office"
+// @Param         If-Match  header  string  false "ETag of the branch office version being updated"
+// @Param         service_area  body  dto.UpdateBranchOfficeServiceAreaRequest  true  "Service area and postal codes"
+// @Success       200 {object} dto.UpdateBranchOfficeResponse
+// @Header        200 {string} ETag "Current version of the branch office"
+// @Failure       500 {object} dto.InternalServerErrorResponse
+// @Failure       422 {object} dto.UnprocessableEntityResponse{error=dto.UpdateBranchOfficeServiceAreaValidationResponse}
+// @Failure       409 {object} dto.BranchOfficeConflictResponse
+// @Failure       404 {object} dto.NotFoundResponse
+// @Failure       412 {object} dto.PreconditionFailedResponse
+// @Router        /branch-office/{id}/service-area [put]
+func (c *branchOfficeController) UpdateBranchOfficeServiceArea(ctx *gin.Context) {
+	req, err := validators.ValidateUpdateBranchOfficeServiceAreaRequest(ctx)
+	if err != nil {
+		util.HandleErrorResponse(ctx, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	version, err := ifMatchVersion(ctx)
+	if err != nil {
+		handleBranchOfficeError(ctx, err)
+		return
+	}
+
+	data, err := c.branchOfficeService.UpdateBranchOfficeServiceAreaById(ctx, ctx.Param("id"), *req, version)
+	if err != nil {
+		handleBranchOfficeError(ctx, err)
+		return
+	}
+
+	setETag(ctx, data.Version)
+	ctx.JSON(http.StatusOK, dto.UpdateBranchOfficeResponse{
+		Data:    data.ToDtoResponse(),
+		Message: util.ResponseMessage(http.StatusOK),
+	})
+	return
+}
+
+// ResolveBranchOffice godoc
+// @Summary       Find the branch office responsible for a place
+// @Description   Returns the active branch office whose service area holds the point given by lat and lng, or which serves postal_code.
+// @Tags          Branch Offices
+// @Produce       json
+// @Param         branch_office query dto.ResolveBranchOfficeRequest true "Point or postal code to resolve"
+// @Success       200 {object} dto.ResolveBranchOfficeResponse
+// @Failure       500 {object} dto.InternalServerErrorResponse
+// @Failure       422 {object} dto.UnprocessableEntityResponse{error=dto.ResolveBranchOfficeValidationResponse}
+// @Failure       404 {object} dto.NotFoundResponse
+// @Router        /branch-offices/resolve [get]
+func (c *branchOfficeController) ResolveBranchOffice(ctx *gin.Context) {
+	req, err := validators.ValidateResolveBranchOfficeRequest(ctx)
+	if err != nil {
+		util.HandleErrorResponse(ctx, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	data, err := c.branchOfficeService.ResolveBranchOffice(ctx, *req)
+	if err != nil {
+		handleBranchOfficeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.ResolveBranchOfficeResponse{
+		Data:    data.ToDtoResponse(),
+		Message: util.ResponseMessage(http.StatusOK),
+	})
+	return
+}
+
+// BulkSoftDeleteBranchOffices godoc
+// @Summary       Soft delete several branch offices at once
+// @Description   Soft deletes, in one transaction, the live branch offices given by ids or matching filter and returns how many were deleted.
+// @Tags          Branch Offices
+// @Produce       json
+// @Param         branch_offices  body  dto.BulkBranchOfficeRequest  true  "Ids or filter of the branch offices to soft delete"
+// @Success       200 {object} dto.BulkBranchOfficeResponse
+// @Failure       500 {object} dto.InternalServerErrorResponse
+// @Failure       422 {object} dto.UnprocessableEntityResponse{error=dto.BulkBranchOfficeValidationResponse}
+// @Failure       400 {object} dto.BadRequestResponse{error=dto.BulkBranchOfficeValidationResponse}
+// @Router        /branch-offices/bulk-delete [post]
+func (c *branchOfficeController) BulkSoftDeleteBranchOffices(ctx *gin.Context) {
+	c.bulkBranchOffices(ctx, false, c.branchOfficeService.BulkSoftDeleteBranchOffice)
+}
+
+// BulkRestoreBranchOffices godoc
+// @Summary       Restore several branch offices at once
+// @Description   Restores, in one transaction, the soft-deleted branch offices given by ids or matching filter and returns how many were restored.
+// @Tags          Branch Offices
+// @Produce       json
+// @Param         branch_offices  body  dto.BulkBranchOfficeRequest  true  "Ids or filter of the branch offices to restore"
+// @Success       200 {object} dto.BulkBranchOfficeResponse
+// @Failure       500 {object} dto.InternalServerErrorResponse
+// @Failure       409 {object} dto.BranchOfficeConflictResponse
+// @Failure       422 {object} dto.UnprocessableEntityResponse{error=dto.BulkBranchOfficeValidationResponse}
+// @Failure       400 {object} dto.BadRequestResponse{error=dto.BulkBranchOfficeValidationResponse}
+// @Router        /branch-offices/bulk-restore [post]
+func (c *branchOfficeController) BulkRestoreBranchOffices(ctx *gin.Context) {
+	c.bulkBranchOffices(ctx, false, c.branchOfficeService.BulkRestoreBranchOffice)
+}
+
+// BulkHardDeleteBranchOffices godoc
+// @Summary       Permanently delete several branch offices at once
+// @Description   Permanently deletes, in one transaction, the branch offices given by ids or matching filter and returns how many were deleted.
+// @Tags          Branch Offices
+// @Produce       json
+// @Param         branch_offices  body  dto.BulkBranchOfficeRequest  true  "Ids or filter of the branch offices to permanently delete"
+// @Success       200 {object} dto.BulkBranchOfficeResponse
+// @Failure       500 {object} dto.InternalServerErrorResponse
+// @Failure       422 {object} dto.UnprocessableEntityResponse{error=dto.BulkBranchOfficeValidationResponse}
+// @Failure       400 {object} dto.BadRequestResponse{error=dto.BulkBranchOfficeValidationResponse}
+// @Router        /branch-offices/bulk-hard-delete [post]
+func (c *branchOfficeController) BulkHardDeleteBranchOffices(ctx *gin.Context) {
+	c.bulkBranchOffices(ctx, true, c.branchOfficeService.BulkHardDeleteBranchOffice)
+}
+
+// bulkBranchOffices validates a bulk write request, whose filter may hold a status when
+// withStatus, and responds with the outcome of write.
+func (c *branchOfficeController) bulkBranchOffices(ctx *gin.Context, withStatus bool, write func(ctx context.Context, req dto.BulkBranchOfficeRequest) (*services.BulkBranchOfficeResult, error)) {
+	req, err := validators.ValidateBulkBranchOfficeRequest(ctx, withStatus)
+	if err != nil {
+		util.HandleErrorResponse(ctx, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	res, err := write(ctx, *req)
+	if err != nil {
+		handleBranchOfficeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.BulkBranchOfficeResponse{
+		Data: &dto.BulkBranchOfficeResource{
+			Affected:    res.Affected,
+			NotFoundIds: res.NotFoundIds,
+		},
+		Message: util.ResponseMessage(http.StatusOK),
+	})
+}
+
+// MigrateBranchOfficeCityCodes godoc
+// @Summary       Map the free-text cities of branch offices to city codes
+// @Description   Gives every live branch office without a city_code the code of the regency or city its city names and reports the cities that match no region or several.
+// @Tags          Branch Offices
+// @Produce       json
+// @Param         dry_run  query  boolean  false  "Report without writing"
+// @Success       200 {object} dto.MigrateBranchOfficeCityCodesResponse
+// @Failure       500 {object} dto.InternalServerErrorResponse
+// @Failure       422 {object} dto.UnprocessableEntityResponse{error=dto.MigrateBranchOfficeCityCodesValidationResponse}
+// @Router        /branch-offices/city-codes/migrate [post]
+func (c *branchOfficeController) MigrateBranchOfficeCityCodes(ctx *gin.Context) {
+	req, err := validators.ValidateMigrateBranchOfficeCityCodesRequest(ctx)
+	if err != nil {
+		util.HandleErrorResponse(ctx, http.StatusUnprocessableEntity, err)
+		return
+	}
+	dryRun := req.DryRun != nil && *req.DryRun
+
+	res, err := c.branchOfficeService.MigrateBranchOfficeCityCodes(ctx, dryRun)
+	if err != nil {
+		handleBranchOfficeError(ctx, err)
+		return
+	}
+
+	unmapped := []*dto.UnmappedBranchOfficeCityResource{}
+	for _, city := range res.Unmapped {
+		candidates := []*dto.RegionResource{}
+		for _, region := range city.Candidates {
+			candidates = append(candidates, region.ToDtoResponse())
+		}
+		unmapped = append(unmapped, &dto.UnmappedBranchOfficeCityResource{
+			City:          city.City,
+			BranchOffices: city.BranchOffices,
+			Reason:        city.Reason,
+			Candidates:    candidates,
+		})
+	}
+
+	ctx.JSON(http.StatusOK, dto.MigrateBranchOfficeCityCodesResponse{
+		Data: &dto.MigrateBranchOfficeCityCodesResource{
+			DryRun:   dryRun,
+			Total:    res.Total,
+			Mapped:   res.Mapped,
+			Unmapped: unmapped,
+		},
+		Message: util.ResponseMessage(http.StatusOK),
+	})
+}
+
+// MigrateBranchOfficeCodes godoc
+// @Summary       Give branch offices stored before codes existed a branch code
+// @Description   Gives every live branch office without a code the next code of its city, as a create would.
+// @Tags          Branch Offices
+// @Produce       json
+// @Param         dry_run  query  boolean  false  "Count without writing"
+// @Success       200 {object} dto.MigrateBranchOfficeCodesResponse
+// @Failure       500 {object} dto.InternalServerErrorResponse
+// @Failure       422 {object} dto.UnprocessableEntityResponse{error=dto.MigrateBranchOfficeCodesValidationResponse}
+// @Router        /branch-offices/codes/migrate [post]
+func (c *branchOfficeController) MigrateBranchOfficeCodes(ctx *gin.Context) {
+	req, err := validators.ValidateMigrateBranchOfficeCodesRequest(ctx)
+	if err != nil {
+		util.HandleErrorResponse(ctx, http.StatusUnprocessableEntity, err)
+		return
+	}
+	dryRun := req.DryRun != nil && *req.DryRun
+
+	res, err := c.branchOfficeService.MigrateBranchOfficeCodes(ctx, dryRun)
+	if err != nil {
+		handleBranchOfficeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.MigrateBranchOfficeCodesResponse{
+		Data: &dto.MigrateBranchOfficeCodesResource{
+			DryRun:   dryRun,
+			Total:    res.Total,
+			Assigned: res.Assigned,
+		},
+		Message: util.ResponseMessage(http.StatusOK),
+	})
+}
+
+// MigrateBranchOfficeAddresses godoc
+// @Summary       Structure the flat addresses of branch offices stored before address_detail existed
+// @Description   Parses the flat address of every live branch office without a street into address_detail, keeping the flat address as it was written.
+// @Tags          Branch Offices
+// @Produce       json
+// @Param         dry_run  query  boolean  false  "Count without writing"
+// @Success       200 {object} dto.MigrateBranchOfficeAddressesResponse
+// @Failure       500 {object} dto.InternalServerErrorResponse
+// @Failure       422 {object} dto.UnprocessableEntityResponse{error=dto.MigrateBranchOfficeAddressesValidationResponse}
+// @Router        /branch-offices/addresses/migrate [post]
+func (c *branchOfficeController) MigrateBranchOfficeAddresses(ctx *gin.Context) {
+	req, err := validators.ValidateMigrateBranchOfficeAddressesRequest(ctx)
+	if err != nil {
+		util.HandleErrorResponse(ctx, http.StatusUnprocessableEntity, err)
+		return
+	}
+	dryRun := req.DryRun != nil && *req.DryRun
+
+	res, err := c.branchOfficeService.MigrateBranchOfficeAddresses(ctx, dryRun)
+	if err != nil {
+		handleBranchOfficeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.MigrateBranchOfficeAddressesResponse{
+		Data: &dto.MigrateBranchOfficeAddressesResource{
+			DryRun:     dryRun,
+			Total:      res.Total,
+			Structured: res.Structured,
+		},
+		Message: util.ResponseMessage(http.StatusOK),
+	})
+}
+
 // GetSimpleBranchOffices	godoc
 // @Summary       			Retrieve a list of simple branch offices
 // @Description   			Fetches a filtered list of simple branch offices and returns the results in JSON format.
@@ -320,3 +1251,67 @@ func (c *branchOfficeController) GetSimpleBranchOffices(ctx *gin.Context) {
 	})
 	return
 }
+
+// GetBranchOfficeHistory godoc
+// @Summary       Retrieve the change history of a branch office
+// @Description   Pages through the audit trail of a branch office, newest change first, including who made each change and the before and after value of every changed field.
+// @Tags          Branch Offices
+// @Produce       json
+// @Param         id  path  string  true "Unique identifier for the branch office"
+// @Param         branch_office query dto.GetBranchOfficeHistoryRequest true "Pagination of the history"
+// @Success       200 {object} dto.GetBranchOfficeHistoryResponse
+// @Failure       500 {object} dto.InternalServerErrorResponse
+// @Failure       422 {object} dto.UnprocessableEntityResponse{error=dto.GetBranchOfficeHistoryValidationResponse}
+// @Failure       404 {object} dto.NotFoundResponse
+// @Router        /branch-office/{id}/history [get]
+func (c *branchOfficeController) GetBranchOfficeHistory(ctx *gin.Context) {
+	id := ctx.Param("id")
+	req, err := validators.ValidateGetBranchOfficeHistoryRequest(ctx)
+	if err != nil {
+		util.HandleErrorResponse(ctx, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	totalRows, totalPages, err := c.branchOfficeAuditService.GetTotalRowsAndPages(ctx, id, *req)
+	if err != nil {
+		util.HandleErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	// The history outlives hard deletes, so only an id that never existed is not found.
+	if totalRows == 0 {
+		branchExists, err := c.branchOfficeService.ExistsBranchOfficeById(ctx, id, true)
+		if err != nil {
+			util.HandleErrorResponse(ctx, http.StatusInternalServerError, err)
+			return
+		} else if !branchExists {
+			util.HandleErrorResponse(ctx, http.StatusNotFound, err)
+			return
+		}
+	}
+
+	data, err := c.branchOfficeAuditService.GetBranchOfficeAuditList(ctx, id, *req)
+	if err != nil {
+		util.HandleErrorResponse(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	var responseData []*dto.BranchOfficeAuditResource
+	for _, item := range data {
+		responseData = append(responseData, item.ToDtoResponse())
+	}
+
+	ctx.JSON(http.StatusOK, dto.GetBranchOfficeHistoryResponse{
+		Data: responseData,
+		Meta: &dto.BranchOfficeMeta{
+			Pagination: &utilDTO.Pagination{
+				Limit:      *req.Limit,
+				Page:       *req.Page,
+				TotalRows:  totalRows,
+				TotalPages: totalPages,
+			},
+		},
+		Message: util.ResponseMessage(http.StatusOK),
+	})
+	return
+}
@@ -0,0 +1,417 @@
+package controllers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jangkartech/twin-branch-office/pkg/controllers"
+	"github.com/jangkartech/twin-branch-office/pkg/imports"
+	"github.com/jangkartech/twin-branch-office/pkg/models"
+	"github.com/jangkartech/twin-branch-office/pkg/repos"
+	"github.com/jangkartech/twin-branch-office/pkg/services"
+)
+
+// testServer serves the branch office routes over a memory store.
+type testServer struct {
+	engine *gin.Engine
+	repo   repos.BranchOfficeRepoInterface
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	store := repos.NewMemoryStore()
+	branchOfficeRepo := repos.NewMemoryBranchOfficeRepo(store)
+	unitOfWork := services.NewUnitOfWork(repos.NewMemoryTransactionRepo(store))
+	branchOfficeAuditService := services.NewBranchOfficeAuditService(repos.NewMemoryBranchOfficeAuditRepo(store))
+	branchOfficeService := services.NewBranchOfficeService(branchOfficeRepo, branchOfficeAuditService, unitOfWork, services.MustParseBranchCodePattern(services.DefaultBranchCodePattern), nil)
+	branchOfficeController := controllers.NewBranchOfficeController(branchOfficeService, branchOfficeAuditService)
+
+	engine := gin.New()
+	engine.Use(func(ctx *gin.Context) {
+		ctx.Set(services.ActorContextKey, "tester")
+		ctx.Next()
+	})
+	engine.GET("/branch-offices", branchOfficeController.GetBranchOffices)
+	engine.POST("/branch-office", branchOfficeController.CreateBranchOffice)
+	engine.POST("/branch-offices/import", branchOfficeController.ImportBranchOffices)
+	engine.GET("/branch-office/:id", branchOfficeController.ShowBranchOffice)
+	engine.PUT("/branch-office/:id", branchOfficeController.UpdateBranchOffice)
+	engine.PATCH("/branch-office/:id", branchOfficeController.PatchBranchOffice)
+	engine.DELETE("/branch-office/:id", branchOfficeController.SoftDeleteBranchOffice)
+	engine.PATCH("/branch-office/restore/:id", branchOfficeController.RestoreBranchOffice)
+	engine.POST("/branch-offices/bulk-delete", branchOfficeController.BulkSoftDeleteBranchOffices)
+	engine.POST("/branch-offices/bulk-restore", branchOfficeController.BulkRestoreBranchOffices)
+	engine.POST("/branch-offices/bulk-hard-delete", branchOfficeController.BulkHardDeleteBranchOffices)
+	engine.POST("/branch-offices/codes/migrate", branchOfficeController.MigrateBranchOfficeCodes)
+	engine.PUT("/branch-office/:id/location", branchOfficeController.UpdateBranchOfficeLocation)
+	engine.POST("/branch-offices/addresses/migrate", branchOfficeController.MigrateBranchOfficeAddresses)
+	return &testServer{engine: engine, repo: branchOfficeRepo}
+}
+
+// request serves a request with a JSON body, given as a string or a value to encode,
+// and headers given as name and value pairs.
+func (s *testServer) request(t *testing.T, method string, path string, body interface{}, headers ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf bytes.Buffer
+	switch body := body.(type) {
+	case nil:
+	case string:
+		buf.WriteString(body)
+	default:
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatalf("encode %v: %v", body, err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	res := httptest.NewRecorder()
+	s.engine.ServeHTTP(res, req)
+	return res
+}
+
+// create creates a branch office from body and returns its id.
+func (s *testServer) create(t *testing.T, body map[string]interface{}) string {
+	t.Helper()
+	res := s.request(t, http.MethodPost, "/branch-office", body)
+	if res.Code != http.StatusCreated && res.Code != http.StatusOK {
+		t.Fatalf("POST /branch-office = %d %s", res.Code, res.Body)
+	}
+	var out struct {
+		Data struct {
+			Id string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(res.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode %s: %v", res.Body, err)
+	}
+	return out.Data.Id
+}
+
+// newBranchOffice is the body of a valid create named name.
+func newBranchOffice(name string) map[string]interface{} {
+	return map[string]interface{}{
+		"name":         name,
+		"address":      "Jl. Merdeka No. 1",
+		"phone_number": "021-555",
+		"fax_number":   "021-556",
+		"city":         "Jakarta Pusat",
+	}
+}
+
+func assertStatus(t *testing.T, res *httptest.ResponseRecorder, want int) {
+	t.Helper()
+	if res.Code != want {
+		t.Errorf("status = %d %s, want %d", res.Code, res.Body, want)
+	}
+}
+
+func TestBulkBranchOfficesStatusFilter(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		path string
+		body string
+		want int
+	}{
+		{"SoftDeleteStatusOnly", "/branch-offices/bulk-delete", `{"filter":{"status":"deleted"}}`, http.StatusUnprocessableEntity},
+		{"SoftDeleteStatusAndCity", "/branch-offices/bulk-delete", `{"filter":{"status":"deleted","city":["Jakarta Pusat"]}}`, http.StatusUnprocessableEntity},
+		{"RestoreStatusOnly", "/branch-offices/bulk-restore", `{"filter":{"status":"deleted"}}`, http.StatusUnprocessableEntity},
+		{"HardDeleteStatusOnly", "/branch-offices/bulk-hard-delete", `{"filter":{"status":"deleted"}}`, http.StatusOK},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTestServer(t)
+			s.create(t, newBranchOffice("Kantor Pusat"))
+			s.create(t, newBranchOffice("Kantor Cabang"))
+
+			assertStatus(t, s.request(t, http.MethodPost, tc.path, tc.body), tc.want)
+			if count, err := s.repo.GetBranchOfficeCount(context.Background(), repos.GetBranchOfficeListFilter{}); err != nil || count != 2 {
+				t.Errorf("%d live branch offices left, %v, want 2", count, err)
+			}
+		})
+	}
+}
+
+func TestBulkBranchOfficesEmptyFilter(t *testing.T) {
+	for _, body := range []string{
+		`{"filter":{}}`,
+		`{"filter":{"city":[""]}}`,
+		`{"filter":{"code":[" "]}}`,
+		`{"filter":{"lifecycle_status":[","]}}`,
+		`{"filter":{"city":["Jakarta Pusat",""]}}`,
+		`{"filter":{"keyword":"Pusat","fields":["bogus"]}}`,
+		`{"filter":{"keyword":"Pusat","fields":[]}}`,
+		`{"filter":{"keyword":"Pusat","fields":[""],"city":["Jakarta Pusat"]}}`,
+		`{"filter":{"keyword":""}}`,
+	} {
+		for _, path := range []string{"/branch-offices/bulk-delete", "/branch-offices/bulk-hard-delete"} {
+			t.Run(path+" "+body, func(t *testing.T) {
+				s := newTestServer(t)
+				s.create(t, newBranchOffice("Kantor Pusat"))
+				s.create(t, newBranchOffice("Kantor Cabang"))
+
+				assertStatus(t, s.request(t, http.MethodPost, path, body), http.StatusUnprocessableEntity)
+				if count, err := s.repo.GetBranchOfficeCount(context.Background(), repos.GetBranchOfficeListFilter{WithTrash: true}); err != nil || count != 2 {
+					t.Errorf("%d branch offices left, %v, want 2", count, err)
+				}
+			})
+		}
+	}
+}
+
+// upload posts content as the file of an import named filename.
+func (s *testServer) upload(t *testing.T, filename string, content []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	part, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatalf("CreateFormFile: %v", err)
+	}
+	part.Write(content)
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/branch-offices/import", &buf)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	res := httptest.NewRecorder()
+	s.engine.ServeHTTP(res, req)
+	return res
+}
+
+func TestImportBranchOfficesLimits(t *testing.T) {
+	tooManyRows := []byte("name,address,phone_number,fax_number,city\n" +
+		strings.Repeat("Kantor,Jl. Merdeka,021-555,021-556,Jakarta Pusat\n", imports.MaxBranchOfficeRows+1))
+	tooLarge := append([]byte("name\n"), bytes.Repeat([]byte("x"), imports.MaxBranchOfficeFileSize)...)
+
+	for _, tc := range []struct {
+		name    string
+		content []byte
+	}{
+		{"TooManyRows", tooManyRows},
+		{"TooLarge", tooLarge},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTestServer(t)
+			res := s.upload(t, "branch-offices.csv", tc.content)
+			assertStatus(t, res, http.StatusUnprocessableEntity)
+			// The upload as a whole is rejected, rather than row by row.
+			var out map[string]interface{}
+			json.Unmarshal(res.Body.Bytes(), &out)
+			if _, ok := out["data"]; ok {
+				t.Errorf("body = %.200s, want the file rejected as a whole", res.Body)
+			}
+		})
+	}
+}
+
+func TestBranchOfficeFaxNumberOptional(t *testing.T) {
+	s := newTestServer(t)
+	body := newBranchOffice("Kantor Pusat")
+	delete(body, "fax_number")
+	id := s.create(t, body)
+
+	// PUT takes the same fields as create, so a body accepted by one is by the other.
+	assertStatus(t, s.request(t, http.MethodPut, "/branch-office/"+id, body), http.StatusOK)
+	if res, err := s.repo.GetBranchOfficeById(context.Background(), id, false); err != nil || res.FaxNumber != "" {
+		t.Errorf("GetBranchOfficeById = %+v, %v, want no fax number", res, err)
+	}
+}
+
+func TestBulkRestoreBranchOfficesNameConflict(t *testing.T) {
+	t.Run("TakenByLive", func(t *testing.T) {
+		s := newTestServer(t)
+		id := s.create(t, newBranchOffice("Kantor Pusat"))
+		assertStatus(t, s.request(t, http.MethodDelete, "/branch-office/"+id, nil), http.StatusOK)
+		s.create(t, newBranchOffice("kantor pusat"))
+
+		single := s.request(t, http.MethodPatch, "/branch-office/restore/"+id, nil)
+		assertStatus(t, single, http.StatusConflict)
+		bulk := s.request(t, http.MethodPost, "/branch-offices/bulk-restore", map[string]interface{}{"ids": []string{id}})
+		assertStatus(t, bulk, http.StatusConflict)
+		if !strings.Contains(bulk.Body.String(), `"conflict"`) {
+			t.Errorf("body = %s, want the conflicting branch office", bulk.Body)
+		}
+	})
+
+	t.Run("WithinBatch", func(t *testing.T) {
+		s := newTestServer(t)
+		a := s.create(t, newBranchOffice("Kantor Pusat"))
+		assertStatus(t, s.request(t, http.MethodDelete, "/branch-office/"+a, nil), http.StatusOK)
+		b := s.create(t, newBranchOffice("Kantor Pusat"))
+		assertStatus(t, s.request(t, http.MethodDelete, "/branch-office/"+b, nil), http.StatusOK)
+
+		res := s.request(t, http.MethodPost, "/branch-offices/bulk-restore", map[string]interface{}{"ids": []string{a, b}})
+		assertStatus(t, res, http.StatusConflict)
+		if count, err := s.repo.GetBranchOfficeCount(context.Background(), repos.GetBranchOfficeListFilter{}); err != nil || count != 0 {
+			t.Errorf("%d branch offices restored, %v, want none", count, err)
+		}
+	})
+}
+
+func TestMigrateBranchOfficeCodes(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	coded := s.create(t, newBranchOffice("Kantor Pusat"))
+	created := time.Now().Add(-time.Hour)
+	for i, name := range []string{"Kantor Lama", "Kantor Tua"} {
+		if _, err := s.repo.CreateBranchOffice(ctx, models.BranchOffice{
+			Id:        name,
+			Name:      name,
+			City:      "Jakarta Pusat",
+			Version:   1,
+			CreatedAt: created.Add(time.Duration(i) * time.Minute),
+		}); err != nil {
+			t.Fatalf("CreateBranchOffice(%s): %v", name, err)
+		}
+	}
+
+	dryRun := s.request(t, http.MethodPost, "/branch-offices/codes/migrate?dry_run=true", nil)
+	assertStatus(t, dryRun, http.StatusOK)
+	if !strings.Contains(dryRun.Body.String(), `"assigned":2`) {
+		t.Errorf("dry run body = %s, want 2 assigned", dryRun.Body)
+	}
+
+	assertStatus(t, s.request(t, http.MethodPost, "/branch-offices/codes/migrate", nil), http.StatusOK)
+	// The dry run took no numbers, and the oldest branch office gets the lowest.
+	for id, want := range map[string]string{coded: "JKT-001", "Kantor Lama": "JKT-002", "Kantor Tua": "JKT-003"} {
+		res, err := s.repo.GetBranchOfficeById(ctx, id, false)
+		if err != nil || res.Code != want {
+			t.Errorf("GetBranchOfficeById(%s) = %+v, %v, want code %s", id, res, err, want)
+		}
+		if id != coded && (err != nil || res.Version != 2 || res.UpdatedBy != "tester") {
+			t.Errorf("GetBranchOfficeById(%s) = %+v, %v, want version 2 updated by tester", id, res, err)
+		}
+	}
+
+	again := s.request(t, http.MethodPost, "/branch-offices/codes/migrate", nil)
+	if !strings.Contains(again.Body.String(), `"total":0`) {
+		t.Errorf("second run body = %s, want nothing left to code", again.Body)
+	}
+}
+
+func TestBranchOfficeGeocodeOff(t *testing.T) {
+	s := newTestServer(t)
+	body := newBranchOffice("Kantor Pusat")
+	body["latitude"], body["longitude"] = -6.1754, 106.8272
+	id := s.create(t, body)
+
+	// Without a geocoder, cleared coordinates are not waited for.
+	assertStatus(t, s.request(t, http.MethodPut, "/branch-office/"+id+"/location", `{}`), http.StatusOK)
+	if res, err := s.repo.GetBranchOfficeById(context.Background(), id, false); err != nil || res.GeocodeStatus != models.BranchOfficeGeocodeOff || res.Latitude != nil {
+		t.Errorf("GetBranchOfficeById = %+v, %v, want no coordinates and geocoding off", res, err)
+	}
+}
+
+func TestBranchOfficeFlatAddressKept(t *testing.T) {
+	s := newTestServer(t)
+	body := newBranchOffice("Kantor Pusat")
+	body["address"] = "Jl. Merdeka No. 1, RT 01/RW 02, Desa Gambir, Jakarta Pusat 10110"
+	id := s.create(t, body)
+
+	res, err := s.repo.GetBranchOfficeById(context.Background(), id, false)
+	if err != nil || res.Address != body["address"] || res.Kelurahan != "Gambir" || res.PostalCode != "10110" {
+		t.Errorf("GetBranchOfficeById = %+v, %v, want the flat address kept and parsed", res, err)
+	}
+
+	body["address"] = "Jl. Merdeka No. 2, Jakarta Pusat 10110"
+	assertStatus(t, s.request(t, http.MethodPut, "/branch-office/"+id, body), http.StatusOK)
+	if res, err := s.repo.GetBranchOfficeById(context.Background(), id, false); err != nil || res.Address != body["address"] || res.Street != "Jl. Merdeka No. 2" {
+		t.Errorf("GetBranchOfficeById after PUT = %+v, %v, want the flat address kept and parsed", res, err)
+	}
+}
+
+func TestMigrateBranchOfficeAddresses(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	flat := "Jl. Merdeka No. 1, RT 01/RW 02, Kel. Gambir, Jakarta Pusat 10110"
+	for _, branchOffice := range []models.BranchOffice{
+		{Id: "old", Name: "Kantor Lama", Address: flat, City: "Jakarta Pusat", Country: "ID", Version: 1},
+		{Id: "structured", Name: "Kantor Baru", Address: "Jl. Thamrin 5", Street: "Jl. Thamrin 5", City: "Jakarta Pusat", Country: "ID", Version: 1},
+		{Id: "empty", Name: "Kantor Kosong", City: "Jakarta Pusat", Country: "ID", Version: 1},
+	} {
+		if _, err := s.repo.CreateBranchOffice(ctx, branchOffice); err != nil {
+			t.Fatalf("CreateBranchOffice(%s): %v", branchOffice.Id, err)
+		}
+	}
+
+	dryRun := s.request(t, http.MethodPost, "/branch-offices/addresses/migrate?dry_run=true", nil)
+	assertStatus(t, dryRun, http.StatusOK)
+	if !strings.Contains(dryRun.Body.String(), `"total":1,"structured":1`) {
+		t.Errorf("dry run body = %s, want the one unstructured address structured", dryRun.Body)
+	}
+	if res, err := s.repo.GetBranchOfficeById(ctx, "old", false); err != nil || res.Street != "" {
+		t.Errorf("GetBranchOfficeById after a dry run = %+v, %v, want it untouched", res, err)
+	}
+
+	assertStatus(t, s.request(t, http.MethodPost, "/branch-offices/addresses/migrate", nil), http.StatusOK)
+	res, err := s.repo.GetBranchOfficeById(ctx, "old", false)
+	want := models.BranchOfficeAddress{Street: "Jl. Merdeka No. 1", Rt: "001", Rw: "002", Kelurahan: "Gambir", PostalCode: "10110", Country: "ID"}
+	if err != nil || res.StructuredAddress() != want || res.Address != flat || res.Version != 2 || res.UpdatedBy != "tester" {
+		t.Errorf("GetBranchOfficeById = %+v, %v, want %+v parsed from the flat address it keeps, at version 2", res, err, want)
+	}
+	if res, err := s.repo.GetBranchOfficeById(ctx, "structured", false); err != nil || res.Version != 1 {
+		t.Errorf("GetBranchOfficeById = %+v, %v, want a structured address left alone", res, err)
+	}
+
+	again := s.request(t, http.MethodPost, "/branch-offices/addresses/migrate", nil)
+	if !strings.Contains(again.Body.String(), `"structured":0`) {
+		t.Errorf("second run body = %s, want nothing left to structure", again.Body)
+	}
+}
+
+func TestBranchOfficeCityCode(t *testing.T) {
+	s := newTestServer(t)
+	body := newBranchOffice("Kantor Pusat")
+	body["city_code"] = "32.73"
+	assertStatus(t, s.request(t, http.MethodPost, "/branch-office", body), http.StatusUnprocessableEntity)
+
+	body["city_code"] = "31.73"
+	id := s.create(t, body)
+
+	// A PUT without a city code keeps it.
+	delete(body, "city_code")
+	body["phone_number"] = "021-557"
+	assertStatus(t, s.request(t, http.MethodPut, "/branch-office/"+id, body), http.StatusOK)
+	if res, err := s.repo.GetBranchOfficeById(context.Background(), id, false); err != nil || res.CityCode != "31.73" || res.PhoneNumber != "021-557" {
+		t.Errorf("GetBranchOfficeById = %+v, %v, want the city code kept", res, err)
+	}
+
+	// Nor may the city move away from the code kept.
+	body["city"] = "Bandung"
+	res := s.request(t, http.MethodPut, "/branch-office/"+id, body)
+	assertStatus(t, res, http.StatusUnprocessableEntity)
+	if !strings.Contains(res.Body.String(), "city_code") {
+		t.Errorf("body = %s, want the city code reported", res.Body)
+	}
+
+	body["city"], body["city_code"] = "Kota Bandung", "32.73"
+	assertStatus(t, s.request(t, http.MethodPut, "/branch-office/"+id, body), http.StatusOK)
+}
+
+func TestPatchBranchOfficeRestoresWithoutMergePatch(t *testing.T) {
+	s := newTestServer(t)
+	id := s.create(t, newBranchOffice("Kantor Pusat"))
+	assertStatus(t, s.request(t, http.MethodDelete, "/branch-office/"+id, nil), http.StatusOK)
+
+	// Clients of the route from before merge patches restore with it.
+	assertStatus(t, s.request(t, http.MethodPatch, "/branch-office/"+id, nil), http.StatusOK)
+	if _, err := s.repo.GetBranchOfficeById(context.Background(), id, false); err != nil {
+		t.Errorf("GetBranchOfficeById = %v, want the branch office restored", err)
+	}
+
+	assertStatus(t, s.request(t, http.MethodPatch, "/branch-office/"+id, `{"name":"Kantor Utama"}`, "Content-Type", "application/merge-patch+json"), http.StatusOK)
+	if res, err := s.repo.GetBranchOfficeById(context.Background(), id, false); err != nil || res.Name != "Kantor Utama" {
+		t.Errorf("GetBranchOfficeById = %+v, %v, want the merge patch applied", res, err)
+	}
+}
@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jangkartech/twin-branch-office/pkg/dto"
+	"github.com/jangkartech/twin-branch-office/pkg/regions"
+	"github.com/jangkartech/twin-branch-office/pkg/services"
+	"github.com/jangkartech/twin-branch-office/pkg/validators"
+	"github.com/jangkartech/twin-util/pkg/util"
+)
+
+type RegionControllerInterface interface {
+	GetProvinces(ctx *gin.Context)
+	GetRegencies(ctx *gin.Context)
+	GetDistricts(ctx *gin.Context)
+}
+
+type regionController struct {
+	regionService services.RegionServiceInterface
+}
+
+func NewRegionController(regionService services.RegionServiceInterface) RegionControllerInterface {
+	return &regionController{
+		regionService: regionService,
+	}
+}
+
+// GetProvinces godoc
+// @Summary       Retrieve the provinces
+// @Description   Lists every province of Indonesia by its Kemendagri code, such as 31 for DKI Jakarta. keyword keeps the provinces whose name holds it, ignoring case.
+// @Tags          Regions
+// @Produce       json
+// @Param         region query dto.GetRegionRequest true "Keyword to filter by"
+// @Success       200 {object} dto.GetRegionResponse
+// @Failure       422 {object} dto.UnprocessableEntityResponse{error=dto.GetRegionValidationResponse}
+// @Router        /regions/provinces [get]
+func (c *regionController) GetProvinces(ctx *gin.Context) {
+	req, err := validators.ValidateGetRegionRequest(ctx)
+	if err != nil {
+		util.HandleErrorResponse(ctx, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	writeRegions(ctx, c.regionService.GetProvinces(*req))
+}
+
+// GetRegencies godoc
+// @Summary       Retrieve the regencies and cities of a province
+// @Description   Lists the regencies and cities of a province, whose codes, such as 31.73 for Jakarta Pusat, are the city_code of branch offices. keyword keeps those whose name holds it, ignoring case.
+// @Tags          Regions
+// @Produce       json
+// @Param         code  path  string  true "Code of the province"
+// @Param         region query dto.GetRegionRequest true "Keyword to filter by"
+// @Success       200 {object} dto.GetRegionResponse
+// @Failure       422 {object} dto.UnprocessableEntityResponse{error=dto.GetRegionValidationResponse}
+// @Failure       404 {object} dto.NotFoundResponse
+// @Router        /regions/provinces/{code}/regencies [get]
+func (c *regionController) GetRegencies(ctx *gin.Context) {
+	req, err := validators.ValidateGetRegionRequest(ctx)
+	if err != nil {
+		util.HandleErrorResponse(ctx, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	data, err := c.regionService.GetRegencies(ctx.Param("code"), *req)
+	if err != nil {
+		handleRegionError(ctx, err)
+		return
+	}
+	writeRegions(ctx, data)
+}
+
+// GetDistricts godoc
+// @Summary       Retrieve the districts of a regency or city
+// @Description   Lists the districts (kecamatan) of a regency or city, such as 31.73.01 for Gambir. keyword keeps those whose name holds it, ignoring case.
+// @Tags          Regions
+// @Produce       json
+// @Param         code  path  string  true "Code of the regency or city"
+// @Param         region query dto.GetRegionRequest true "Keyword to filter by"
+// @Success       200 {object} dto.GetRegionResponse
+// @Failure       422 {object} dto.UnprocessableEntityResponse{error=dto.GetRegionValidationResponse}
+// @Failure       404 {object} dto.NotFoundResponse
+// @Router        /regions/regencies/{code}/districts [get]
+func (c *regionController) GetDistricts(ctx *gin.Context) {
+	req, err := validators.ValidateGetRegionRequest(ctx)
+	if err != nil {
+		util.HandleErrorResponse(ctx, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	data, err := c.regionService.GetDistricts(ctx.Param("code"), *req)
+	if err != nil {
+		handleRegionError(ctx, err)
+		return
+	}
+	writeRegions(ctx, data)
+}
+
+// handleRegionError maps region service errors to their HTTP status.
+func handleRegionError(ctx *gin.Context, err error) {
+	if errors.Is(err, services.ErrRegionNotFound) {
+		util.HandleErrorResponse(ctx, http.StatusNotFound, err)
+		return
+	}
+	util.HandleErrorResponse(ctx, http.StatusInternalServerError, err)
+}
+
+func writeRegions(ctx *gin.Context, data []regions.Region) {
+	responseData := []*dto.RegionResource{}
+	for _, item := range data {
+		responseData = append(responseData, item.ToDtoResponse())
+	}
+
+	ctx.JSON(http.StatusOK, dto.GetRegionResponse{
+		Data:    responseData,
+		Message: util.ResponseMessage(http.StatusOK),
+	})
+}
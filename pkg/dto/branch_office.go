@@ -1,19 +1,107 @@
 package dto
 
-import "github.com/jangkartech/twin-util/pkg/dto"
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+
+	"github.com/jangkartech/twin-branch-office/pkg/geo"
+	"github.com/jangkartech/twin-util/pkg/dto"
+)
 
 type BranchOfficeResource struct {
-	Id          string `json:"id"`
-	Name        string `json:"name"`
-	Address     string `json:"address"`
-	PhoneNumber string `json:"phone_number"`
-	FaxNumber   string `json:"fax_number"`
-	City        string `json:"city"`
-	CreatedAt   int64  `json:"created_at"`
+	Id                         string                       `json:"id"`
+	Code                       string                       `json:"code"`
+	Name                       string                       `json:"name"`
+	Address                    string                       `json:"address"`
+	AddressDetail              BranchOfficeAddress          `json:"address_detail"`
+	PhoneNumber                string                       `json:"phone_number"`
+	FaxNumber                  string                       `json:"fax_number"`
+	City                       string                       `json:"city"`
+	CityCode                   string                       `json:"city_code"`
+	Latitude                   *float64                     `json:"latitude"`
+	Longitude                  *float64                     `json:"longitude"`
+	ServiceArea                geo.MultiPolygon             `json:"service_area" swaggertype:"object"`
+	PostalCodes                []string                     `json:"postal_codes"`
+	GeocodeStatus              string                       `json:"geocode_status"`
+	GeocodedAddress            string                       `json:"geocoded_address"`
+	LifecycleStatus            string                       `json:"lifecycle_status"`
+	LifecycleStatusReason      string                       `json:"lifecycle_status_reason"`
+	LifecycleStatusEffectiveAt *int64                       `json:"lifecycle_status_effective_at"`
+	Timezone                   string                       `json:"timezone"`
+	OpeningHours               []BranchOfficeOpeningHours   `json:"opening_hours"`
+	Exceptions                 []BranchOfficeHoursException `json:"exceptions"`
+	Version                    int64                        `json:"version"`
+	CreatedAt                  int64                        `json:"created_at"`
+	CreatedBy                  string                       `json:"created_by"`
+	UpdatedAt                  int64                        `json:"updated_at"`
+	UpdatedBy                  string                       `json:"updated_by"`
+	DeletedBy                  string                       `json:"deleted_by"`
+	DistanceKm                 *float64                     `json:"distance_km,omitempty"`
+}
+
+// BranchOfficeAddress is the structured address of a branch office, apart from the city
+// or regency, which is given on its own. Rt and Rw number the neighbourhood units of an
+// Indonesian address and Kelurahan and Kecamatan name its village and district. Country
+// is an ISO 3166-1 alpha-2 code, ID by default.
+type BranchOfficeAddress struct {
+	Street     string `validate:"required,max=255" json:"street"`
+	Rt         string `validate:"omitempty,numeric,max=3" json:"rt"`
+	Rw         string `validate:"omitempty,numeric,max=3" json:"rw"`
+	Kelurahan  string `validate:"omitempty,max=100" json:"kelurahan"`
+	Kecamatan  string `validate:"omitempty,max=100" json:"kecamatan"`
+	Province   string `validate:"omitempty,max=100" json:"province"`
+	PostalCode string `validate:"omitempty,max=10" json:"postal_code"`
+	Country    string `validate:"omitempty,iso3166_1_alpha2" json:"country"`
+}
+
+// BranchOfficeHoursInterval is a stretch of a day, from Opens up to but excluding
+// Closes, both HH:MM in the timezone of the branch office. Closes may be 24:00.
+type BranchOfficeHoursInterval struct {
+	Opens  string `validate:"required" json:"opens"`
+	Closes string `validate:"required" json:"closes"`
+}
+
+// BranchOfficeOpeningHours is a weekly interval. Weekday counts from Sunday as 0.
+type BranchOfficeOpeningHours struct {
+	Weekday int    `validate:"min=0,max=6" json:"weekday"`
+	Opens   string `validate:"required" json:"opens"`
+	Closes  string `validate:"required" json:"closes"`
+}
+
+// BranchOfficeHoursException replaces the weekly hours on Date. Without intervals the
+// branch office is closed all day.
+type BranchOfficeHoursException struct {
+	Date      string                      `validate:"required,datetime=2006-01-02" json:"date"`
+	Name      string                      `validate:"omitempty,max=100" json:"name"`
+	Intervals []BranchOfficeHoursInterval `validate:"omitempty,max=10,dive" json:"intervals"`
+}
+
+// SparseBranchOfficeResource holds the subset of BranchOfficeResource fields a client
+// asked for with the select parameter.
+type SparseBranchOfficeResource map[string]json.RawMessage
+
+// Select keeps only the given JSON fields of the resource.
+func (r *BranchOfficeResource) Select(fields []string) SparseBranchOfficeResource {
+	raw, _ := json.Marshal(r)
+	var values SparseBranchOfficeResource
+	_ = json.Unmarshal(raw, &values)
+
+	res := make(SparseBranchOfficeResource, len(fields))
+	for _, field := range fields {
+		if value, ok := values[field]; ok {
+			res[field] = value
+		}
+	}
+	return res
 }
 
 type BranchOfficeMeta struct {
 	Pagination *dto.Pagination `json:"pagination"`
+	Sort       string          `json:"sort,omitempty"`
+	NextCursor *string         `json:"next_cursor,omitempty"`
+	PrevCursor *string         `json:"prev_cursor,omitempty"`
+	TotalRows  *int64          `json:"total_rows,omitempty"`
 }
 
 type ShowBranchOfficeResponse struct {
@@ -22,19 +110,73 @@ type ShowBranchOfficeResponse struct {
 }
 
 type GetBranchOfficeRequest struct {
-	Fields  *[]string `validate:"omitempty" form:"fields"`
-	Keyword *string   `validate:"omitempty" form:"keyword"`
-	Limit   *int      `validate:"omitempty" form:"limit"`
-	Page    *int      `validate:"omitempty" form:"page"`
-	Status  *string   `validate:"omitempty" form:"status"`
+	Fields          *[]string `validate:"omitempty" form:"fields"`
+	Keyword         *string   `validate:"omitempty" form:"keyword"`
+	Limit           *int      `validate:"omitempty" form:"limit"`
+	Page            *int      `validate:"omitempty" form:"page"`
+	Status          *string   `validate:"omitempty" form:"status"`
+	CreatedBy       *string   `validate:"omitempty" form:"created_by"`
+	UpdatedBy       *string   `validate:"omitempty" form:"updated_by"`
+	DeletedBy       *string   `validate:"omitempty" form:"deleted_by"`
+	Sort            *string   `validate:"omitempty" form:"sort"`
+	City            *[]string `validate:"omitempty" form:"city"`
+	CityCode        *[]string `validate:"omitempty" form:"city_code"`
+	Code            *[]string `validate:"omitempty" form:"code"`
+	LifecycleStatus *[]string `validate:"omitempty" form:"lifecycle_status"`
+	GeocodeStatus   *[]string `validate:"omitempty" form:"geocode_status"`
+	Province        *[]string `validate:"omitempty" form:"province"`
+	PostalCode      *[]string `validate:"omitempty" form:"postal_code"`
+	Ids             *[]string `validate:"omitempty" form:"ids"`
+	CreatedFrom     *int64    `validate:"omitempty" form:"created_from"`
+	CreatedTo       *int64    `validate:"omitempty" form:"created_to"`
+	HasFax          *bool     `validate:"omitempty" form:"has_fax"`
+	Pagination      *string   `validate:"omitempty,oneof=offset cursor" form:"pagination"`
+	Cursor          *string   `validate:"omitempty" form:"cursor"`
+	WithTotal       *bool     `validate:"omitempty" form:"with_total"`
+	Select          *[]string `validate:"omitempty" form:"select"`
+	OpenAt          *int64    `validate:"omitempty" form:"open_at"`
+	OpenNow         *bool     `validate:"omitempty" form:"open_now"`
 }
 
 type GetBranchOfficeValidationResponse struct {
-	Fields  *string `json:"fields"`
-	Keyword *string `json:"keyword"`
-	Limit   *string `json:"limit"`
-	Page    *string `json:"page"`
-	Status  *string `json:"status"`
+	Fields          *string `json:"fields"`
+	Keyword         *string `json:"keyword"`
+	Limit           *string `json:"limit"`
+	Page            *string `json:"page"`
+	Status          *string `json:"status"`
+	CreatedBy       *string `json:"created_by"`
+	UpdatedBy       *string `json:"updated_by"`
+	DeletedBy       *string `json:"deleted_by"`
+	Sort            *string `json:"sort"`
+	City            *string `json:"city"`
+	CityCode        *string `json:"city_code"`
+	Code            *string `json:"code"`
+	LifecycleStatus *string `json:"lifecycle_status"`
+	GeocodeStatus   *string `json:"geocode_status"`
+	Province        *string `json:"province"`
+	PostalCode      *string `json:"postal_code"`
+	Ids             *string `json:"ids"`
+	CreatedFrom     *string `json:"created_from"`
+	CreatedTo       *string `json:"created_to"`
+	HasFax          *string `json:"has_fax"`
+	Pagination      *string `json:"pagination"`
+	Cursor          *string `json:"cursor"`
+	WithTotal       *string `json:"with_total"`
+	Select          *string `json:"select"`
+	OpenAt          *string `json:"open_at"`
+	OpenNow         *string `json:"open_now"`
+}
+
+// ExportBranchOfficeRequest takes the filters, sort and select of GetBranchOfficeRequest,
+// whose pagination it ignores.
+type ExportBranchOfficeRequest struct {
+	GetBranchOfficeRequest
+	Format *string `validate:"omitempty,oneof=csv xlsx jsonl" form:"format"`
+}
+
+type ExportBranchOfficeValidationResponse struct {
+	GetBranchOfficeValidationResponse
+	Format *string `json:"format"`
 }
 
 type GetBranchOfficeResponse struct {
@@ -43,22 +185,55 @@ type GetBranchOfficeResponse struct {
 	Message string                  `json:"message"`
 }
 
+type GetSparseBranchOfficeResponse struct {
+	Data    []SparseBranchOfficeResource `json:"data"`
+	Meta    *BranchOfficeMeta            `json:"meta"`
+	Message string                       `json:"message"`
+}
+
+// CreateBranchOfficeRequest creates a branch office. The server generates a UUIDv7 id
+// when none is given, and always assigns the branch code. A branch office starts out
+// active unless it is created as planned. Latitude and Longitude are given together or
+// not at all. An active branch office may not share its service area or postal codes
+// with another active one. The address is given either structured, as AddressDetail, or
+// as the flat Address of old, which is kept as written and parsed into one. CityCode, when given, is the
+// code of the regency or city in the regions package that City names.
 type CreateBranchOfficeRequest struct {
-	Id          string `validate:"required" json:"id"`
-	Name        string `validate:"required" json:"name"`
-	Address     string `validate:"required" json:"address"`
-	PhoneNumber string `validate:"required" json:"phone_number"`
-	City        string `validate:"required" json:"city"`
-	FaxNumber   string `validate:"required" json:"fax_number"`
+	Id              string                       `validate:"omitempty,uuid" json:"id"`
+	Name            string                       `validate:"required" json:"name"`
+	Address         string                       `validate:"required_without=AddressDetail,excluded_with=AddressDetail,max=255" json:"address"`
+	AddressDetail   *BranchOfficeAddress         `validate:"omitempty" json:"address_detail"`
+	PhoneNumber     string                       `validate:"required" json:"phone_number"`
+	City            string                       `validate:"required" json:"city"`
+	CityCode        string                       `validate:"omitempty,max=5" json:"city_code"`
+	FaxNumber       string                       `validate:"omitempty" json:"fax_number"`
+	Latitude        *float64                     `validate:"omitempty,min=-90,max=90" json:"latitude"`
+	Longitude       *float64                     `validate:"omitempty,min=-180,max=180" json:"longitude"`
+	ServiceArea     geo.MultiPolygon             `validate:"omitempty" json:"service_area" swaggertype:"object"`
+	PostalCodes     []string                     `validate:"omitempty,max=500,dive,required,max=20" json:"postal_codes"`
+	LifecycleStatus string                       `validate:"omitempty,oneof=planned active" json:"lifecycle_status"`
+	Timezone        string                       `validate:"omitempty,timezone" json:"timezone"`
+	OpeningHours    []BranchOfficeOpeningHours   `validate:"omitempty,max=70,dive" json:"opening_hours"`
+	Exceptions      []BranchOfficeHoursException `validate:"omitempty,max=366,dive" json:"exceptions"`
 }
 
 type CreateBranchOfficeValidationResponse struct {
-	Id          *string `json:"id"`
-	Name        *string `json:"name"`
-	Address     *string `json:"address"`
-	PhoneNumber *string `json:"phone_number"`
-	City        *string `json:"city"`
-	FaxNumber   *string `json:"fax_number"`
+	Id              *string `json:"id"`
+	Name            *string `json:"name"`
+	Address         *string `json:"address"`
+	AddressDetail   *string `json:"address_detail"`
+	PhoneNumber     *string `json:"phone_number"`
+	City            *string `json:"city"`
+	CityCode        *string `json:"city_code"`
+	FaxNumber       *string `json:"fax_number"`
+	Latitude        *string `json:"latitude"`
+	Longitude       *string `json:"longitude"`
+	ServiceArea     *string `json:"service_area"`
+	PostalCodes     *string `json:"postal_codes"`
+	LifecycleStatus *string `json:"lifecycle_status"`
+	Timezone        *string `json:"timezone"`
+	OpeningHours    *string `json:"opening_hours"`
+	Exceptions      *string `json:"exceptions"`
 }
 
 type CreateBranchOfficeResponse struct {
@@ -66,20 +241,134 @@ type CreateBranchOfficeResponse struct {
 	Message string                `json:"message"`
 }
 
+type BulkCreateBranchOfficeRequest struct {
+	Mode  *string                     `validate:"omitempty,oneof=atomic partial" form:"mode"`
+	Items []CreateBranchOfficeRequest `validate:"min=1,max=500" form:"-"`
+}
+
+type BulkCreateBranchOfficeValidationResponse struct {
+	Mode  *string `json:"mode"`
+	Items *string `json:"items"`
+}
+
+type BulkCreateBranchOfficeItemResult struct {
+	Index   int                                   `json:"index"`
+	Id      string                                `json:"id"`
+	Status  string                                `json:"status"`
+	Data    *BranchOfficeResource                 `json:"data,omitempty"`
+	Error   *CreateBranchOfficeValidationResponse `json:"error,omitempty"`
+	Message string                                `json:"message,omitempty"`
+}
+
+type BulkCreateBranchOfficeMeta struct {
+	Mode    string `json:"mode"`
+	Total   int    `json:"total"`
+	Created int    `json:"created"`
+	Failed  int    `json:"failed"`
+	Skipped int    `json:"skipped"`
+}
+
+type BulkCreateBranchOfficeResponse struct {
+	Data    []*BulkCreateBranchOfficeItemResult `json:"data"`
+	Meta    *BulkCreateBranchOfficeMeta         `json:"meta"`
+	Message string                              `json:"message"`
+}
+
+// UpdateBranchOfficeRequest replaces every editable field of a branch office. A missing
+// fax number clears it, while a missing city code keeps the current one; clear that with
+// a merge patch. The address is given as in CreateBranchOfficeRequest.
 type UpdateBranchOfficeRequest struct {
-	Name        *string `validate:"omitempty" json:"name"`
-	Address     *string `validate:"omitempty" json:"address"`
-	PhoneNumber *string `validate:"omitempty" json:"phone_number"`
-	City        *string `validate:"omitempty" json:"city"`
-	FaxNumber   *string `validate:"omitempty" json:"fax_number"`
+	Name          string               `validate:"required" json:"name"`
+	Address       string               `validate:"required_without=AddressDetail,excluded_with=AddressDetail,max=255" json:"address"`
+	AddressDetail *BranchOfficeAddress `validate:"omitempty" json:"address_detail"`
+	PhoneNumber   string               `validate:"required" json:"phone_number"`
+	City          string               `validate:"required" json:"city"`
+	CityCode      string               `validate:"omitempty,max=5" json:"city_code"`
+	FaxNumber     string               `validate:"omitempty" json:"fax_number"`
 }
 
 type UpdateBranchOfficeValidationResponse struct {
-	Name        *string `json:"name"`
-	Address     *string `json:"address"`
-	PhoneNumber *string `json:"phone_number"`
-	City        *string `json:"city"`
-	FaxNumber   *string `json:"fax_number"`
+	Name          *string `json:"name"`
+	Address       *string `json:"address"`
+	AddressDetail *string `json:"address_detail"`
+	PhoneNumber   *string `json:"phone_number"`
+	City          *string `json:"city"`
+	CityCode      *string `json:"city_code"`
+	FaxNumber     *string `json:"fax_number"`
+}
+
+// PatchString is a string member of a JSON merge patch (RFC 7396). Set tells whether the
+// member was present at all, and Null whether it was null, which clears the field.
+type PatchString struct {
+	Set   bool
+	Null  bool
+	Value string
+}
+
+func (p *PatchString) UnmarshalJSON(data []byte) error {
+	p.Set = true
+	if string(data) == "null" {
+		p.Null = true
+		return nil
+	}
+	return json.Unmarshal(data, &p.Value)
+}
+
+// PatchBranchOfficeAddress is the address_detail member of a merge patch, which is
+// merged into the structured address member by member. Set and Null tell, as for a
+// PatchString, whether it was present and whether it was null.
+type PatchBranchOfficeAddress struct {
+	Set        bool        `json:"-"`
+	Null       bool        `json:"-"`
+	Street     PatchString `json:"street" swaggertype:"string"`
+	Rt         PatchString `json:"rt" swaggertype:"string" extensions:"x-nullable"`
+	Rw         PatchString `json:"rw" swaggertype:"string" extensions:"x-nullable"`
+	Kelurahan  PatchString `json:"kelurahan" swaggertype:"string" extensions:"x-nullable"`
+	Kecamatan  PatchString `json:"kecamatan" swaggertype:"string" extensions:"x-nullable"`
+	Province   PatchString `json:"province" swaggertype:"string" extensions:"x-nullable"`
+	PostalCode PatchString `json:"postal_code" swaggertype:"string" extensions:"x-nullable"`
+	Country    PatchString `json:"country" swaggertype:"string"`
+}
+
+func (p *PatchBranchOfficeAddress) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*p = PatchBranchOfficeAddress{Set: true, Null: true}
+		return nil
+	}
+	// Unknown members are rejected as they are in the rest of the patch.
+	type members PatchBranchOfficeAddress
+	var res members
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&res); err != nil {
+		return err
+	}
+	*p = PatchBranchOfficeAddress(res)
+	p.Set = true
+	return nil
+}
+
+// PatchBranchOfficeRequest is a JSON merge patch of a branch office. Absent members leave
+// their field unchanged. Address and AddressDetail both edit the address, so at most one
+// of them is given.
+type PatchBranchOfficeRequest struct {
+	Name          PatchString              `json:"name" swaggertype:"string"`
+	Address       PatchString              `json:"address" swaggertype:"string"`
+	AddressDetail PatchBranchOfficeAddress `json:"address_detail" swaggertype:"object"`
+	PhoneNumber   PatchString              `json:"phone_number" swaggertype:"string"`
+	City          PatchString              `json:"city" swaggertype:"string"`
+	CityCode      PatchString              `json:"city_code" swaggertype:"string" extensions:"x-nullable"`
+	FaxNumber     PatchString              `json:"fax_number" swaggertype:"string" extensions:"x-nullable"`
+}
+
+type PatchBranchOfficeValidationResponse struct {
+	Name          *string `json:"name"`
+	Address       *string `json:"address"`
+	AddressDetail *string `json:"address_detail"`
+	PhoneNumber   *string `json:"phone_number"`
+	City          *string `json:"city"`
+	CityCode      *string `json:"city_code"`
+	FaxNumber     *string `json:"fax_number"`
 }
 
 type UpdateBranchOfficeResponse struct {
@@ -95,12 +384,305 @@ type HardDeleteBranchOfficeResponse struct {
 	Message string `json:"message"`
 }
 
+// RestoreBranchOfficeRequest is the optional body of a restore. Name renames the branch
+// office as it comes back, for when its old name has been taken.
+type RestoreBranchOfficeRequest struct {
+	Name *string `validate:"omitempty" json:"name"`
+}
+
+type RestoreBranchOfficeValidationResponse struct {
+	Name *string `json:"name"`
+}
+
 type RestoreBranchOfficeResponse struct {
-	Message string `json:"message"`
+	Data    *BranchOfficeResource `json:"data"`
+	Message string                `json:"message"`
+}
+
+// UpdateBranchOfficeHoursRequest replaces the timezone, weekly opening hours and
+// exceptions of a branch office. Intervals of a day must not overlap, and exception dates
+// must be unique.
+type UpdateBranchOfficeHoursRequest struct {
+	Timezone     string                       `validate:"required,timezone" json:"timezone"`
+	OpeningHours []BranchOfficeOpeningHours   `validate:"omitempty,max=70,dive" json:"opening_hours"`
+	Exceptions   []BranchOfficeHoursException `validate:"omitempty,max=366,dive" json:"exceptions"`
+}
+
+type UpdateBranchOfficeHoursValidationResponse struct {
+	Timezone     *string `json:"timezone"`
+	OpeningHours *string `json:"opening_hours"`
+	Exceptions   *string `json:"exceptions"`
+}
+
+type GetBranchOfficeAvailabilityRequest struct {
+	At *int64 `validate:"omitempty" form:"at"`
+}
+
+type GetBranchOfficeAvailabilityValidationResponse struct {
+	At *string `json:"at"`
+}
+
+// BranchOfficeAvailabilityResource tells whether a branch office is open at a unix time.
+// Hours are those of the local date, set by Exception when it is not null. ClosesAt is
+// given while open and NextOpensAt while closed, when it opens within two weeks.
+type BranchOfficeAvailabilityResource struct {
+	Id              string                      `json:"id"`
+	Open            bool                        `json:"open"`
+	At              int64                       `json:"at"`
+	LocalTime       string                      `json:"local_time"`
+	Timezone        string                      `json:"timezone"`
+	LifecycleStatus string                      `json:"lifecycle_status"`
+	Hours           []BranchOfficeHoursInterval `json:"hours"`
+	Exception       *BranchOfficeHoursException `json:"exception"`
+	ClosesAt        *int64                      `json:"closes_at"`
+	NextOpensAt     *int64                      `json:"next_opens_at"`
+}
+
+type GetBranchOfficeAvailabilityResponse struct {
+	Data    *BranchOfficeAvailabilityResource `json:"data"`
+	Message string                            `json:"message"`
+}
+
+// UpdateBranchOfficeLocationRequest sets the coordinates of a branch office in decimal
+// degrees. Both are given together, or both left out to clear them.
+type UpdateBranchOfficeLocationRequest struct {
+	Latitude  *float64 `validate:"omitempty,min=-90,max=90" json:"latitude"`
+	Longitude *float64 `validate:"omitempty,min=-180,max=180" json:"longitude"`
+}
+
+type UpdateBranchOfficeLocationValidationResponse struct {
+	Latitude  *string `json:"latitude"`
+	Longitude *string `json:"longitude"`
+}
+
+// UpdateBranchOfficeServiceAreaRequest replaces the area a branch office serves, a GeoJSON
+// Polygon or MultiPolygon, and the postal codes it serves. Null and an empty list clear
+// them. An active branch office may not share either with another active one.
+type UpdateBranchOfficeServiceAreaRequest struct {
+	ServiceArea geo.MultiPolygon `validate:"omitempty" json:"service_area" swaggertype:"object"`
+	PostalCodes []string         `validate:"omitempty,max=500,dive,required,max=20" json:"postal_codes"`
+}
+
+type UpdateBranchOfficeServiceAreaValidationResponse struct {
+	ServiceArea *string `json:"service_area"`
+	PostalCodes *string `json:"postal_codes"`
+}
+
+// ResolveBranchOfficeRequest finds the active branch office responsible for a point, by
+// its service area, or for a postal code. Exactly one of the two is given.
+type ResolveBranchOfficeRequest struct {
+	Lat        *float64 `validate:"omitempty,min=-90,max=90" form:"lat"`
+	Lng        *float64 `validate:"omitempty,min=-180,max=180" form:"lng"`
+	PostalCode *string  `validate:"omitempty,max=20" form:"postal_code"`
+}
+
+type ResolveBranchOfficeValidationResponse struct {
+	Lat        *string `json:"lat"`
+	Lng        *string `json:"lng"`
+	PostalCode *string `json:"postal_code"`
+}
+
+type ResolveBranchOfficeResponse struct {
+	Data    *BranchOfficeResource `json:"data"`
+	Message string                `json:"message"`
+}
+
+// GetNearbyBranchOfficeRequest looks for the branch offices nearest to Lat and Lng, up to
+// RadiusKm away when given. Branch offices without coordinates are left out.
+type GetNearbyBranchOfficeRequest struct {
+	Lat             *float64  `validate:"required,min=-90,max=90" form:"lat"`
+	Lng             *float64  `validate:"required,min=-180,max=180" form:"lng"`
+	RadiusKm        *float64  `validate:"omitempty,gt=0" form:"radius_km"`
+	Limit           *int      `validate:"omitempty,min=1,max=100" form:"limit"`
+	City            *[]string `validate:"omitempty" form:"city"`
+	LifecycleStatus *[]string `validate:"omitempty" form:"lifecycle_status"`
+}
+
+type GetNearbyBranchOfficeValidationResponse struct {
+	Lat             *string `json:"lat"`
+	Lng             *string `json:"lng"`
+	RadiusKm        *string `json:"radius_km"`
+	Limit           *string `json:"limit"`
+	City            *string `json:"city"`
+	LifecycleStatus *string `json:"lifecycle_status"`
+}
+
+// GetNearbyBranchOfficeResponse lists branch offices nearest first, each with its
+// distance_km from the requested point.
+type GetNearbyBranchOfficeResponse struct {
+	Data    []*BranchOfficeResource `json:"data"`
+	Message string                  `json:"message"`
+}
+
+// TransitionBranchOfficeRequest moves a branch office to another lifecycle status.
+// EffectiveAt is the unix time the change took effect, now when left out, and Reason is
+// required when suspending or closing.
+type TransitionBranchOfficeRequest struct {
+	Status      string `validate:"required,oneof=planned active suspended closed" json:"status"`
+	Reason      string `validate:"omitempty,max=255" json:"reason"`
+	EffectiveAt *int64 `validate:"omitempty" json:"effective_at"`
+}
+
+type TransitionBranchOfficeValidationResponse struct {
+	Status      *string `json:"status"`
+	Reason      *string `json:"reason"`
+	EffectiveAt *string `json:"effective_at"`
+}
+
+type TransitionBranchOfficeResponse struct {
+	Data    *BranchOfficeResource `json:"data"`
+	Message string                `json:"message"`
+}
+
+// BranchOfficeConflictResponse names the branch office that already holds the name a
+// write asked for.
+type BranchOfficeConflictResponse struct {
+	Error    string                `json:"error"`
+	Conflict *BranchOfficeResource `json:"conflict"`
+}
+
+// BulkBranchOfficeFilter selects the targets of a bulk write with the filters of
+// GET /branch-offices.
+type BulkBranchOfficeFilter struct {
+	Fields          *[]string `validate:"omitempty" json:"fields"`
+	Keyword         *string   `validate:"omitempty" json:"keyword"`
+	CreatedBy       *string   `validate:"omitempty" json:"created_by"`
+	UpdatedBy       *string   `validate:"omitempty" json:"updated_by"`
+	DeletedBy       *string   `validate:"omitempty" json:"deleted_by"`
+	City            *[]string `validate:"omitempty" json:"city"`
+	Code            *[]string `validate:"omitempty" json:"code"`
+	LifecycleStatus *[]string `validate:"omitempty" json:"lifecycle_status"`
+	CreatedFrom     *int64    `validate:"omitempty" json:"created_from"`
+	CreatedTo       *int64    `validate:"omitempty" json:"created_to"`
+	HasFax          *bool     `validate:"omitempty" json:"has_fax"`
+	Status          *string   `validate:"omitempty,oneof=deleted" json:"status"`
+}
+
+type BulkBranchOfficeRequest struct {
+	Ids    []string                `validate:"omitempty,max=500,dive,required" json:"ids"`
+	Filter *BulkBranchOfficeFilter `validate:"omitempty" json:"filter"`
+}
+
+type BulkBranchOfficeValidationResponse struct {
+	Ids    *string `json:"ids"`
+	Filter *string `json:"filter"`
+}
+
+type BulkBranchOfficeResource struct {
+	Affected    int64    `json:"affected"`
+	NotFoundIds []string `json:"not_found_ids"`
+}
+
+type BulkBranchOfficeResponse struct {
+	Data    *BulkBranchOfficeResource `json:"data"`
+	Message string                    `json:"message"`
+}
+
+type ImportBranchOfficeRequest struct {
+	File   *multipart.FileHeader `validate:"required" form:"file"`
+	DryRun *bool                 `validate:"omitempty" form:"dry_run"`
+	Report *string               `validate:"omitempty,oneof=json csv" form:"report"`
+}
+
+type ImportBranchOfficeValidationResponse struct {
+	File   *string `json:"file"`
+	DryRun *string `json:"dry_run"`
+	Report *string `json:"report"`
+}
+
+// ImportBranchOfficeError is a problem with one cell, or with a whole row when Column is
+// empty, of an import file. Row is the 1-based row number in the file.
+type ImportBranchOfficeError struct {
+	Row    int    `json:"row"`
+	Column string `json:"column"`
+	Reason string `json:"reason"`
+}
+
+type ImportBranchOfficeResource struct {
+	DryRun    bool                       `json:"dry_run"`
+	Total     int                        `json:"total"`
+	Created   int                        `json:"created"`
+	Updated   int                        `json:"updated"`
+	Unchanged int                        `json:"unchanged"`
+	Errors    []*ImportBranchOfficeError `json:"errors"`
+}
+
+type ImportBranchOfficeResponse struct {
+	Data    *ImportBranchOfficeResource `json:"data"`
+	Message string                      `json:"message"`
+}
+
+type MigrateBranchOfficeCityCodesRequest struct {
+	DryRun *bool `validate:"omitempty" form:"dry_run"`
+}
+
+type MigrateBranchOfficeCityCodesValidationResponse struct {
+	DryRun *string `json:"dry_run"`
+}
+
+// UnmappedBranchOfficeCityResource is a city that no city code could be given for.
+// Reason is unknown when it matches no regency, and ambiguous when it matches all of
+// Candidates.
+type UnmappedBranchOfficeCityResource struct {
+	City          string            `json:"city"`
+	BranchOffices int               `json:"branch_offices"`
+	Reason        string            `json:"reason"`
+	Candidates    []*RegionResource `json:"candidates"`
+}
+
+type MigrateBranchOfficeCityCodesResource struct {
+	DryRun   bool                                `json:"dry_run"`
+	Total    int                                 `json:"total"`
+	Mapped   int                                 `json:"mapped"`
+	Unmapped []*UnmappedBranchOfficeCityResource `json:"unmapped"`
+}
+
+type MigrateBranchOfficeCityCodesResponse struct {
+	Data    *MigrateBranchOfficeCityCodesResource `json:"data"`
+	Message string                                `json:"message"`
+}
+
+type MigrateBranchOfficeCodesRequest struct {
+	DryRun *bool `validate:"omitempty" form:"dry_run"`
+}
+
+type MigrateBranchOfficeCodesValidationResponse struct {
+	DryRun *string `json:"dry_run"`
+}
+
+type MigrateBranchOfficeCodesResource struct {
+	DryRun   bool `json:"dry_run"`
+	Total    int  `json:"total"`
+	Assigned int  `json:"assigned"`
+}
+
+type MigrateBranchOfficeCodesResponse struct {
+	Data    *MigrateBranchOfficeCodesResource `json:"data"`
+	Message string                            `json:"message"`
+}
+
+type MigrateBranchOfficeAddressesRequest struct {
+	DryRun *bool `validate:"omitempty" form:"dry_run"`
+}
+
+type MigrateBranchOfficeAddressesValidationResponse struct {
+	DryRun *string `json:"dry_run"`
+}
+
+type MigrateBranchOfficeAddressesResource struct {
+	DryRun     bool `json:"dry_run"`
+	Total      int  `json:"total"`
+	Structured int  `json:"structured"`
+}
+
+type MigrateBranchOfficeAddressesResponse struct {
+	Data    *MigrateBranchOfficeAddressesResource `json:"data"`
+	Message string                                `json:"message"`
 }
 
 type SimpleBranchOfficeResource struct {
 	Id   string `json:"id"`
+	Code string `json:"code"`
 	Name string `json:"name"`
 }
 
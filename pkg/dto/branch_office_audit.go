@@ -0,0 +1,33 @@
+package dto
+
+type BranchOfficeAuditChangeResource struct {
+	Field  string  `json:"field"`
+	Before *string `json:"before"`
+	After  *string `json:"after"`
+}
+
+type BranchOfficeAuditResource struct {
+	Id             uint64                             `json:"id"`
+	BranchOfficeId string                             `json:"branch_office_id"`
+	Action         string                             `json:"action"`
+	Actor          string                             `json:"actor"`
+	RequestId      string                             `json:"request_id"`
+	Changes        []*BranchOfficeAuditChangeResource `json:"changes"`
+	CreatedAt      int64                              `json:"created_at"`
+}
+
+type GetBranchOfficeHistoryRequest struct {
+	Limit *int `validate:"omitempty" form:"limit"`
+	Page  *int `validate:"omitempty" form:"page"`
+}
+
+type GetBranchOfficeHistoryValidationResponse struct {
+	Limit *string `json:"limit"`
+	Page  *string `json:"page"`
+}
+
+type GetBranchOfficeHistoryResponse struct {
+	Data    []*BranchOfficeAuditResource `json:"data"`
+	Meta    *BranchOfficeMeta            `json:"meta"`
+	Message string                       `json:"message"`
+}
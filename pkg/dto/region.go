@@ -0,0 +1,21 @@
+package dto
+
+type RegionResource struct {
+	Code       string `json:"code"`
+	Name       string `json:"name"`
+	Level      string `json:"level"`
+	ParentCode string `json:"parent_code"`
+}
+
+type GetRegionRequest struct {
+	Keyword *string `validate:"omitempty,max=100" form:"keyword"`
+}
+
+type GetRegionValidationResponse struct {
+	Keyword *string `json:"keyword"`
+}
+
+type GetRegionResponse struct {
+	Data    []*RegionResource `json:"data"`
+	Message string            `json:"message"`
+}
@@ -0,0 +1,264 @@
+// Package exports writes branch offices to the files finance and audit ask for.
+package exports
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jangkartech/twin-branch-office/pkg/dto"
+	"github.com/xuri/excelize/v2"
+)
+
+// Formats of an export file.
+const (
+	FormatCSV   = "csv"
+	FormatXLSX  = "xlsx"
+	FormatJSONL = "jsonl"
+)
+
+// flushEvery is how many rows a writer buffers before flushing them to the client.
+const flushEvery = 100
+
+var ErrUnsupportedFormat = errors.New("unsupported export format")
+
+// ContentTypes are the media types of the export formats.
+var ContentTypes = map[string]string{
+	FormatCSV:   "text/csv; charset=utf-8",
+	FormatXLSX:  "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	FormatJSONL: "application/x-ndjson",
+}
+
+// BranchOfficeWriter writes branch offices to an export file, one row per call.
+type BranchOfficeWriter interface {
+	Write(branchOffice *dto.BranchOfficeResource) error
+	// Close writes what the format keeps until the end and flushes the output. It does
+	// not close the underlying writer.
+	Close() error
+}
+
+// NewBranchOfficeWriter returns a writer of the given columns of branch offices to w.
+// CSV and XLSX files start with a header row and hold timestamps in RFC 3339, while JSON
+// Lines hold one resource per line exactly as the API returns it.
+func NewBranchOfficeWriter(w io.Writer, format string, columns []string) (BranchOfficeWriter, error) {
+	switch format {
+	case FormatCSV:
+		res := &csvWriter{out: w, writer: csv.NewWriter(w), columns: columns}
+		return res, res.writer.Write(columns)
+	case FormatXLSX:
+		return newXLSXWriter(w, columns)
+	case FormatJSONL:
+		return &jsonlWriter{out: w, encoder: json.NewEncoder(w), columns: columns}, nil
+	}
+	return nil, ErrUnsupportedFormat
+}
+
+// branchOfficeCell returns the value of a column of r as a spreadsheet shows it.
+func branchOfficeCell(r *dto.BranchOfficeResource, column string) string {
+	switch column {
+	case "id":
+		return r.Id
+	case "code":
+		return r.Code
+	case "name":
+		return r.Name
+	case "address":
+		return r.Address
+	case "address_detail":
+		res, _ := json.Marshal(r.AddressDetail)
+		return string(res)
+	case "phone_number":
+		return r.PhoneNumber
+	case "fax_number":
+		return r.FaxNumber
+	case "city":
+		return r.City
+	case "city_code":
+		return r.CityCode
+	case "latitude":
+		return floatCell(r.Latitude)
+	case "longitude":
+		return floatCell(r.Longitude)
+	case "service_area":
+		if len(r.ServiceArea) == 0 {
+			return ""
+		}
+		res, _ := json.Marshal(r.ServiceArea)
+		return string(res)
+	case "postal_codes":
+		return strings.Join(r.PostalCodes, ",")
+	case "geocode_status":
+		return r.GeocodeStatus
+	case "geocoded_address":
+		return r.GeocodedAddress
+	case "lifecycle_status":
+		return r.LifecycleStatus
+	case "lifecycle_status_reason":
+		return r.LifecycleStatusReason
+	case "lifecycle_status_effective_at":
+		if r.LifecycleStatusEffectiveAt == nil {
+			return ""
+		}
+		return time.Unix(*r.LifecycleStatusEffectiveAt, 0).UTC().Format(time.RFC3339)
+	case "timezone":
+		return r.Timezone
+	case "opening_hours":
+		return jsonCell(r.OpeningHours)
+	case "exceptions":
+		return jsonCell(r.Exceptions)
+	case "version":
+		return strconv.FormatInt(r.Version, 10)
+	case "created_at":
+		return time.Unix(r.CreatedAt, 0).UTC().Format(time.RFC3339)
+	case "created_by":
+		return r.CreatedBy
+	case "updated_at":
+		return time.Unix(r.UpdatedAt, 0).UTC().Format(time.RFC3339)
+	case "updated_by":
+		return r.UpdatedBy
+	case "deleted_by":
+		return r.DeletedBy
+	}
+	return ""
+}
+
+// floatCell renders an optional number in its shortest exact form, or leaves the cell
+// empty.
+func floatCell(f *float64) string {
+	if f == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*f, 'f', -1, 64)
+}
+
+// jsonCell renders a list as JSON in a single cell, or leaves the cell empty when it has
+// no items.
+func jsonCell[T any](items []T) string {
+	if len(items) == 0 {
+		return ""
+	}
+	res, _ := json.Marshal(items)
+	return string(res)
+}
+
+// flush pushes buffered output to the client when w supports it, as
+// gin.ResponseWriter does.
+func flush(w io.Writer) {
+	if flusher, ok := w.(interface{ Flush() }); ok {
+		flusher.Flush()
+	}
+}
+
+type csvWriter struct {
+	out     io.Writer
+	writer  *csv.Writer
+	columns []string
+	rows    int
+}
+
+func (w *csvWriter) Write(branchOffice *dto.BranchOfficeResource) error {
+	record := make([]string, len(w.columns))
+	for i, column := range w.columns {
+		record[i] = branchOfficeCell(branchOffice, column)
+	}
+	if err := w.writer.Write(record); err != nil {
+		return err
+	}
+	if w.rows++; w.rows%flushEvery == 0 {
+		w.writer.Flush()
+		flush(w.out)
+	}
+	return w.writer.Error()
+}
+
+func (w *csvWriter) Close() error {
+	w.writer.Flush()
+	flush(w.out)
+	return w.writer.Error()
+}
+
+// xlsxWriter builds the workbook with excelize's stream writer, which moves rows to a
+// temporary file once they outgrow its memory buffer. The workbook can only be sent
+// once complete, on Close.
+type xlsxWriter struct {
+	out     io.Writer
+	file    *excelize.File
+	stream  *excelize.StreamWriter
+	columns []string
+	rows    int
+}
+
+func newXLSXWriter(w io.Writer, columns []string) (*xlsxWriter, error) {
+	file := excelize.NewFile()
+	stream, err := file.NewStreamWriter("Sheet1")
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	res := &xlsxWriter{out: w, file: file, stream: stream, columns: columns}
+
+	header := make([]interface{}, len(columns))
+	for i, column := range columns {
+		header[i] = column
+	}
+	if err := res.writeRow(header); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return res, nil
+}
+
+func (w *xlsxWriter) writeRow(values []interface{}) error {
+	w.rows++
+	cell, err := excelize.CoordinatesToCellName(1, w.rows)
+	if err != nil {
+		return err
+	}
+	return w.stream.SetRow(cell, values)
+}
+
+func (w *xlsxWriter) Write(branchOffice *dto.BranchOfficeResource) error {
+	values := make([]interface{}, len(w.columns))
+	for i, column := range w.columns {
+		values[i] = branchOfficeCell(branchOffice, column)
+	}
+	return w.writeRow(values)
+}
+
+func (w *xlsxWriter) Close() error {
+	defer w.file.Close()
+	if err := w.stream.Flush(); err != nil {
+		return err
+	}
+	if err := w.file.Write(w.out); err != nil {
+		return err
+	}
+	flush(w.out)
+	return nil
+}
+
+type jsonlWriter struct {
+	out     io.Writer
+	encoder *json.Encoder
+	columns []string
+	rows    int
+}
+
+func (w *jsonlWriter) Write(branchOffice *dto.BranchOfficeResource) error {
+	if err := w.encoder.Encode(branchOffice.Select(w.columns)); err != nil {
+		return err
+	}
+	if w.rows++; w.rows%flushEvery == 0 {
+		flush(w.out)
+	}
+	return nil
+}
+
+func (w *jsonlWriter) Close() error {
+	flush(w.out)
+	return nil
+}
@@ -0,0 +1,94 @@
+// Package geo does the great-circle arithmetic behind the distance searches on branch
+// offices, on a spherical earth, which is accurate to about half a percent.
+package geo
+
+import "math"
+
+// EarthRadiusKm is the mean radius of the earth.
+const EarthRadiusKm = 6371.0088
+
+// MaxDistanceKm is the distance between antipodes, the farthest two points can be.
+const MaxDistanceKm = math.Pi * EarthRadiusKm
+
+// Point is a position in decimal degrees, north and east being positive.
+type Point struct {
+	Lat float64
+	Lng float64
+}
+
+// DistanceKm returns the great-circle distance between a and b by the haversine formula.
+func DistanceKm(a Point, b Point) float64 {
+	lat1, lat2 := radians(a.Lat), radians(b.Lat)
+	dLat, dLng := radians(b.Lat-a.Lat), radians(b.Lng-a.Lng)
+	h := math.Pow(math.Sin(dLat/2), 2) + math.Cos(lat1)*math.Cos(lat2)*math.Pow(math.Sin(dLng/2), 2)
+	// Rounding may push h of nearly antipodal points past 1.
+	return 2 * EarthRadiusKm * math.Asin(math.Sqrt(math.Min(1, h)))
+}
+
+// Box is a range of latitudes and longitudes. MinLng is greater than MaxLng when the box
+// crosses the antimeridian, and AllLng is set when it spans every longitude, as near
+// the poles.
+type Box struct {
+	MinLat float64
+	MaxLat float64
+	MinLng float64
+	MaxLng float64
+	AllLng bool
+}
+
+// BoundingBox returns a box holding every point within radiusKm of center. It holds
+// more than that, so it only serves to rule points out cheaply before their distance is
+// computed.
+func BoundingBox(center Point, radiusKm float64) Box {
+	delta := degrees(radiusKm / EarthRadiusKm)
+	res := Box{
+		MinLat: math.Max(-90, center.Lat-delta),
+		MaxLat: math.Min(90, center.Lat+delta),
+	}
+	if res.MinLat == -90 || res.MaxLat == 90 {
+		res.AllLng = true
+		return res
+	}
+
+	// The widest longitude span is at the latitude where the circle touches a meridian.
+	sinRatio := math.Sin(radians(delta)) / math.Cos(radians(center.Lat))
+	if sinRatio >= 1 {
+		res.AllLng = true
+		return res
+	}
+	span := degrees(math.Asin(sinRatio))
+	res.MinLng = normalizeLng(center.Lng - span)
+	res.MaxLng = normalizeLng(center.Lng + span)
+	return res
+}
+
+// ContainsLng tells whether lng is within the longitudes of the box.
+func (b Box) ContainsLng(lng float64) bool {
+	switch {
+	case b.AllLng:
+		return true
+	case b.MinLng <= b.MaxLng:
+		return lng >= b.MinLng && lng <= b.MaxLng
+	default:
+		return lng >= b.MinLng || lng <= b.MaxLng
+	}
+}
+
+// normalizeLng wraps a longitude into [-180, 180].
+func normalizeLng(lng float64) float64 {
+	for lng < -180 {
+		lng += 360
+	}
+	for lng > 180 {
+		lng -= 360
+	}
+	return lng
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func degrees(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
@@ -0,0 +1,64 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDistanceKm(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		a    Point
+		b    Point
+		want float64
+	}{
+		{"Same", Point{-6.2, 106.85}, Point{-6.2, 106.85}, 0},
+		{"JakartaBandung", Point{-6.1754, 106.8272}, Point{-6.9175, 107.6191}, 120.0},
+		{"QuarterMeridian", Point{0, 0}, Point{90, 0}, MaxDistanceKm / 2},
+		{"AcrossAntimeridian", Point{0, 179.5}, Point{0, -179.5}, MaxDistanceKm / 180},
+		{"Antipodes", Point{10, 20}, Point{-10, -160}, MaxDistanceKm},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// The distances wanted are rounded.
+			if got := DistanceKm(tc.a, tc.b); math.Abs(got-tc.want) > 0.5 {
+				t.Errorf("DistanceKm(%v, %v) = %v, want %v", tc.a, tc.b, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBoundingBox(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		center   Point
+		radiusKm float64
+		inside   []float64
+		outside  []float64
+		allLng   bool
+	}{
+		{"Jakarta", Point{-6.2, 106.85}, 50, []float64{106.85, 107.2}, []float64{107.4, -73.2}, false},
+		{"AcrossAntimeridian", Point{-18.1416, 178.4419}, 250, []float64{179.9, -179.9, 178}, []float64{175, -177, 0}, false},
+		{"NearPole", Point{89.5, 0}, 100, []float64{0, 180, -90}, nil, true},
+		{"WholeEarth", Point{0, 0}, MaxDistanceKm, []float64{0, 180}, nil, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			box := BoundingBox(tc.center, tc.radiusKm)
+			if box.AllLng != tc.allLng {
+				t.Errorf("BoundingBox(%v, %v).AllLng = %v, want %v", tc.center, tc.radiusKm, box.AllLng, tc.allLng)
+			}
+			if tc.center.Lat < box.MinLat || tc.center.Lat > box.MaxLat {
+				t.Errorf("BoundingBox(%v, %v) = %+v, want the latitude of the center", tc.center, tc.radiusKm, box)
+			}
+			for _, lng := range tc.inside {
+				if !box.ContainsLng(lng) {
+					t.Errorf("%+v.ContainsLng(%v) = false, want true", box, lng)
+				}
+			}
+			for _, lng := range tc.outside {
+				if box.ContainsLng(lng) {
+					t.Errorf("%+v.ContainsLng(%v) = true, want false", box, lng)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,408 @@
+package geo
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"sort"
+)
+
+// Position is a GeoJSON position, longitude first. An altitude is dropped.
+type Position [2]float64
+
+// Ring is a closed linear ring: its last position repeats the first.
+type Ring []Position
+
+// Polygon is an outer ring followed by the holes cut out of it.
+type Polygon []Ring
+
+// MultiPolygon is an area made of polygons that do not overlap. It reads a GeoJSON
+// Polygon or MultiPolygon geometry, writes a MultiPolygon and is null when empty.
+// Positions are compared as plane coordinates, so an area must not cross the
+// antimeridian.
+type MultiPolygon []Polygon
+
+var (
+	ErrUnsupportedGeometry = errors.New("geometry must be a GeoJSON Polygon or MultiPolygon")
+	ErrPositionOutOfRange  = errors.New("position is out of range")
+	ErrRingTooShort        = errors.New("ring has fewer than three distinct positions")
+	ErrRingNotClosed       = errors.New("ring does not end where it starts")
+	ErrSelfIntersection    = errors.New("rings cross themselves or each other")
+	ErrHoleOutside         = errors.New("hole lies outside its polygon")
+	ErrPolygonsOverlap     = errors.New("polygons overlap")
+)
+
+// epsilon absorbs the rounding of the cross products that orient positions.
+const epsilon = 1e-12
+
+func (m MultiPolygon) MarshalJSON() ([]byte, error) {
+	if len(m) == 0 {
+		return []byte("null"), nil
+	}
+	return json.Marshal(struct {
+		Type        string    `json:"type"`
+		Coordinates []Polygon `json:"coordinates"`
+	}{"MultiPolygon", m})
+}
+
+func (m *MultiPolygon) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var geometry struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	}
+	if err := json.Unmarshal(data, &geometry); err != nil {
+		return err
+	}
+	switch geometry.Type {
+	case "Polygon":
+		var polygon Polygon
+		if err := json.Unmarshal(geometry.Coordinates, &polygon); err != nil {
+			return err
+		}
+		*m = MultiPolygon{polygon}
+	case "MultiPolygon":
+		var polygons []Polygon
+		if err := json.Unmarshal(geometry.Coordinates, &polygons); err != nil {
+			return err
+		}
+		*m = polygons
+	default:
+		return ErrUnsupportedGeometry
+	}
+	return nil
+}
+
+// NumPositions counts the positions of every ring of m.
+func (m MultiPolygon) NumPositions() int {
+	res := 0
+	for _, polygon := range m {
+		for _, ring := range polygon {
+			res += len(ring)
+		}
+	}
+	return res
+}
+
+// Validate checks that m is a valid area: every ring is closed, has an area and does not
+// cross itself or another ring, holes lie within their polygon and no two polygons
+// overlap. Rings may touch at single positions, as where two holes meet.
+func (m MultiPolygon) Validate() error {
+	for _, polygon := range m {
+		if len(polygon) == 0 {
+			return ErrRingTooShort
+		}
+		for _, ring := range polygon {
+			if err := ring.validate(); err != nil {
+				return err
+			}
+		}
+		for i := range polygon {
+			for j := i + 1; j < len(polygon); j++ {
+				if ringsCross(polygon[i], polygon[j]) {
+					return ErrSelfIntersection
+				}
+			}
+			if i > 0 && !polygon[0].contains(polygon[i].interiorPoint()) {
+				return ErrHoleOutside
+			}
+		}
+	}
+	for i := range m {
+		for j := i + 1; j < len(m); j++ {
+			if m[i].overlaps(m[j]) {
+				return ErrPolygonsOverlap
+			}
+		}
+	}
+	return nil
+}
+
+func (r Ring) validate() error {
+	for _, position := range r {
+		if position[0] < -180 || position[0] > 180 || position[1] < -90 || position[1] > 90 {
+			return ErrPositionOutOfRange
+		}
+	}
+	if len(r) < 4 {
+		return ErrRingTooShort
+	}
+	if r[0] != r[len(r)-1] {
+		return ErrRingNotClosed
+	}
+	if math.Abs(r.area()) < epsilon {
+		return ErrRingTooShort
+	}
+
+	n := len(r) - 1
+	for i := 0; i < n; i++ {
+		a, b, c := r[i], r[i+1], r[(i+2)%n]
+		// A ring may neither stay in place nor turn straight back.
+		if a == b {
+			return ErrSelfIntersection
+		}
+		if math.Abs(cross(a, b, c)) < epsilon && (b[0]-a[0])*(c[0]-b[0])+(b[1]-a[1])*(c[1]-b[1]) < 0 {
+			return ErrSelfIntersection
+		}
+		for j := i + 2; j < n; j++ {
+			if i == 0 && j == n-1 {
+				continue
+			}
+			if segmentsIntersect(r[i], r[i+1], r[j], r[j+1]) {
+				return ErrSelfIntersection
+			}
+		}
+	}
+	return nil
+}
+
+// Contains tells whether p lies in the area, its boundary included.
+func (m MultiPolygon) Contains(p Point) bool {
+	position := Position{p.Lng, p.Lat}
+	for _, polygon := range m {
+		if polygon.contains(position) || polygon.onBoundary(position) {
+			return true
+		}
+	}
+	return false
+}
+
+// Overlaps tells whether the interiors of m and other meet. Areas that only share a
+// border, like neighbouring districts, do not overlap.
+func (m MultiPolygon) Overlaps(other MultiPolygon) bool {
+	for _, a := range m {
+		for _, b := range other {
+			if a.overlaps(b) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// contains tells whether position lies inside the outer ring of p and outside its
+// holes. Positions on a boundary may go either way.
+func (p Polygon) contains(position Position) bool {
+	if len(p) == 0 || !p[0].contains(position) {
+		return false
+	}
+	for _, hole := range p[1:] {
+		if hole.contains(position) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p Polygon) onBoundary(position Position) bool {
+	for _, ring := range p {
+		for i := 0; i+1 < len(ring); i++ {
+			if onSegment(ring[i], ring[i+1], position) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// containsStrictly tells whether position lies inside p and off its boundary.
+func (p Polygon) containsStrictly(position Position) bool {
+	return p.contains(position) && !p.onBoundary(position)
+}
+
+func (p Polygon) overlaps(other Polygon) bool {
+	if len(p) == 0 || len(other) == 0 || !p[0].bounds().Intersects(other[0].bounds()) {
+		return false
+	}
+	for _, a := range p {
+		for _, b := range other {
+			if ringsCross(a, b) {
+				return true
+			}
+		}
+	}
+	// Without crossing edges, the polygons overlap when one holds a position, an edge
+	// midpoint or an interior point of the other.
+	return p.holdsSampleOf(other) || other.holdsSampleOf(p)
+}
+
+func (p Polygon) holdsSampleOf(other Polygon) bool {
+	for _, ring := range other {
+		for i := 0; i+1 < len(ring); i++ {
+			midpoint := Position{(ring[i][0] + ring[i+1][0]) / 2, (ring[i][1] + ring[i+1][1]) / 2}
+			if p.containsStrictly(ring[i]) || p.containsStrictly(midpoint) {
+				return true
+			}
+		}
+	}
+	return p.containsStrictly(other.interiorPoint())
+}
+
+// interiorPoint returns a position inside p, off its boundary, found on a horizontal
+// line through its middle that passes no position.
+func (p Polygon) interiorPoint() Position {
+	if len(p) == 0 {
+		return Position{}
+	}
+	y := p[0].scanline()
+	var xs []float64
+	for _, ring := range p {
+		xs = append(xs, ring.crossings(y)...)
+	}
+	sort.Float64s(xs)
+
+	res, width := Position{}, -1.0
+	for i := 0; i+1 < len(xs); i += 2 {
+		if xs[i+1]-xs[i] > width {
+			res, width = Position{(xs[i] + xs[i+1]) / 2, y}, xs[i+1]-xs[i]
+		}
+	}
+	return res
+}
+
+func (r Ring) interiorPoint() Position {
+	return Polygon{r}.interiorPoint()
+}
+
+// scanline returns a latitude between the two positions of r nearest its middle, so a
+// horizontal line there crosses edges of r but meets none of its positions.
+func (r Ring) scanline() float64 {
+	var ys []float64
+	for _, position := range r {
+		ys = append(ys, position[1])
+	}
+	sort.Float64s(ys)
+	mid := (ys[0] + ys[len(ys)-1]) / 2
+	below, above := ys[0], ys[len(ys)-1]
+	for _, y := range ys {
+		if y <= mid && y > below {
+			below = y
+		}
+		if y > mid && y < above {
+			above = y
+		}
+	}
+	if below == above {
+		return mid
+	}
+	return (below + above) / 2
+}
+
+// crossings returns the longitudes at which the edges of r cross latitude y.
+func (r Ring) crossings(y float64) []float64 {
+	var res []float64
+	for i := 0; i+1 < len(r); i++ {
+		a, b := r[i], r[i+1]
+		if (a[1] > y) != (b[1] > y) {
+			res = append(res, a[0]+(y-a[1])*(b[0]-a[0])/(b[1]-a[1]))
+		}
+	}
+	return res
+}
+
+// contains tells whether position lies inside r by the even-odd rule.
+func (r Ring) contains(position Position) bool {
+	inside := false
+	for _, x := range r.crossings(position[1]) {
+		if x > position[0] {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// area returns the signed area of r by the shoelace formula.
+func (r Ring) area() float64 {
+	res := 0.0
+	for i := 0; i+1 < len(r); i++ {
+		res += r[i][0]*r[i+1][1] - r[i+1][0]*r[i][1]
+	}
+	return res / 2
+}
+
+func (r Ring) bounds() Box {
+	res := Box{MinLat: 90, MaxLat: -90, MinLng: 180, MaxLng: -180}
+	for _, position := range r {
+		res.MinLng = math.Min(res.MinLng, position[0])
+		res.MaxLng = math.Max(res.MaxLng, position[0])
+		res.MinLat = math.Min(res.MinLat, position[1])
+		res.MaxLat = math.Max(res.MaxLat, position[1])
+	}
+	return res
+}
+
+// Bounds returns the box around the outer rings of m, which is false when m is empty.
+func (m MultiPolygon) Bounds() (Box, bool) {
+	if len(m) == 0 {
+		return Box{}, false
+	}
+	res := Box{MinLat: 90, MaxLat: -90, MinLng: 180, MaxLng: -180}
+	for _, polygon := range m {
+		if len(polygon) == 0 {
+			continue
+		}
+		bounds := polygon[0].bounds()
+		res.MinLng = math.Min(res.MinLng, bounds.MinLng)
+		res.MaxLng = math.Max(res.MaxLng, bounds.MaxLng)
+		res.MinLat = math.Min(res.MinLat, bounds.MinLat)
+		res.MaxLat = math.Max(res.MaxLat, bounds.MaxLat)
+	}
+	return res, true
+}
+
+// Intersects tells whether b and other share a position, their edges included. Neither
+// may cross the antimeridian or span every longitude, which the bounds of an area never
+// do.
+func (b Box) Intersects(other Box) bool {
+	return b.MinLng <= other.MaxLng && other.MinLng <= b.MaxLng && b.MinLat <= other.MaxLat && other.MinLat <= b.MaxLat
+}
+
+// ringsCross tells whether an edge of a properly crosses an edge of b, each passing
+// through the inside of the other rather than meeting at an end or along a line.
+func ringsCross(a Ring, b Ring) bool {
+	for i := 0; i+1 < len(a); i++ {
+		for j := 0; j+1 < len(b); j++ {
+			if segmentsCross(a[i], a[i+1], b[j], b[j+1]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// cross returns the cross product of ab and ac, positive when c lies left of ab.
+func cross(a Position, b Position, c Position) float64 {
+	return (b[0]-a[0])*(c[1]-a[1]) - (b[1]-a[1])*(c[0]-a[0])
+}
+
+func sign(f float64) int {
+	switch {
+	case f > epsilon:
+		return 1
+	case f < -epsilon:
+		return -1
+	}
+	return 0
+}
+
+// segmentsCross tells whether ab and cd cross at a single point inside both.
+func segmentsCross(a Position, b Position, c Position, d Position) bool {
+	d1, d2 := sign(cross(a, b, c)), sign(cross(a, b, d))
+	d3, d4 := sign(cross(c, d, a)), sign(cross(c, d, b))
+	return d1*d2 < 0 && d3*d4 < 0
+}
+
+// segmentsIntersect tells whether ab and cd have any point in common.
+func segmentsIntersect(a Position, b Position, c Position, d Position) bool {
+	return segmentsCross(a, b, c, d) ||
+		onSegment(a, b, c) || onSegment(a, b, d) || onSegment(c, d, a) || onSegment(c, d, b)
+}
+
+// onSegment tells whether p lies on ab.
+func onSegment(a Position, b Position, p Position) bool {
+	return sign(cross(a, b, p)) == 0 &&
+		p[0] >= math.Min(a[0], b[0])-epsilon && p[0] <= math.Max(a[0], b[0])+epsilon &&
+		p[1] >= math.Min(a[1], b[1])-epsilon && p[1] <= math.Max(a[1], b[1])+epsilon
+}
@@ -0,0 +1,167 @@
+package geo
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// square returns the ring of the square from x0, y0 to x1, y1, counterclockwise.
+func square(x0, y0, x1, y1 float64) Ring {
+	return Ring{{x0, y0}, {x1, y0}, {x1, y1}, {x0, y1}, {x0, y0}}
+}
+
+// withHole is the square from 0, 0 to 10, 10 with the square from 4, 4 to 6, 6 cut out.
+var withHole = MultiPolygon{{square(0, 0, 10, 10), square(4, 4, 6, 6)}}
+
+func TestMultiPolygonValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		area MultiPolygon
+		want error
+	}{
+		{"Square", MultiPolygon{{square(0, 0, 1, 1)}}, nil},
+		{"Hole", withHole, nil},
+		{"HolesTouchingAtAPosition", MultiPolygon{{square(0, 0, 10, 10), square(2, 2, 4, 4), square(4, 4, 6, 6)}}, nil},
+		{"PolygonsSharingAnEdge", MultiPolygon{{square(0, 0, 1, 1)}, {square(1, 0, 2, 1)}}, nil},
+		{"NearTheAntimeridian", MultiPolygon{{square(179, -18, 180, -17)}, {square(-180, -18, -179, -17)}}, nil},
+		{"NoRings", MultiPolygon{{}}, ErrRingTooShort},
+		{"TooFewPositions", MultiPolygon{{{{0, 0}, {1, 0}, {0, 0}}}}, ErrRingTooShort},
+		{"NoArea", MultiPolygon{{{{0, 0}, {1, 0}, {2, 0}, {0, 0}}}}, ErrRingTooShort},
+		{"NotClosed", MultiPolygon{{{{0, 0}, {1, 0}, {1, 1}, {0, 1}}}}, ErrRingNotClosed},
+		{"OutOfRange", MultiPolygon{{square(179, 0, 181, 1)}}, ErrPositionOutOfRange},
+		{"LatitudeOutOfRange", MultiPolygon{{square(0, 89, 1, 91)}}, ErrPositionOutOfRange},
+		{"Bowtie", MultiPolygon{{{{0, 0}, {2, 2}, {2, 0}, {0, 1}, {0, 0}}}}, ErrSelfIntersection},
+		{"RepeatedPosition", MultiPolygon{{{{0, 0}, {1, 0}, {1, 0}, {1, 1}, {0, 0}}}}, ErrSelfIntersection},
+		{"Spike", MultiPolygon{{{{0, 0}, {2, 0}, {3, 0}, {2, 0}, {2, 2}, {0, 0}}}}, ErrSelfIntersection},
+		{"HoleCrossingOuter", MultiPolygon{{square(0, 0, 10, 10), square(8, 8, 12, 12)}}, ErrSelfIntersection},
+		{"HoleOutside", MultiPolygon{{square(0, 0, 10, 10), square(20, 20, 30, 30)}}, ErrHoleOutside},
+		{"PolygonsOverlapping", MultiPolygon{{square(0, 0, 2, 2)}, {square(1, 1, 3, 3)}}, ErrPolygonsOverlap},
+		{"PolygonInsideAnother", MultiPolygon{{square(0, 0, 10, 10)}, {square(2, 2, 3, 3)}}, ErrPolygonsOverlap},
+		{"PolygonInHole", MultiPolygon{{square(0, 0, 10, 10), square(4, 4, 6, 6)}, {square(4.5, 4.5, 5.5, 5.5)}}, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.area.Validate(); !errors.Is(err, tc.want) {
+				t.Errorf("Validate() = %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestMultiPolygonContains(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		area MultiPolygon
+		p    Point
+		want bool
+	}{
+		{"Inside", withHole, Point{Lat: 2, Lng: 2}, true},
+		{"InHole", withHole, Point{Lat: 5, Lng: 5}, false},
+		{"OnHoleEdge", withHole, Point{Lat: 5, Lng: 4}, true},
+		{"OnOuterEdge", withHole, Point{Lat: 0, Lng: 5}, true},
+		{"OnCorner", withHole, Point{Lat: 10, Lng: 10}, true},
+		{"Outside", withHole, Point{Lat: 11, Lng: 5}, false},
+		{"LatLngSwapped", MultiPolygon{{square(106, -7, 107, -6)}}, Point{Lat: 106.5, Lng: -6.5}, false},
+		{"SecondPolygon", MultiPolygon{{square(0, 0, 1, 1)}, {square(5, 5, 6, 6)}}, Point{Lat: 5.5, Lng: 5.5}, true},
+		{"EastOfTheAntimeridian", MultiPolygon{{square(-180, -18, -179, -17)}}, Point{Lat: -17.5, Lng: -179.5}, true},
+		{"Empty", nil, Point{}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.area.Contains(tc.p); got != tc.want {
+				t.Errorf("Contains(%v) = %v, want %v", tc.p, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMultiPolygonOverlaps(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		a    MultiPolygon
+		b    MultiPolygon
+		want bool
+	}{
+		{"Crossing", MultiPolygon{{square(0, 0, 2, 2)}}, MultiPolygon{{square(1, 1, 3, 3)}}, true},
+		{"Same", MultiPolygon{{square(0, 0, 2, 2)}}, MultiPolygon{{square(0, 0, 2, 2)}}, true},
+		{"Inside", MultiPolygon{{square(0, 0, 10, 10)}}, MultiPolygon{{square(2, 2, 3, 3)}}, true},
+		{"SharingAnEdge", MultiPolygon{{square(0, 0, 1, 1)}}, MultiPolygon{{square(1, 0, 2, 1)}}, false},
+		{"SharingPartOfAnEdge", MultiPolygon{{square(0, 0, 2, 2)}}, MultiPolygon{{square(2, 1, 3, 3)}}, false},
+		{"SharingACorner", MultiPolygon{{square(0, 0, 1, 1)}}, MultiPolygon{{square(1, 1, 2, 2)}}, false},
+		{"Apart", MultiPolygon{{square(0, 0, 1, 1)}}, MultiPolygon{{square(5, 5, 6, 6)}}, false},
+		{"InHole", withHole, MultiPolygon{{square(4.5, 4.5, 5.5, 5.5)}}, false},
+		{"FillingHole", withHole, MultiPolygon{{square(4, 4, 6, 6)}}, false},
+		{"OverHole", withHole, MultiPolygon{{square(3, 3, 7, 7)}}, true},
+		{"EitherSideOfTheAntimeridian", MultiPolygon{{square(179, -18, 180, -17)}}, MultiPolygon{{square(-180, -18, -179, -17)}}, false},
+		{"Empty", nil, withHole, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.a.Overlaps(tc.b); got != tc.want {
+				t.Errorf("a.Overlaps(b) = %v, want %v", got, tc.want)
+			}
+			if got := tc.b.Overlaps(tc.a); got != tc.want {
+				t.Errorf("b.Overlaps(a) = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMultiPolygonBounds(t *testing.T) {
+	area := MultiPolygon{{square(0, 0, 10, 10), square(4, 4, 6, 6)}, {square(20, -5, 21, 1)}}
+	if got, ok := area.Bounds(); !ok || got != (Box{MinLat: -5, MaxLat: 10, MinLng: 0, MaxLng: 21}) {
+		t.Errorf("Bounds() = %+v, %v, want the box around both polygons", got, ok)
+	}
+	if _, ok := MultiPolygon(nil).Bounds(); ok {
+		t.Errorf("Bounds() of no area = true, want false")
+	}
+
+	for _, tc := range []struct {
+		name  string
+		other Box
+		want  bool
+	}{
+		{"Inside", Box{MinLat: 1, MaxLat: 2, MinLng: 1, MaxLng: 2}, true},
+		{"Point", Box{MinLat: 10, MaxLat: 10, MinLng: 21, MaxLng: 21}, true},
+		{"TouchingEdge", Box{MinLat: 10, MaxLat: 12, MinLng: 5, MaxLng: 6}, true},
+		{"North", Box{MinLat: 11, MaxLat: 12, MinLng: 5, MaxLng: 6}, false},
+		{"East", Box{MinLat: 1, MaxLat: 2, MinLng: 22, MaxLng: 23}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			box, _ := area.Bounds()
+			if got := box.Intersects(tc.other); got != tc.want {
+				t.Errorf("Intersects(%+v) = %v, want %v", tc.other, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMultiPolygonJSON(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		json string
+		want MultiPolygon
+		err  error
+	}{
+		{"Polygon", `{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,1],[0,0]]]}`, MultiPolygon{{square(0, 0, 1, 1)}}, nil},
+		{"MultiPolygon", `{"type":"MultiPolygon","coordinates":[[[[0,0],[1,0],[1,1],[0,1],[0,0]]]]}`, MultiPolygon{{square(0, 0, 1, 1)}}, nil},
+		{"Altitude", `{"type":"Polygon","coordinates":[[[0,0,5],[1,0,5],[1,1,5],[0,1,5],[0,0,5]]]}`, MultiPolygon{{square(0, 0, 1, 1)}}, nil},
+		{"Null", `null`, nil, nil},
+		{"Point", `{"type":"Point","coordinates":[0,0]}`, nil, ErrUnsupportedGeometry},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var got MultiPolygon
+			err := json.Unmarshal([]byte(tc.json), &got)
+			if !errors.Is(err, tc.err) || !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Unmarshal(%s) = %v, %v, want %v, %v", tc.json, got, err, tc.want, tc.err)
+			}
+		})
+	}
+
+	out, err := json.Marshal(MultiPolygon{{square(0, 0, 1, 1)}})
+	if want := `{"type":"MultiPolygon","coordinates":[[[[0,0],[1,0],[1,1],[0,1],[0,0]]]]}`; err != nil || string(out) != want {
+		t.Errorf("Marshal = %s, %v, want %s", out, err, want)
+	}
+	if out, err := json.Marshal(MultiPolygon{}); err != nil || string(out) != "null" {
+		t.Errorf("Marshal of no area = %s, %v, want null", out, err)
+	}
+}
@@ -0,0 +1,99 @@
+package geocode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jangkartech/twin-branch-office/pkg/geo"
+)
+
+// FileEntry is a known address in the file of a file geocoder. FormattedAddress
+// defaults to the address and city.
+type FileEntry struct {
+	Address          string  `json:"address"`
+	City             string  `json:"city"`
+	Latitude         float64 `json:"latitude"`
+	Longitude        float64 `json:"longitude"`
+	FormattedAddress string  `json:"formatted_address"`
+}
+
+type fileGeocoder struct {
+	path    string
+	mu      sync.Mutex
+	modTime time.Time
+	entries map[string]*Result
+}
+
+// NewFileGeocoder returns a Geocoder that looks addresses up in a JSON array of
+// FileEntry, for development and tests where no geocoding provider is reachable. Only
+// the address and city of a query are matched, ignoring case and runs of whitespace.
+// The file is read again whenever it changes, so entries can be added while the service
+// runs; a missing or malformed file fails the lookup, which is tried again later.
+func NewFileGeocoder(path string) Geocoder {
+	return &fileGeocoder{
+		path: path,
+	}
+}
+
+func (g *fileGeocoder) Geocode(ctx context.Context, query Query) (*Result, error) {
+	entries, err := g.load()
+	if err != nil {
+		return nil, err
+	}
+	res, ok := entries[fileEntryKey(query.Address, query.City)]
+	if !ok {
+		return nil, ErrNoMatch
+	}
+	return &Result{Point: res.Point, Address: res.Address}, nil
+}
+
+// load returns the entries of the file by fileEntryKey, reading it when it changed.
+func (g *fileGeocoder) load() (map[string]*Result, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	info, err := os.Stat(g.path)
+	if err != nil {
+		return nil, err
+	}
+	if g.entries != nil && info.ModTime().Equal(g.modTime) {
+		return g.entries, nil
+	}
+
+	data, err := os.ReadFile(g.path)
+	if err != nil {
+		return nil, err
+	}
+	var list []FileEntry
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("geocode file %s: %w", g.path, err)
+	}
+	entries := make(map[string]*Result, len(list))
+	for i, entry := range list {
+		if entry.Latitude < -90 || entry.Latitude > 90 || entry.Longitude < -180 || entry.Longitude > 180 {
+			return nil, fmt.Errorf("geocode file %s: entry %d is out of range", g.path, i)
+		}
+		address := entry.FormattedAddress
+		if address == "" {
+			address = entry.Address + ", " + entry.City
+		}
+		entries[fileEntryKey(entry.Address, entry.City)] = &Result{
+			Point:   geo.Point{Lat: entry.Latitude, Lng: entry.Longitude},
+			Address: address,
+		}
+	}
+	g.entries, g.modTime = entries, info.ModTime()
+	return entries, nil
+}
+
+func fileEntryKey(address string, city string) string {
+	normalize := func(s string) string {
+		return strings.Join(strings.Fields(strings.ToLower(s)), " ")
+	}
+	return normalize(address) + "\n" + normalize(city)
+}
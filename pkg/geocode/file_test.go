@@ -0,0 +1,55 @@
+package geocode
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jangkartech/twin-branch-office/pkg/geo"
+)
+
+func TestFileGeocoder(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "geocode.json")
+	write := func(content string, modTime time.Time) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	g := NewFileGeocoder(path)
+
+	if _, err := g.Geocode(ctx, Query{Address: "Jl. Merdeka 1", City: "Jakarta Pusat"}); err == nil || errors.Is(err, ErrNoMatch) {
+		t.Errorf("Geocode without a file = %v, want a temporary error", err)
+	}
+
+	now := time.Now()
+	write(`[{"address":"Jl. Merdeka 1","city":"Jakarta Pusat","latitude":-6.17,"longitude":106.82}]`, now)
+	res, err := g.Geocode(ctx, Query{Address: "  jl.  merdeka 1", City: "JAKARTA PUSAT"})
+	if err != nil || res.Point != (geo.Point{Lat: -6.17, Lng: 106.82}) || res.Address != "Jl. Merdeka 1, Jakarta Pusat" {
+		t.Errorf("Geocode = %+v, %v, want the entry matched ignoring case and whitespace", res, err)
+	}
+	if _, err := g.Geocode(ctx, Query{Address: "Jl. Thamrin 5", City: "Jakarta Pusat"}); !errors.Is(err, ErrNoMatch) {
+		t.Errorf("Geocode of an unknown address = %v, want ErrNoMatch", err)
+	}
+
+	// The file is read again once it changes.
+	write(`[{"address":"Jl. Thamrin 5","city":"Jakarta Pusat","latitude":-6.19,"longitude":106.82,"formatted_address":"Jalan M.H. Thamrin 5"}]`, now.Add(time.Second))
+	if res, err := g.Geocode(ctx, Query{Address: "Jl. Thamrin 5", City: "Jakarta Pusat"}); err != nil || res.Address != "Jalan M.H. Thamrin 5" {
+		t.Errorf("Geocode after a change = %+v, %v, want the new entry", res, err)
+	}
+
+	for _, content := range []string{`{`, `[{"address":"Jl. Merdeka 1","latitude":91}]`} {
+		write(content, now.Add(2*time.Second))
+		if _, err := g.Geocode(ctx, Query{Address: "Jl. Merdeka 1"}); err == nil || errors.Is(err, ErrNoMatch) {
+			t.Errorf("Geocode with file %s = %v, want a temporary error", content, err)
+		}
+		now = now.Add(time.Second)
+	}
+}
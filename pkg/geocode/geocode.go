@@ -0,0 +1,36 @@
+// Package geocode resolves the postal addresses of branch offices to coordinates.
+package geocode
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jangkartech/twin-branch-office/pkg/geo"
+)
+
+// ErrNoMatch is returned by a Geocoder that knows no location for an address. Any other
+// error is taken as temporary, and the address is tried again later.
+var ErrNoMatch = errors.New("address matches no location")
+
+// Query is the address to geocode. Address is the street line, down to the kecamatan,
+// and Country an ISO 3166-1 alpha-2 code.
+type Query struct {
+	Address    string
+	City       string
+	Province   string
+	PostalCode string
+	Country    string
+}
+
+// Result is the location of an address. Address is the address the provider matched,
+// written out in full.
+type Result struct {
+	Point   geo.Point
+	Address string
+}
+
+// Geocoder resolves addresses to locations. Implementations are called from a background
+// worker and must be safe for concurrent use.
+type Geocoder interface {
+	Geocode(ctx context.Context, query Query) (*Result, error)
+}
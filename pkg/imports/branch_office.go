@@ -0,0 +1,157 @@
+// Package imports reads branch offices from the spreadsheets the operations team keeps
+// the office directory in.
+package imports
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Formats of an import file.
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// MaxBranchOfficeRows is the largest number of data rows a file may hold.
+const MaxBranchOfficeRows = 5000
+
+// MaxBranchOfficeFileSize is the largest upload, in bytes, an import reads. It leaves
+// room for MaxBranchOfficeRows rows of long values.
+const MaxBranchOfficeFileSize = 10 << 20
+
+// BranchOfficeColumns are the columns an import file may have, named by its header row.
+var BranchOfficeColumns = []string{"id", "name", "address", "phone_number", "fax_number", "city"}
+
+// readOnlyBranchOfficeColumns are columns of an export that an import skips, so an
+// exported file can be edited and imported back.
+var readOnlyBranchOfficeColumns = []string{"code", "address_detail", "city_code", "latitude", "longitude", "service_area", "postal_codes", "geocode_status", "geocoded_address", "lifecycle_status", "lifecycle_status_reason", "lifecycle_status_effective_at", "timezone", "opening_hours", "exceptions", "version", "created_at", "created_by", "updated_at", "updated_by", "deleted_by"}
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported import format")
+	ErrMissingHeader     = errors.New("import file has no header row")
+	ErrUnknownColumn     = errors.New("unknown import column")
+	ErrDuplicateColumn   = errors.New("duplicate import column")
+	ErrTooManyRows       = errors.New("import file has too many rows")
+)
+
+// BranchOfficeRow is a data row of an import file. Line is its 1-based row number in
+// the file, counting the header, and Values holds its cells by column.
+type BranchOfficeRow struct {
+	Line   int
+	Values map[string]string
+}
+
+// ReadBranchOfficeRows reads the rows of a CSV file, or of the first sheet of an XLSX
+// workbook. Headers are matched case-insensitively, with spaces standing for
+// underscores, and blank rows are skipped. Reading stops with ErrTooManyRows at the
+// first row past MaxBranchOfficeRows.
+func ReadBranchOfficeRows(r io.Reader, format string) ([]BranchOfficeRow, error) {
+	var next func() ([]string, error)
+	switch format {
+	case FormatCSV:
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+		next = reader.Read
+	case FormatXLSX:
+		records, closeRecords, err := readXLSX(r)
+		if err != nil {
+			return nil, err
+		}
+		defer closeRecords()
+		next = records
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+
+	record, err := next()
+	if errors.Is(err, io.EOF) {
+		return nil, ErrMissingHeader
+	}
+	if err != nil {
+		return nil, err
+	}
+	header := make([]string, len(record))
+	for i, cell := range record {
+		// Spreadsheet apps may start a CSV file with a byte order mark.
+		column := strings.ToLower(strings.TrimSpace(cell))
+		column = strings.ReplaceAll(strings.TrimPrefix(column, "\ufeff"), " ", "_")
+		if column == "" || slices.Contains(readOnlyBranchOfficeColumns, column) {
+			continue
+		}
+		if !slices.Contains(BranchOfficeColumns, column) {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownColumn, cell)
+		}
+		if slices.Contains(header, column) {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateColumn, cell)
+		}
+		header[i] = column
+	}
+
+	var res []BranchOfficeRow
+	for line := 2; ; line++ {
+		record, err := next()
+		if errors.Is(err, io.EOF) {
+			return res, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		row := BranchOfficeRow{Line: line, Values: map[string]string{}}
+		for j, cell := range record {
+			if j < len(header) && header[j] != "" {
+				if cell = strings.TrimSpace(cell); cell != "" {
+					row.Values[header[j]] = cell
+				}
+			}
+		}
+		if len(row.Values) == 0 {
+			continue
+		}
+		if len(res) == MaxBranchOfficeRows {
+			return nil, ErrTooManyRows
+		}
+		res = append(res, row)
+	}
+}
+
+// readXLSX returns a reader of the rows of the first sheet of an XLSX workbook, which
+// ends with io.EOF, and a func releasing the workbook.
+func readXLSX(r io.Reader) (func() ([]string, error), func(), error) {
+	file, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sheets := file.GetSheetList()
+	if len(sheets) == 0 {
+		file.Close()
+		return func() ([]string, error) { return nil, io.EOF }, func() {}, nil
+	}
+	rows, err := file.Rows(sheets[0])
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	next := func() ([]string, error) {
+		if !rows.Next() {
+			if err := rows.Error(); err != nil {
+				return nil, err
+			}
+			return nil, io.EOF
+		}
+		return rows.Columns()
+	}
+	release := func() {
+		rows.Close()
+		file.Close()
+	}
+	return next, release, nil
+}
@@ -0,0 +1,84 @@
+package imports_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/jangkartech/twin-branch-office/pkg/imports"
+	"github.com/xuri/excelize/v2"
+)
+
+func TestReadBranchOfficeRows(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		content string
+		want    []imports.BranchOfficeRow
+		wantErr error
+	}{
+		{
+			name:    "HeaderAndRows",
+			content: "\ufeffName,Phone Number,code\nKantor Pusat, 021-555 ,JKT-001\n,,\nKantor Cabang,,\n",
+			want: []imports.BranchOfficeRow{
+				{Line: 2, Values: map[string]string{"name": "Kantor Pusat", "phone_number": "021-555"}},
+				{Line: 4, Values: map[string]string{"name": "Kantor Cabang"}},
+			},
+		},
+		{name: "Empty", content: "", wantErr: imports.ErrMissingHeader},
+		{name: "UnknownColumn", content: "name,branch\n", wantErr: imports.ErrUnknownColumn},
+		{name: "DuplicateColumn", content: "name,Name\n", wantErr: imports.ErrDuplicateColumn},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := imports.ReadBranchOfficeRows(strings.NewReader(tc.content), imports.FormatCSV)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("ReadBranchOfficeRows error = %v, want %v", err, tc.wantErr)
+			}
+			if !reflect.DeepEqual(res, tc.want) {
+				t.Errorf("ReadBranchOfficeRows = %+v, want %+v", res, tc.want)
+			}
+		})
+	}
+}
+
+func TestReadBranchOfficeRowsXLSX(t *testing.T) {
+	file := excelize.NewFile()
+	defer file.Close()
+	for i, row := range [][]interface{}{{"name", "city"}, {"Kantor Pusat", "Jakarta Pusat"}} {
+		if err := file.SetSheetRow("Sheet1", fmt.Sprintf("A%d", i+1), &row); err != nil {
+			t.Fatalf("SetSheetRow: %v", err)
+		}
+	}
+	var buf bytes.Buffer
+	if err := file.Write(&buf); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	res, err := imports.ReadBranchOfficeRows(&buf, imports.FormatXLSX)
+	if err != nil {
+		t.Fatalf("ReadBranchOfficeRows: %v", err)
+	}
+	want := []imports.BranchOfficeRow{{Line: 2, Values: map[string]string{"name": "Kantor Pusat", "city": "Jakarta Pusat"}}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("ReadBranchOfficeRows = %+v, want %+v", res, want)
+	}
+}
+
+func TestReadBranchOfficeRowsStopsAtCap(t *testing.T) {
+	var content strings.Builder
+	content.WriteString("name\n")
+	for i := 0; i < imports.MaxBranchOfficeRows+10; i++ {
+		fmt.Fprintf(&content, "Kantor %d\n", i)
+	}
+	// Reading on to the end of the file would fail.
+	errReadPastCap := errors.New("read past the row cap")
+	r := io.MultiReader(strings.NewReader(content.String()), iotest.ErrReader(errReadPastCap))
+
+	if _, err := imports.ReadBranchOfficeRows(r, imports.FormatCSV); !errors.Is(err, imports.ErrTooManyRows) {
+		t.Errorf("ReadBranchOfficeRows error = %v, want %v", err, imports.ErrTooManyRows)
+	}
+}
@@ -0,0 +1,52 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/jangkartech/twin-branch-office/pkg/models"
+	"gorm.io/gorm"
+)
+
+// Migrate creates or updates every table owned by this module. Hosts call it on
+// startup, before Register. Creating the name index fails while live branch offices
+// share a name, which has to be fixed by hand first. Beyond the schema, it only fills in
+// what older rows already mean, such as the bounds of their service areas. The branch
+// offices stored before codes, city codes or structured addresses existed are brought up
+// to date by the migrations of the API instead, each a new version with an audit entry.
+func Migrate(db *gorm.DB) error {
+	err := db.AutoMigrate(
+		&models.BranchOffice{},
+		&models.BranchOfficeAudit{},
+		&models.BranchOfficeCodeSequence{},
+	)
+	if err != nil {
+		return err
+	}
+	// GORM tags cannot express an index on an expression with a predicate.
+	err = db.Exec(fmt.Sprintf(
+		"CREATE UNIQUE INDEX IF NOT EXISTS %s ON branch_offices ((%s)) WHERE deleted_at IS NULL",
+		models.BranchOfficeNameIndex, models.BranchOfficeNameKeySQL,
+	)).Error
+	if err != nil {
+		return err
+	}
+	// Branch offices stored before geocoding existed have no geocode status, which is
+	// what off means.
+	err = db.Model(&models.BranchOffice{}).Unscoped().Where("geocode_status = ''").UpdateColumn("geocode_status", models.BranchOfficeGeocodeOff).Error
+	if err != nil {
+		return err
+	}
+	// The bounds of the service areas stored before they were kept are derived in place,
+	// which changes nothing the API shows.
+	return db.Exec(`UPDATE branch_offices SET
+		service_area_min_lng = b.min_lng, service_area_max_lng = b.max_lng,
+		service_area_min_lat = b.min_lat, service_area_max_lat = b.max_lat
+	FROM (
+		SELECT id, MIN((p->>0)::float8) AS min_lng, MAX((p->>0)::float8) AS max_lng,
+			MIN((p->>1)::float8) AS min_lat, MAX((p->>1)::float8) AS max_lat
+		FROM branch_offices, jsonb_path_query(service_area, 'strict $.coordinates[*][0][*]') p
+		WHERE jsonb_typeof(service_area) = 'object' AND service_area_min_lng IS NULL
+		GROUP BY id
+	) b
+	WHERE branch_offices.id = b.id`).Error
+}
@@ -1,38 +1,197 @@
 package models
 
 import (
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/jangkartech/twin-branch-office/pkg/dto"
+	"github.com/jangkartech/twin-branch-office/pkg/geo"
 	"gorm.io/gorm"
 )
 
 type BranchOffice struct {
-	Id          string         `gorm:"type:varchar(36);primaryKey;" json:"id"`
-	Name        string         `gorm:"type:varchar(100);" json:"name"`
-	Address     string         `gorm:"type:varchar(100);" json:"address"`
-	PhoneNumber string         `gorm:"type:varchar(100);" json:"phone_number"`
-	FaxNumber   string         `gorm:"type:varchar(100);" json:"fax_number"`
-	City        string         `gorm:"type:varchar(100);" json:"city"`
-	CreatedAt   time.Time      `gorm:"default:CURRENT_TIMESTAMP;" json:"created_at"`
-	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+	Id                         string                       `gorm:"type:varchar(36);primaryKey;" json:"id"`
+	Code                       string                       `gorm:"type:varchar(30);uniqueIndex:idx_branch_offices_code,where:code <> '';" json:"code"`
+	Name                       string                       `gorm:"type:varchar(100);" json:"name"`
+	Address                    string                       `gorm:"type:text;" json:"address"`
+	Street                     string                       `gorm:"type:varchar(255);not null;default:'';" json:"street"`
+	Rt                         string                       `gorm:"type:varchar(3);not null;default:'';" json:"rt"`
+	Rw                         string                       `gorm:"type:varchar(3);not null;default:'';" json:"rw"`
+	Kelurahan                  string                       `gorm:"type:varchar(100);not null;default:'';" json:"kelurahan"`
+	Kecamatan                  string                       `gorm:"type:varchar(100);not null;default:'';" json:"kecamatan"`
+	Province                   string                       `gorm:"type:varchar(100);not null;default:'';index;" json:"province"`
+	PostalCode                 string                       `gorm:"type:varchar(10);not null;default:'';index;" json:"postal_code"`
+	Country                    string                       `gorm:"type:varchar(2);not null;default:ID;" json:"country"`
+	PhoneNumber                string                       `gorm:"type:varchar(100);" json:"phone_number"`
+	FaxNumber                  string                       `gorm:"type:varchar(100);" json:"fax_number"`
+	City                       string                       `gorm:"type:varchar(100);" json:"city"`
+	CityCode                   string                       `gorm:"type:varchar(5);not null;default:'';index;" json:"city_code"`
+	Latitude                   *float64                     `gorm:"type:double precision;index:idx_branch_offices_location;" json:"latitude"`
+	Longitude                  *float64                     `gorm:"type:double precision;index:idx_branch_offices_location;" json:"longitude"`
+	ServiceArea                geo.MultiPolygon             `gorm:"type:jsonb;serializer:json;" json:"service_area"`
+	PostalCodes                []string                     `gorm:"type:jsonb;serializer:json;index:idx_branch_offices_postal_codes,type:gin;" json:"postal_codes"`
+	ServiceAreaMinLng          *float64                     `gorm:"type:double precision;index:idx_branch_offices_service_area_bounds;" json:"-"`
+	ServiceAreaMaxLng          *float64                     `gorm:"type:double precision;index:idx_branch_offices_service_area_bounds;" json:"-"`
+	ServiceAreaMinLat          *float64                     `gorm:"type:double precision;index:idx_branch_offices_service_area_bounds;" json:"-"`
+	ServiceAreaMaxLat          *float64                     `gorm:"type:double precision;index:idx_branch_offices_service_area_bounds;" json:"-"`
+	GeocodeStatus              string                       `gorm:"type:varchar(20);not null;default:off;index;" json:"geocode_status"`
+	GeocodedAddress            string                       `gorm:"type:varchar(255);" json:"geocoded_address"`
+	GeocodeAttempts            int                          `gorm:"not null;default:0;" json:"geocode_attempts"`
+	GeocodeNextAttemptAt       *time.Time                   `json:"geocode_next_attempt_at"`
+	LifecycleStatus            string                       `gorm:"type:varchar(20);not null;default:active;index;" json:"lifecycle_status"`
+	LifecycleStatusReason      string                       `gorm:"type:varchar(255);" json:"lifecycle_status_reason"`
+	LifecycleStatusEffectiveAt *time.Time                   `json:"lifecycle_status_effective_at"`
+	Timezone                   string                       `gorm:"type:varchar(64);not null;default:Asia/Jakarta;" json:"timezone"`
+	OpeningHours               []BranchOfficeOpeningHours   `gorm:"type:jsonb;serializer:json;" json:"opening_hours"`
+	Exceptions                 []BranchOfficeHoursException `gorm:"type:jsonb;serializer:json;" json:"exceptions"`
+	Version                    int64                        `gorm:"not null;default:1;" json:"version"`
+	CreatedAt                  time.Time                    `gorm:"default:CURRENT_TIMESTAMP;" json:"created_at"`
+	CreatedBy                  string                       `gorm:"type:varchar(100);index;" json:"created_by"`
+	UpdatedAt                  time.Time                    `gorm:"default:CURRENT_TIMESTAMP;" json:"updated_at"`
+	UpdatedBy                  string                       `gorm:"type:varchar(100);index;" json:"updated_by"`
+	DeletedAt                  gorm.DeletedAt               `gorm:"index" json:"-"`
+	DeletedBy                  string                       `gorm:"type:varchar(100);" json:"deleted_by"`
+}
+
+// Lifecycle statuses of a branch office. They are independent of soft deletes: a closed
+// branch office stays in the directory until it is deleted.
+const (
+	BranchOfficeStatusPlanned   = "planned"
+	BranchOfficeStatusActive    = "active"
+	BranchOfficeStatusSuspended = "suspended"
+	BranchOfficeStatusClosed    = "closed"
+)
+
+// BranchOfficeStatuses lists the lifecycle statuses in the order an office goes through
+// them.
+var BranchOfficeStatuses = []string{BranchOfficeStatusPlanned, BranchOfficeStatusActive, BranchOfficeStatusSuspended, BranchOfficeStatusClosed}
+
+// Geocode statuses of a branch office. A pending branch office waits for its address to
+// be geocoded, and failed ones gave up after several attempts. Manual coordinates were
+// given by hand and are never geocoded over. Off branch offices were created, or had
+// their address or coordinates changed, while geocoding was off, so their coordinates,
+// if any, do not follow their address.
+const (
+	BranchOfficeGeocodeOff       = "off"
+	BranchOfficeGeocodePending   = "pending"
+	BranchOfficeGeocodeSucceeded = "succeeded"
+	BranchOfficeGeocodeNotFound  = "not_found"
+	BranchOfficeGeocodeFailed    = "failed"
+	BranchOfficeGeocodeManual    = "manual"
+)
+
+// BranchOfficeGeocodeStatuses lists the geocode statuses.
+var BranchOfficeGeocodeStatuses = []string{BranchOfficeGeocodeOff, BranchOfficeGeocodePending, BranchOfficeGeocodeSucceeded, BranchOfficeGeocodeNotFound, BranchOfficeGeocodeFailed, BranchOfficeGeocodeManual}
+
+// PostalCodeKey normalizes a postal code, which is compared ignoring case and
+// surrounding whitespace.
+func PostalCodeKey(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// PostalCodesFromDto normalizes postal codes by PostalCodeKey and sorts them.
+func PostalCodesFromDto(codes []string) []string {
+	res := make([]string, 0, len(codes))
+	for _, code := range codes {
+		res = append(res, PostalCodeKey(code))
+	}
+	slices.Sort(res)
+	return slices.Compact(res)
+}
+
+// BranchOfficeCodeIndex is the unique index of branch codes. Branch offices created
+// before codes existed have none.
+const BranchOfficeCodeIndex = "idx_branch_offices_code"
+
+// BranchOfficeCodeSequence is the last number handed out in the branch codes of a scope,
+// which is the code pattern rendered for a city.
+type BranchOfficeCodeSequence struct {
+	Scope string `gorm:"type:varchar(30);primaryKey;"`
+	Value int64  `gorm:"not null;"`
+}
+
+// BranchOfficeNameIndex is the partial unique index that keeps the names of live branch
+// offices unique by BranchOfficeNameKey.
+const BranchOfficeNameIndex = "idx_branch_offices_name_key"
+
+// BranchOfficeNameKeySQL computes BranchOfficeNameKey of the name column in SQL.
+const BranchOfficeNameKeySQL = `LOWER(TRIM(REGEXP_REPLACE(name, '\s+', ' ', 'g')))`
+
+// BranchOfficeNameKey is what makes two branch office names the same: they are compared
+// ignoring case, surrounding whitespace and the length of whitespace runs.
+func BranchOfficeNameKey(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// BranchOfficeServiceAreaBoundsColumns hold the bounds of the service area, which the
+// repos derive from it whenever it is written, so that the GORM repo can rule out most
+// branch offices by index before comparing areas.
+var BranchOfficeServiceAreaBoundsColumns = []string{"service_area_min_lng", "service_area_max_lng", "service_area_min_lat", "service_area_max_lat"}
+
+// SetServiceAreaBounds sets the bounds of the service area from it, or clears them.
+func (m *BranchOffice) SetServiceAreaBounds() {
+	box, ok := m.ServiceArea.Bounds()
+	if !ok {
+		m.ServiceAreaMinLng, m.ServiceAreaMaxLng, m.ServiceAreaMinLat, m.ServiceAreaMaxLat = nil, nil, nil, nil
+		return
+	}
+	m.ServiceAreaMinLng, m.ServiceAreaMaxLng = &box.MinLng, &box.MaxLng
+	m.ServiceAreaMinLat, m.ServiceAreaMaxLat = &box.MinLat, &box.MaxLat
+}
+
+// Point returns the coordinates of the branch office, which it may not have been given.
+func (m *BranchOffice) Point() (geo.Point, bool) {
+	if m.Latitude == nil || m.Longitude == nil {
+		return geo.Point{}, false
+	}
+	return geo.Point{Lat: *m.Latitude, Lng: *m.Longitude}, true
 }
 
 func (m *BranchOffice) ToDtoResponse() *dto.BranchOfficeResource {
 	return &dto.BranchOfficeResource{
-		Id:          m.Id,
-		Name:        m.Name,
-		Address:     m.Address,
-		PhoneNumber: m.PhoneNumber,
-		FaxNumber:   m.FaxNumber,
-		City:        m.City,
-		CreatedAt:   m.CreatedAt.Unix(),
+		Id:                         m.Id,
+		Code:                       m.Code,
+		Name:                       m.Name,
+		Address:                    m.Address,
+		AddressDetail:              *m.StructuredAddress().ToDto(),
+		PhoneNumber:                m.PhoneNumber,
+		FaxNumber:                  m.FaxNumber,
+		City:                       m.City,
+		CityCode:                   m.CityCode,
+		Latitude:                   m.Latitude,
+		Longitude:                  m.Longitude,
+		ServiceArea:                m.ServiceArea,
+		PostalCodes:                append([]string{}, m.PostalCodes...),
+		GeocodeStatus:              m.GeocodeStatus,
+		GeocodedAddress:            m.GeocodedAddress,
+		LifecycleStatus:            m.LifecycleStatus,
+		LifecycleStatusReason:      m.LifecycleStatusReason,
+		LifecycleStatusEffectiveAt: unixOrNil(m.LifecycleStatusEffectiveAt),
+		Timezone:                   m.Timezone,
+		OpeningHours:               OpeningHoursToDto(m.OpeningHours),
+		Exceptions:                 HoursExceptionsToDto(m.Exceptions),
+		Version:                    m.Version,
+		CreatedAt:                  m.CreatedAt.Unix(),
+		CreatedBy:                  m.CreatedBy,
+		UpdatedAt:                  m.UpdatedAt.Unix(),
+		UpdatedBy:                  m.UpdatedBy,
+		DeletedBy:                  m.DeletedBy,
+	}
+}
+
+func unixOrNil(t *time.Time) *int64 {
+	if t == nil {
+		return nil
 	}
+	res := t.Unix()
+	return &res
 }
 
 func (m *BranchOffice) ToDtoSimpleResponse() *dto.SimpleBranchOfficeResource {
 	return &dto.SimpleBranchOfficeResource{
 		Id:   m.Id,
+		Code: m.Code,
 		Name: m.Name,
 	}
 }
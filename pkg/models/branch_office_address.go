@@ -0,0 +1,224 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jangkartech/twin-branch-office/pkg/dto"
+)
+
+// DefaultBranchOfficeCountry is the country of branch offices whose address gives none.
+const DefaultBranchOfficeCountry = "ID"
+
+// BranchOfficeAddress is the structured address of a branch office below its city or
+// regency, which is kept in the City column. Rt and Rw are zero-padded to three digits.
+type BranchOfficeAddress struct {
+	Street     string
+	Rt         string
+	Rw         string
+	Kelurahan  string
+	Kecamatan  string
+	Province   string
+	PostalCode string
+	Country    string
+}
+
+// BranchOfficeAddressColumns are the columns a change of address writes, the flat
+// address included.
+var BranchOfficeAddressColumns = []string{"address", "street", "rt", "rw", "kelurahan", "kecamatan", "province", "postal_code", "country"}
+
+var (
+	rtRwPattern       = regexp.MustCompile(`(?i)\bRT\.?\s*(\d{1,3})\s*(?:/\s*|\s+)RW\.?\s*(\d{1,3})\b`)
+	kelurahanPattern  = regexp.MustCompile(`(?i)^(?:kel\.|ds\.|(?:kel|kelurahan|desa|ds)\s)\s*(.+)$`)
+	kecamatanPattern  = regexp.MustCompile(`(?i)^(?:kec\.|(?:kec|kecamatan)\s)\s*(.+)$`)
+	postalCodePattern = regexp.MustCompile(`^(.*?)\s*\b(\d{5})$`)
+
+	indonesianPostalCodePattern = regexp.MustCompile(`^\d{5}$`)
+)
+
+// ParseBranchOfficeAddress reads a flat address as written before addresses were
+// structured, such as "Jl. Merdeka No. 1, RT 01/RW 02, Kel. Gambir, Kec. Gambir,
+// Jakarta Pusat 10110". It picks out the RT/RW, the kelurahan and kecamatan, and a last
+// part that is the city, the postal code or the city followed by the postal code, which
+// drops the city. The rest is kept as the street, house numbers of five digits included.
+func ParseBranchOfficeAddress(address string, city string) BranchOfficeAddress {
+	res := BranchOfficeAddress{Country: DefaultBranchOfficeCountry}
+	if match := rtRwPattern.FindStringSubmatch(address); match != nil {
+		res.Rt, res.Rw = padNeighbourhood(match[1]), padNeighbourhood(match[2])
+		address = strings.Replace(address, match[0], "", 1)
+	}
+
+	var street []string
+	segments := strings.Split(address, ",")
+	for i, segment := range segments {
+		segment = strings.TrimSpace(segment)
+		if i > 0 && i == len(segments)-1 {
+			if match := postalCodePattern.FindStringSubmatch(segment); match != nil && (match[1] == "" || BranchOfficeNameKey(match[1]) == BranchOfficeNameKey(city)) {
+				res.PostalCode = match[2]
+				segment = ""
+			}
+			if BranchOfficeNameKey(segment) == BranchOfficeNameKey(city) {
+				segment = ""
+			}
+		}
+		switch match := kelurahanPattern.FindStringSubmatch(segment); {
+		case segment == "":
+		case match != nil:
+			res.Kelurahan = strings.TrimSpace(match[1])
+		case kecamatanPattern.MatchString(segment):
+			res.Kecamatan = strings.TrimSpace(kecamatanPattern.FindStringSubmatch(segment)[1])
+		default:
+			street = append(street, segment)
+		}
+	}
+	res.Street = strings.Join(street, ", ")
+	return res
+}
+
+// WithFlatAddress replaces the parts of a that a flat address holds, as String renders
+// them, with those parsed from address. The province and country stay, and so does the
+// postal code unless address ends with one.
+func (a BranchOfficeAddress) WithFlatAddress(address string, city string) BranchOfficeAddress {
+	res := ParseBranchOfficeAddress(address, city)
+	res.Province, res.Country = a.Province, a.Country
+	if res.PostalCode == "" {
+		res.PostalCode = a.PostalCode
+	}
+	if res.Country == "" {
+		res.Country = DefaultBranchOfficeCountry
+	}
+	return res
+}
+
+// ValidPostalCode tells whether the postal code, if any, is written as the country of the
+// address writes them. Only Indonesian postal codes, of five digits, are checked.
+func (a BranchOfficeAddress) ValidPostalCode() bool {
+	return a.PostalCode == "" || a.Country != DefaultBranchOfficeCountry || indonesianPostalCodePattern.MatchString(a.PostalCode)
+}
+
+// padNeighbourhood zero-pads an RT or RW number to three digits, as it is written on
+// Indonesian addresses. Anything but a number is kept as it is.
+func padNeighbourhood(number string) string {
+	n, err := strconv.Atoi(number)
+	if err != nil {
+		return number
+	}
+	return fmt.Sprintf("%03d", n)
+}
+
+// String renders the address as the flat address of old: the street, RT/RW, kelurahan
+// and kecamatan. The city, province, postal code and country have fields of their own.
+func (a BranchOfficeAddress) String() string {
+	var parts []string
+	if a.Street != "" {
+		parts = append(parts, a.Street)
+	}
+	switch {
+	case a.Rt != "" && a.Rw != "":
+		parts = append(parts, "RT "+a.Rt+"/RW "+a.Rw)
+	case a.Rt != "":
+		parts = append(parts, "RT "+a.Rt)
+	case a.Rw != "":
+		parts = append(parts, "RW "+a.Rw)
+	}
+	if a.Kelurahan != "" {
+		parts = append(parts, "Kel. "+a.Kelurahan)
+	}
+	if a.Kecamatan != "" {
+		parts = append(parts, "Kec. "+a.Kecamatan)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// StructuredAddress returns the structured address of the branch office.
+func (m *BranchOffice) StructuredAddress() BranchOfficeAddress {
+	return BranchOfficeAddress{
+		Street:     m.Street,
+		Rt:         m.Rt,
+		Rw:         m.Rw,
+		Kelurahan:  m.Kelurahan,
+		Kecamatan:  m.Kecamatan,
+		Province:   m.Province,
+		PostalCode: m.PostalCode,
+		Country:    m.Country,
+	}
+}
+
+// SetAddress sets the structured address of the branch office and the flat address
+// rendered from it.
+func (m *BranchOffice) SetAddress(address BranchOfficeAddress) {
+	m.Address = address.String()
+	m.Street = address.Street
+	m.Rt = address.Rt
+	m.Rw = address.Rw
+	m.Kelurahan = address.Kelurahan
+	m.Kecamatan = address.Kecamatan
+	m.Province = address.Province
+	m.PostalCode = address.PostalCode
+	m.Country = address.Country
+}
+
+// SetFlatAddress sets the flat address of the branch office as it was written, city and
+// postal code included, along with address, the structured address parsed from it.
+func (m *BranchOffice) SetFlatAddress(flat string, address BranchOfficeAddress) {
+	m.SetAddress(address)
+	m.Address = strings.TrimSpace(flat)
+}
+
+// BranchOfficeAddressFromDto converts a structured address, trimming its parts and
+// filling in the default country.
+func BranchOfficeAddressFromDto(address dto.BranchOfficeAddress) BranchOfficeAddress {
+	res := BranchOfficeAddress{
+		Street:     strings.TrimSpace(address.Street),
+		Rt:         padNeighbourhood(strings.TrimSpace(address.Rt)),
+		Rw:         padNeighbourhood(strings.TrimSpace(address.Rw)),
+		Kelurahan:  strings.TrimSpace(address.Kelurahan),
+		Kecamatan:  strings.TrimSpace(address.Kecamatan),
+		Province:   strings.TrimSpace(address.Province),
+		PostalCode: strings.TrimSpace(address.PostalCode),
+		Country:    strings.ToUpper(strings.TrimSpace(address.Country)),
+	}
+	if res.Country == "" {
+		res.Country = DefaultBranchOfficeCountry
+	}
+	return res
+}
+
+// Patch merges the members of patch present into the address, clearing those that are
+// null.
+func (a BranchOfficeAddress) Patch(patch dto.PatchBranchOfficeAddress) BranchOfficeAddress {
+	res := a.ToDto()
+	for _, member := range []struct {
+		value *string
+		patch dto.PatchString
+	}{
+		{&res.Street, patch.Street},
+		{&res.Rt, patch.Rt},
+		{&res.Rw, patch.Rw},
+		{&res.Kelurahan, patch.Kelurahan},
+		{&res.Kecamatan, patch.Kecamatan},
+		{&res.Province, patch.Province},
+		{&res.PostalCode, patch.PostalCode},
+		{&res.Country, patch.Country},
+	} {
+		if member.patch.Set {
+			*member.value = member.patch.Value
+		}
+	}
+	return BranchOfficeAddressFromDto(*res)
+}
+
+func (a BranchOfficeAddress) ToDto() *dto.BranchOfficeAddress {
+	return &dto.BranchOfficeAddress{
+		Street:     a.Street,
+		Rt:         a.Rt,
+		Rw:         a.Rw,
+		Kelurahan:  a.Kelurahan,
+		Kecamatan:  a.Kecamatan,
+		Province:   a.Province,
+		PostalCode: a.PostalCode,
+		Country:    a.Country,
+	}
+}
@@ -0,0 +1,101 @@
+package models
+
+import "testing"
+
+func TestParseBranchOfficeAddress(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		address string
+		city    string
+		want    BranchOfficeAddress
+	}{
+		{
+			"Full", "Jl. Merdeka No. 1, RT 01/RW 02, Kel. Gambir, Kec. Gambir, Jakarta Pusat 10110", "Jakarta Pusat",
+			BranchOfficeAddress{Street: "Jl. Merdeka No. 1", Rt: "001", Rw: "002", Kelurahan: "Gambir", Kecamatan: "Gambir", PostalCode: "10110"},
+		},
+		{
+			"SpelledOut", "Jl. Raya Bogor Km. 30, RT.003 RW.011, Kelurahan Mekarsari, Kecamatan Cimanggis", "Depok",
+			BranchOfficeAddress{Street: "Jl. Raya Bogor Km. 30", Rt: "003", Rw: "011", Kelurahan: "Mekarsari", Kecamatan: "Cimanggis"},
+		},
+		{
+			"Desa", "Jl. Raya Ciawi, Desa Bendungan, Kec Ciawi, Bogor", "Bogor",
+			BranchOfficeAddress{Street: "Jl. Raya Ciawi", Kelurahan: "Bendungan", Kecamatan: "Ciawi"},
+		},
+		{
+			"NoSpaceAfterDot", "Jl. Braga 10, Kel.Braga, Kec.Sumur Bandung", "Bandung",
+			BranchOfficeAddress{Street: "Jl. Braga 10", Kelurahan: "Braga", Kecamatan: "Sumur Bandung"},
+		},
+		{
+			"StreetStartingLikeDesa", "Desain Raya No. 5, Kecapi Indah Blok B", "Bekasi",
+			BranchOfficeAddress{Street: "Desain Raya No. 5, Kecapi Indah Blok B"},
+		},
+		{
+			"StreetStartingLikeKel", "Kelapa Gading Boulevard, Kelurahan Kelapa Gading Barat", "Jakarta Utara",
+			BranchOfficeAddress{Street: "Kelapa Gading Boulevard", Kelurahan: "Kelapa Gading Barat"},
+		},
+		{
+			"PostalCodeAlone", "Jl. Pemuda 1, 50132", "Semarang",
+			BranchOfficeAddress{Street: "Jl. Pemuda 1", PostalCode: "50132"},
+		},
+		{
+			"CityOnly", "Jl. Pemuda 1, Semarang", "Semarang",
+			BranchOfficeAddress{Street: "Jl. Pemuda 1"},
+		},
+		{
+			"HouseNumberOfFiveDigits", "Jl. Industri Blok 12345", "Cikarang",
+			BranchOfficeAddress{Street: "Jl. Industri Blok 12345"},
+		},
+		{
+			"FiveDigitsAfterAnotherCity", "Jl. Sudirman 1, Bandung 40115", "Jakarta Selatan",
+			BranchOfficeAddress{Street: "Jl. Sudirman 1, Bandung 40115"},
+		},
+		{
+			"FiveDigitsNotLast", "Jl. Gatot Subroto Kav. 12345, Jakarta Selatan", "Jakarta Selatan",
+			BranchOfficeAddress{Street: "Jl. Gatot Subroto Kav. 12345"},
+		},
+		{
+			"SixDigits", "Jl. Pemuda 1, Semarang 501320", "Semarang",
+			BranchOfficeAddress{Street: "Jl. Pemuda 1, Semarang 501320"},
+		},
+		{
+			"RtOnlyInStreet", "Gg. Mawar RT 5 / RW 7, Yogyakarta", "Yogyakarta",
+			BranchOfficeAddress{Street: "Gg. Mawar", Rt: "005", Rw: "007"},
+		},
+		{"Empty", "", "Jakarta", BranchOfficeAddress{}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.want.Country = DefaultBranchOfficeCountry
+			if got := ParseBranchOfficeAddress(tc.address, tc.city); got != tc.want {
+				t.Errorf("ParseBranchOfficeAddress(%q, %q) = %+v, want %+v", tc.address, tc.city, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBranchOfficeAddressString(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		address BranchOfficeAddress
+		want    string
+	}{
+		{"Full", BranchOfficeAddress{Street: "Jl. Merdeka 1", Rt: "001", Rw: "002", Kelurahan: "Gambir", Kecamatan: "Gambir", PostalCode: "10110"}, "Jl. Merdeka 1, RT 001/RW 002, Kel. Gambir, Kec. Gambir"},
+		{"RtOnly", BranchOfficeAddress{Street: "Jl. Merdeka 1", Rt: "001"}, "Jl. Merdeka 1, RT 001"},
+		{"RwOnly", BranchOfficeAddress{Rw: "002", Kecamatan: "Gambir"}, "RW 002, Kec. Gambir"},
+		{"Empty", BranchOfficeAddress{}, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.address.String(); got != tc.want {
+				t.Errorf("String() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSetFlatAddress(t *testing.T) {
+	flat := "  Jl. Merdeka 1, Kel. Gambir, Jakarta Pusat 10110 "
+	var m BranchOffice
+	m.SetFlatAddress(flat, ParseBranchOfficeAddress(flat, "Jakarta Pusat"))
+	if m.Address != "Jl. Merdeka 1, Kel. Gambir, Jakarta Pusat 10110" || m.Street != "Jl. Merdeka 1" || m.Kelurahan != "Gambir" || m.PostalCode != "10110" {
+		t.Errorf("SetFlatAddress = %+v, want the flat address kept and its parts set", m.StructuredAddress())
+	}
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jangkartech/twin-branch-office/pkg/dto"
+)
+
+const (
+	BranchOfficeAuditActionCreate     = "create"
+	BranchOfficeAuditActionUpdate     = "update"
+	BranchOfficeAuditActionSoftDelete = "soft_delete"
+	BranchOfficeAuditActionRestore    = "restore"
+	BranchOfficeAuditActionHardDelete = "hard_delete"
+	BranchOfficeAuditActionTransition = "transition"
+)
+
+type BranchOfficeAudit struct {
+	Id             uint64                    `gorm:"primaryKey;autoIncrement;" json:"id"`
+	BranchOfficeId string                    `gorm:"type:varchar(36);index;" json:"branch_office_id"`
+	Action         string                    `gorm:"type:varchar(20);" json:"action"`
+	Actor          string                    `gorm:"type:varchar(100);" json:"actor"`
+	RequestId      string                    `gorm:"type:varchar(100);" json:"request_id"`
+	Changes        []BranchOfficeAuditChange `gorm:"type:jsonb;serializer:json;" json:"changes"`
+	CreatedAt      time.Time                 `gorm:"default:CURRENT_TIMESTAMP;" json:"created_at"`
+}
+
+// BranchOfficeAuditChange is the value of one field before and after a change. A nil
+// side means the field did not exist then, as on create or hard delete.
+type BranchOfficeAuditChange struct {
+	Field  string  `json:"field"`
+	Before *string `json:"before"`
+	After  *string `json:"after"`
+}
+
+func (m *BranchOfficeAudit) ToDtoResponse() *dto.BranchOfficeAuditResource {
+	changes := make([]*dto.BranchOfficeAuditChangeResource, 0, len(m.Changes))
+	for _, change := range m.Changes {
+		changes = append(changes, &dto.BranchOfficeAuditChangeResource{
+			Field:  change.Field,
+			Before: change.Before,
+			After:  change.After,
+		})
+	}
+	return &dto.BranchOfficeAuditResource{
+		Id:             m.Id,
+		BranchOfficeId: m.BranchOfficeId,
+		Action:         m.Action,
+		Actor:          m.Actor,
+		RequestId:      m.RequestId,
+		Changes:        changes,
+		CreatedAt:      m.CreatedAt.Unix(),
+	}
+}
@@ -0,0 +1,219 @@
+package models
+
+import (
+	"sort"
+	"time"
+	// The zone database is embedded so branch timezones resolve on hosts without one.
+	_ "time/tzdata"
+
+	"github.com/jangkartech/twin-branch-office/pkg/dto"
+)
+
+// DefaultBranchOfficeTimezone is the timezone of branch offices created without one.
+const DefaultBranchOfficeTimezone = "Asia/Jakarta"
+
+// Layouts of the times and dates of opening hours, in the timezone of the branch office.
+const (
+	BranchOfficeClockLayout = "15:04"
+	BranchOfficeDateLayout  = "2006-01-02"
+)
+
+// BranchOfficeHoursInterval is a stretch of a day during which a branch office is open,
+// from Opens up to but excluding Closes, both as HH:MM. Closes may be 24:00 for the end
+// of the day; an interval never runs past midnight.
+type BranchOfficeHoursInterval struct {
+	Opens  string `json:"opens"`
+	Closes string `json:"closes"`
+}
+
+// BranchOfficeOpeningHours is a weekly interval on Weekday, counted from Sunday as 0 like
+// time.Weekday. A day may have several intervals, such as around a lunch break.
+type BranchOfficeOpeningHours struct {
+	Weekday int    `json:"weekday"`
+	Opens   string `json:"opens"`
+	Closes  string `json:"closes"`
+}
+
+// BranchOfficeHoursException replaces the weekly hours on Date with Intervals. An
+// exception without intervals closes the branch office all day, as on a holiday.
+type BranchOfficeHoursException struct {
+	Date      string                      `json:"date"`
+	Name      string                      `json:"name"`
+	Intervals []BranchOfficeHoursInterval `json:"intervals"`
+}
+
+// Location returns the timezone of the branch office, or the default one when it has
+// none that resolves.
+func (m *BranchOffice) Location() *time.Location {
+	if m.Timezone != "" {
+		if res, err := time.LoadLocation(m.Timezone); err == nil {
+			return res
+		}
+	}
+	res, _ := time.LoadLocation(DefaultBranchOfficeTimezone)
+	return res
+}
+
+// HoursOn returns the intervals the branch office keeps on the date of local, sorted,
+// along with the exception that sets them instead of the weekly hours, if any. local is
+// a time in the timezone of the branch office.
+func (m *BranchOffice) HoursOn(local time.Time) ([]BranchOfficeHoursInterval, *BranchOfficeHoursException) {
+	date := local.Format(BranchOfficeDateLayout)
+	for i := range m.Exceptions {
+		if m.Exceptions[i].Date == date {
+			return append([]BranchOfficeHoursInterval{}, m.Exceptions[i].Intervals...), &m.Exceptions[i]
+		}
+	}
+
+	res := []BranchOfficeHoursInterval{}
+	for _, hours := range m.OpeningHours {
+		if hours.Weekday == int(local.Weekday()) {
+			res = append(res, BranchOfficeHoursInterval{Opens: hours.Opens, Closes: hours.Closes})
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Opens < res[j].Opens
+	})
+	return res, nil
+}
+
+// OpenAt tells whether the branch office is open at the instant at: it has to be active
+// and within one of the intervals of that day where it is. Times are compared to the
+// minute, as HH:MM strings, which is also how the GORM repo compares them.
+func (m *BranchOffice) OpenAt(at time.Time) bool {
+	_, ok := m.openInterval(at)
+	return ok
+}
+
+// openInterval returns the interval the branch office is open in at the instant at.
+func (m *BranchOffice) openInterval(at time.Time) (BranchOfficeHoursInterval, bool) {
+	if m.LifecycleStatus != BranchOfficeStatusActive {
+		return BranchOfficeHoursInterval{}, false
+	}
+	local := at.In(m.Location())
+	clock := local.Format(BranchOfficeClockLayout)
+	intervals, _ := m.HoursOn(local)
+	for _, interval := range intervals {
+		if interval.Opens <= clock && clock < interval.Closes {
+			return interval, true
+		}
+	}
+	return BranchOfficeHoursInterval{}, false
+}
+
+// ClosesAfter returns when the branch office closes next after at, when it is open then.
+// Intervals that meet, such as one up to 24:00 and one from 00:00 the next day, count as
+// one.
+func (m *BranchOffice) ClosesAfter(at time.Time) *time.Time {
+	interval, ok := m.openInterval(at)
+	if !ok {
+		return nil
+	}
+	for i := 0; i < 7; i++ {
+		closes := clockOn(at.In(m.Location()), interval.Closes)
+		if !m.OpenAt(closes) {
+			return &closes
+		}
+		at = closes
+		interval, _ = m.openInterval(at)
+	}
+	return nil
+}
+
+// NextOpening returns when the branch office opens next after at, looking ahead days
+// days, or nil when it stays closed that long or is not active.
+func (m *BranchOffice) NextOpening(at time.Time, days int) *time.Time {
+	if m.LifecycleStatus != BranchOfficeStatusActive {
+		return nil
+	}
+	local := at.In(m.Location())
+	for day := 0; day <= days; day++ {
+		date := time.Date(local.Year(), local.Month(), local.Day()+day, 0, 0, 0, 0, local.Location())
+		intervals, _ := m.HoursOn(date)
+		for _, interval := range intervals {
+			opens := clockOn(date, interval.Opens)
+			if opens.After(at) && !m.OpenAt(opens.Add(-time.Minute)) {
+				return &opens
+			}
+		}
+	}
+	return nil
+}
+
+// clockOn returns the time of day clock, as HH:MM, on the date of day, where 24:00 is
+// the start of the next day.
+func clockOn(day time.Time, clock string) time.Time {
+	var hour, minute int
+	if t, err := time.Parse(BranchOfficeClockLayout, clock); err == nil {
+		hour, minute = t.Hour(), t.Minute()
+	} else if clock == "24:00" {
+		hour = 24
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, day.Location())
+}
+
+// OpeningHoursFromDto converts weekly opening hours, sorted by weekday and time.
+func OpeningHoursFromDto(hours []dto.BranchOfficeOpeningHours) []BranchOfficeOpeningHours {
+	res := make([]BranchOfficeOpeningHours, 0, len(hours))
+	for _, item := range hours {
+		res = append(res, BranchOfficeOpeningHours{Weekday: item.Weekday, Opens: item.Opens, Closes: item.Closes})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Weekday != res[j].Weekday {
+			return res[i].Weekday < res[j].Weekday
+		}
+		return res[i].Opens < res[j].Opens
+	})
+	return res
+}
+
+// HoursExceptionsFromDto converts exceptions, sorted by date and their intervals by time.
+func HoursExceptionsFromDto(exceptions []dto.BranchOfficeHoursException) []BranchOfficeHoursException {
+	res := make([]BranchOfficeHoursException, 0, len(exceptions))
+	for _, item := range exceptions {
+		intervals := make([]BranchOfficeHoursInterval, 0, len(item.Intervals))
+		for _, interval := range item.Intervals {
+			intervals = append(intervals, BranchOfficeHoursInterval{Opens: interval.Opens, Closes: interval.Closes})
+		}
+		sort.Slice(intervals, func(i, j int) bool {
+			return intervals[i].Opens < intervals[j].Opens
+		})
+		res = append(res, BranchOfficeHoursException{Date: item.Date, Name: item.Name, Intervals: intervals})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Date < res[j].Date
+	})
+	return res
+}
+
+func OpeningHoursToDto(hours []BranchOfficeOpeningHours) []dto.BranchOfficeOpeningHours {
+	res := make([]dto.BranchOfficeOpeningHours, 0, len(hours))
+	for _, item := range hours {
+		res = append(res, dto.BranchOfficeOpeningHours{Weekday: item.Weekday, Opens: item.Opens, Closes: item.Closes})
+	}
+	return res
+}
+
+func HoursIntervalsToDto(intervals []BranchOfficeHoursInterval) []dto.BranchOfficeHoursInterval {
+	res := make([]dto.BranchOfficeHoursInterval, 0, len(intervals))
+	for _, item := range intervals {
+		res = append(res, dto.BranchOfficeHoursInterval{Opens: item.Opens, Closes: item.Closes})
+	}
+	return res
+}
+
+func (m *BranchOfficeHoursException) ToDtoResponse() *dto.BranchOfficeHoursException {
+	return &dto.BranchOfficeHoursException{
+		Date:      m.Date,
+		Name:      m.Name,
+		Intervals: HoursIntervalsToDto(m.Intervals),
+	}
+}
+
+func HoursExceptionsToDto(exceptions []BranchOfficeHoursException) []dto.BranchOfficeHoursException {
+	res := make([]dto.BranchOfficeHoursException, 0, len(exceptions))
+	for i := range exceptions {
+		res = append(res, *exceptions[i].ToDtoResponse())
+	}
+	return res
+}
@@ -0,0 +1,171 @@
+// Package regions is the reference data of the administrative regions of Indonesia that
+// branch offices are placed in: provinces, regencies and cities, and districts
+// (kecamatan). Regions are identified by their Kemendagri codes, such as 31 for DKI
+// Jakarta, 31.73 for Jakarta Pusat and 31.73.01 for Gambir.
+//
+// The dataset is embedded from regions.json. It holds every province and regency, as
+// Kemendagri lists them, but only the districts added so far; add to it as branch
+// offices open elsewhere.
+package regions
+
+import (
+	_ "embed"
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"github.com/jangkartech/twin-branch-office/pkg/dto"
+)
+
+// Levels of a region.
+const (
+	LevelProvince = "province"
+	LevelRegency  = "regency"
+	LevelDistrict = "district"
+)
+
+// Region is a province, regency or district. Regency names carry their kind, as in
+// "Kota Bogor" and "Kabupaten Bogor".
+type Region struct {
+	Code       string
+	Name       string
+	Level      string
+	ParentCode string
+	Aliases    []string
+}
+
+// fileRegion is a region of regions.json, with the regions below it.
+type fileRegion struct {
+	Code      string       `json:"code"`
+	Name      string       `json:"name"`
+	Aliases   []string     `json:"aliases"`
+	Regencies []fileRegion `json:"regencies"`
+	Districts []fileRegion `json:"districts"`
+}
+
+//go:embed regions.json
+var data []byte
+
+var (
+	provinces []Region
+	byCode    = map[string]Region{}
+	children  = map[string][]Region{}
+	// regenciesByName holds the regencies by nameKey of their names, short names and
+	// aliases.
+	regenciesByName = map[string][]Region{}
+)
+
+func init() {
+	var list []fileRegion
+	if err := json.Unmarshal(data, &list); err != nil {
+		panic("regions: " + err.Error())
+	}
+	for _, province := range list {
+		provinces = append(provinces, add(province, LevelProvince, ""))
+		for _, regency := range province.Regencies {
+			res := add(regency, LevelRegency, province.Code)
+			for _, name := range append([]string{res.Name, shortName(res.Name)}, res.Aliases...) {
+				if key := nameKey(name); !slices.ContainsFunc(regenciesByName[key], func(r Region) bool { return r.Code == res.Code }) {
+					regenciesByName[key] = append(regenciesByName[key], res)
+				}
+			}
+			for _, district := range regency.Districts {
+				add(district, LevelDistrict, regency.Code)
+			}
+		}
+	}
+}
+
+func add(region fileRegion, level string, parentCode string) Region {
+	if _, ok := byCode[region.Code]; ok {
+		panic("regions: duplicate code " + region.Code)
+	}
+	res := Region{Code: region.Code, Name: region.Name, Level: level, ParentCode: parentCode, Aliases: region.Aliases}
+	byCode[res.Code] = res
+	if parentCode != "" {
+		children[parentCode] = append(children[parentCode], res)
+	}
+	return res
+}
+
+// regencyPrefixes are the kinds a regency name starts with, longest first.
+var regencyPrefixes = []string{"kabupaten administrasi ", "kota administrasi ", "kabupaten ", "kab. ", "kab ", "kota "}
+
+// shortName drops the kind from the name of a regency, so "Kota Bandung" and
+// "Kabupaten Bandung" are both "Bandung".
+func shortName(name string) string {
+	for _, prefix := range regencyPrefixes {
+		if len(name) > len(prefix) && strings.EqualFold(name[:len(prefix)], prefix) {
+			return name[len(prefix):]
+		}
+	}
+	return name
+}
+
+// nameKey is name in lower case with runs of whitespace collapsed.
+func nameKey(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), " ")
+}
+
+// Provinces returns every province, ordered by code.
+func Provinces() []Region {
+	return provinces
+}
+
+// Lookup returns the region with the given code.
+func Lookup(code string) (Region, bool) {
+	res, ok := byCode[code]
+	return res, ok
+}
+
+// IsRegency tells whether code is the code of a known regency or city.
+func IsRegency(code string) bool {
+	res, ok := byCode[code]
+	return ok && res.Level == LevelRegency
+}
+
+// Children returns the regions directly below the region with the given code, ordered
+// by code, and whether that region is known at all.
+func Children(code string) ([]Region, bool) {
+	if _, ok := byCode[code]; !ok {
+		return nil, false
+	}
+	return children[code], true
+}
+
+// MatchRegencies returns the regencies a free-text city name may stand for. Names match
+// ignoring case and whitespace, with or without their kind, and by the aliases of the
+// dataset, so "Bandung" matches both Kota Bandung and Kabupaten Bandung while
+// "Kab. Bandung" matches only the latter.
+func MatchRegencies(city string) []Region {
+	key := nameKey(city)
+	if res, ok := regenciesByName[key]; ok {
+		return res
+	}
+	// The dataset spells kinds out, while cities are often written "Kab. Sleman".
+	if short := shortName(key); short != key {
+		var res []Region
+		for _, region := range regenciesByName[short] {
+			if strings.HasPrefix(nameKey(region.Name), "kab") == strings.HasPrefix(key, "kab") {
+				res = append(res, region)
+			}
+		}
+		return res
+	}
+	return nil
+}
+
+// CityMatchesRegency tells whether city may stand for the regency with the given code,
+// as MatchRegencies matches them.
+func CityMatchesRegency(city string, code string) bool {
+	return slices.ContainsFunc(MatchRegencies(city), func(r Region) bool { return r.Code == code })
+}
+
+func (r Region) ToDtoResponse() *dto.RegionResource {
+	return &dto.RegionResource{
+		Code:       r.Code,
+		Name:       r.Name,
+		Level:      r.Level,
+		ParentCode: r.ParentCode,
+	}
+}
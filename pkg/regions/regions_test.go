@@ -0,0 +1,78 @@
+package regions
+
+import "testing"
+
+func TestMatchRegencies(t *testing.T) {
+	tests := []struct {
+		city string
+		want []string
+	}{
+		{"Kota Bandung", []string{"32.73"}},
+		{"Kab. Bandung", []string{"32.04"}},
+		{"  bandung ", []string{"32.04", "32.73"}},
+		{"Jakarta Pusat", []string{"31.73"}},
+		{"Jaksel", []string{"31.71"}},
+		{"DKI Jakarta", []string{"31.71", "31.72", "31.73", "31.74", "31.75"}},
+		{"Jkt", []string{"31.71", "31.72", "31.73", "31.74", "31.75"}},
+		{"Solo", []string{"33.72"}},
+		{"Bau-Bau", []string{"74.72"}},
+		{"Kabupaten Sorong", []string{"96.01"}},
+		{"Atlantis", nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, region := range MatchRegencies(tt.city) {
+			got = append(got, region.Code)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("MatchRegencies(%q) = %v, want %v", tt.city, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("MatchRegencies(%q) = %v, want %v", tt.city, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestRegencies(t *testing.T) {
+	var regencies, cities int
+	for _, province := range Provinces() {
+		list, _ := Children(province.Code)
+		if len(list) == 0 {
+			t.Errorf("province %s %s has no regencies", province.Code, province.Name)
+		}
+		for _, regency := range list {
+			if regency.Name[:4] == "Kota" {
+				cities++
+			} else {
+				regencies++
+			}
+		}
+	}
+	if regencies != 416 || cities != 98 {
+		t.Errorf("dataset has %d regencies and %d cities, want 416 and 98", regencies, cities)
+	}
+}
+
+func TestCityMatchesRegency(t *testing.T) {
+	tests := []struct {
+		city string
+		code string
+		want bool
+	}{
+		{"Bandung", "32.73", true},
+		{"Bandung", "32.04", true},
+		{"Kota Bandung", "32.04", false},
+		{"Jakarta", "31.73", true},
+		{"Jakarta Pusat", "32.73", false},
+		{"Atlantis", "32.73", false},
+	}
+	for _, tt := range tests {
+		if got := CityMatchesRegency(tt.city, tt.code); got != tt.want {
+			t.Errorf("CityMatchesRegency(%q, %q) = %v, want %v", tt.city, tt.code, got, tt.want)
+		}
+	}
+}
@@ -2,65 +2,458 @@ package repos
 
 import (
 	"context"
+	stdErrors "errors"
 	"fmt"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jangkartech/twin-branch-office/pkg/geo"
 	"github.com/jangkartech/twin-branch-office/pkg/models"
 	"github.com/jangkartech/twin-util/pkg/constant"
-	"github.com/jangkartech/twin-util/pkg/db"
+	"github.com/jangkartech/twin-util/pkg/errors"
 	"github.com/jangkartech/twin-util/pkg/util"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type BranchOfficeRepoInterface interface {
 	GetBranchOfficeList(ctx context.Context, filter GetBranchOfficeListFilter) ([]*models.BranchOffice, error)
+	StreamBranchOfficeList(ctx context.Context, filter GetBranchOfficeListFilter, fn func(branchOffice *models.BranchOffice) error) error
+	// GetNearbyBranchOfficeList returns the branch offices matching filter that have
+	// coordinates, nearest to near first, ignoring the pagination and sort of filter
+	// apart from its limit.
+	GetNearbyBranchOfficeList(ctx context.Context, filter GetBranchOfficeListFilter, near Near) ([]*BranchOfficeDistance, error)
 	CreateBranchOffice(ctx context.Context, BranchOffice models.BranchOffice) (*models.BranchOffice, error)
-	UpdateBranchOfficeById(ctx context.Context, id string, BranchOffice models.BranchOffice) (*models.BranchOffice, error)
-	SoftDeleteBranchOfficeById(ctx context.Context, id string) error
+	// UpdateBranchOfficeById writes the given columns of BranchOffice, zero values
+	// included, and bumps updated_at.
+	UpdateBranchOfficeById(ctx context.Context, id string, BranchOffice models.BranchOffice, columns []string) (*models.BranchOffice, error)
+	// SoftDeleteBranchOfficeById trashes a live branch office and bumps its version, so
+	// an entity tag taken before no longer matches. Soft deletes and restores, one by one
+	// or in bulk, all bump the version.
+	SoftDeleteBranchOfficeById(ctx context.Context, id string, deletedBy string) error
 	HardDeleteBranchOfficeById(ctx context.Context, id string) error
-	RestoreBranchOfficeById(ctx context.Context, id string) error
+	// RestoreBranchOfficeById brings a soft-deleted branch office back, updated at now
+	// by restoredBy. A non-empty name renames it in the same statement, so the name index
+	// only ever sees the new name.
+	RestoreBranchOfficeById(ctx context.Context, id string, name string, restoredBy string) error
+	SoftDeleteBranchOfficesByIds(ctx context.Context, ids []string, deletedBy string) (int64, error)
+	HardDeleteBranchOfficesByIds(ctx context.Context, ids []string) (int64, error)
+	RestoreBranchOfficesByIds(ctx context.Context, ids []string, restoredBy string) (int64, error)
 	GetBranchOfficeById(ctx context.Context, id string, withTrash bool) (*models.BranchOffice, error)
+	LockBranchOfficeById(ctx context.Context, id string, withTrash bool) (*models.BranchOffice, error)
+	LockBranchOfficeList(ctx context.Context, filter GetBranchOfficeListFilter) ([]*models.BranchOffice, error)
 	GetBranchOfficeByField(ctx context.Context, field string, value string, withTrash bool) (*models.BranchOffice, error)
+	GetBranchOfficesByField(ctx context.Context, field string, values []string, withTrash bool) ([]*models.BranchOffice, error)
 	GetBranchOfficeCount(ctx context.Context, filter GetBranchOfficeListFilter) (int64, error)
+	// NextBranchOfficeCodeSequence increments the code sequence of scope, starting it at
+	// 1, and returns its new value. It commits at once, outside the unit of work of the
+	// caller, so concurrent creates in a scope only wait for each other that long. A
+	// create that aborts leaves a gap in the codes of its scope.
+	NextBranchOfficeCodeSequence(ctx context.Context, scope string) (int64, error)
+	// LockBranchOfficeServiceAreas takes a lock on the service areas of every branch
+	// office until the unit of work of ctx ends, so that two writes cannot both find an
+	// area free and then both take it.
+	LockBranchOfficeServiceAreas(ctx context.Context) error
+	// ClaimBranchOfficesForGeocoding takes up to limit live branch offices pending
+	// geocoding whose attempt is due at now, in id order, and puts off their next attempt
+	// until until, so that other workers leave them alone until then. Rows another
+	// transaction holds are skipped rather than waited for. Callers run it in a unit of
+	// work of its own, so the claim is seen once it returns.
+	ClaimBranchOfficesForGeocoding(ctx context.Context, now time.Time, until time.Time, limit int) ([]*models.BranchOffice, error)
 }
 
-type branchOfficeRepo struct{}
+type branchOfficeRepo struct {
+	db *gorm.DB
+}
 
 type GetBranchOfficeListFilter struct {
-	Fields  *[]string
-	Keyword *string
-	Limit   *int
-	Page    *int
-	Status  *string
+	Fields             *[]string
+	Keyword            *string
+	Limit              *int
+	Page               *int
+	Status             *string
+	WithTrash          bool
+	CreatedBy          *string
+	UpdatedBy          *string
+	DeletedBy          *string
+	Sort               []SortField
+	Cities             []string
+	CityCodes          []string
+	Provinces          []string
+	AddressPostalCodes []string
+	Codes              []string
+	LifecycleStatuses  []string
+	Ids                []string
+	CreatedFrom        *time.Time
+	CreatedTo          *time.Time
+	HasFax             *bool
+	HasCode            *bool
+	HasStreet          *bool
+	HasServiceArea     *bool
+	PostalCode         *string // one of the postal codes served, unlike AddressPostalCodes
+	Serving            *Serving
+	GeocodeStatuses    []string
+	GeocodeDueAt       *time.Time
+	OpenAt             *time.Time
+	Keyset             *Keyset
+	Select             []string
 }
 
-func NewBranchOfficeRepo() BranchOfficeRepoInterface {
-	return &branchOfficeRepo{}
+// Serving limits a list to the branch offices that serve one of PostalCodes, or whose
+// service area has bounds meeting Bounds. They are the candidates for serving part of
+// the same postal codes and area, which still has to be checked on their areas.
+type Serving struct {
+	PostalCodes []string
+	Bounds      *geo.Box
 }
 
-func (r *branchOfficeRepo) GetBranchOfficeList(ctx context.Context, filter GetBranchOfficeListFilter) ([]*models.BranchOffice, error) {
-	var list []*models.BranchOffice
-	res := db.DB.Model(&models.BranchOffice{})
+// Near is the point a nearby search measures distances from. RadiusKm, when set, leaves
+// out the branch offices farther away than that.
+type Near struct {
+	Point    geo.Point
+	RadiusKm *float64
+}
+
+// BranchOfficeDistance is a branch office found by a nearby search, with its distance
+// from the point of the search.
+type BranchOfficeDistance struct {
+	models.BranchOffice
+	DistanceKm float64
+}
+
+// SortField orders a list by Column, descending when Desc is set.
+type SortField struct {
+	Column string
+	Desc   bool
+}
+
+// Keyset limits a list to the rows following Row in sort order, or the rows preceding
+// it when Before is set. Row only needs the sort columns and the id. The result is
+// always returned in sort order.
+type Keyset struct {
+	Row    *models.BranchOffice
+	Before bool
+}
+
+// branchOfficeSortValue returns the value of a sortable column of m.
+func branchOfficeSortValue(m *models.BranchOffice, column string) interface{} {
+	switch column {
+	case "created_at":
+		return m.CreatedAt
+	case "updated_at":
+		return m.UpdatedAt
+	}
+	return branchOfficeColumn(m, column)
+}
+
+// branchOfficeSelectColumns adds the id and the sort columns to the selected ones, as
+// keyset cursors are built from them. The address_detail field stands for the columns
+// of the structured address.
+func branchOfficeSelectColumns(columns []string, sort []SortField) []string {
+	res := []string{"id"}
+	for _, column := range columns {
+		if column == "address_detail" {
+			res = append(res, models.BranchOfficeAddressColumns...)
+		} else {
+			res = append(res, column)
+		}
+	}
+	for _, field := range sort {
+		res = append(res, field.Column)
+	}
+	slices.Sort(res)
+	return slices.Compact(res)
+}
+
+// branchOfficeSortExpression is the expression ordering by a sortable column. The text
+// columns may hold NULL in rows older than them, which orders and compares as empty.
+func branchOfficeSortExpression(column string) string {
+	switch column {
+	case "id", "created_at", "updated_at":
+		return column
+	}
+	return fmt.Sprintf("COALESCE(%s, '')", column)
+}
+
+// applyKeyset narrows query to the rows after, or before, keyset in the order given by
+// sort followed by the id.
+func applyKeyset(query *gorm.DB, sort []SortField, keyset *Keyset) {
+	columns := append(append([]SortField{}, sort...), SortField{Column: "id"})
+
+	var conditions []string
+	var args []interface{}
+	for i, field := range columns {
+		var parts []string
+		for _, previous := range columns[:i] {
+			parts = append(parts, fmt.Sprintf("%s = ?", branchOfficeSortExpression(previous.Column)))
+			args = append(args, branchOfficeSortValue(keyset.Row, previous.Column))
+		}
+		operator := ">"
+		if field.Desc != keyset.Before {
+			operator = "<"
+		}
+		parts = append(parts, fmt.Sprintf("%s %s ?", branchOfficeSortExpression(field.Column), operator))
+		args = append(args, branchOfficeSortValue(keyset.Row, field.Column))
+		conditions = append(conditions, "("+strings.Join(parts, " AND ")+")")
+	}
+	query.Where("("+strings.Join(conditions, " OR ")+")", args...)
+}
+
+func NewBranchOfficeRepo(db *gorm.DB) BranchOfficeRepoInterface {
+	return &branchOfficeRepo{
+		db: db,
+	}
+}
 
+// applyListFilter narrows query to the branch offices matching filter, so the list and
+// its count always agree.
+func (r *branchOfficeRepo) applyListFilter(ctx context.Context, query *gorm.DB, filter GetBranchOfficeListFilter) {
 	if filter.Fields != nil && filter.Keyword != nil {
 		if len(*filter.Fields) > 0 && *filter.Keyword != "" {
-			subQuery := db.DB
+			subQuery := conn(ctx, r.db)
 			for _, field := range *filter.Fields {
-				subQuery = res.Or(fmt.Sprintf("%s ILIKE ?", field), "%"+*filter.Keyword+"%")
+				subQuery = subQuery.Or(fmt.Sprintf("%s ILIKE ?", field), "%"+*filter.Keyword+"%")
 			}
-			res.Where(subQuery)
+			query.Where(subQuery)
 		}
 	}
 
 	if filter.Status != nil {
 		if *filter.Status == constant.StatusDeleted {
-			res.Unscoped().Where("deleted_at is not null")
+			query.Unscoped().Where("deleted_at is not null")
 		}
+	} else if filter.WithTrash {
+		query.Unscoped()
+	}
+
+	if filter.CreatedBy != nil {
+		query.Where("created_by = ?", *filter.CreatedBy)
+	}
+	if filter.UpdatedBy != nil {
+		query.Where("updated_by = ?", *filter.UpdatedBy)
+	}
+	if filter.DeletedBy != nil {
+		query.Where("deleted_by = ?", *filter.DeletedBy)
 	}
 
+	if len(filter.Cities) > 0 {
+		query.Where("city IN ?", filter.Cities)
+	}
+	if len(filter.CityCodes) > 0 {
+		query.Where("city_code IN ?", filter.CityCodes)
+	}
+	if len(filter.Provinces) > 0 {
+		query.Where("province IN ?", filter.Provinces)
+	}
+	if len(filter.AddressPostalCodes) > 0 {
+		query.Where("postal_code IN ?", filter.AddressPostalCodes)
+	}
+	if len(filter.Codes) > 0 {
+		query.Where("code IN ?", filter.Codes)
+	}
+	if len(filter.LifecycleStatuses) > 0 {
+		query.Where("lifecycle_status IN ?", filter.LifecycleStatuses)
+	}
+	if len(filter.Ids) > 0 {
+		query.Where("id IN ?", filter.Ids)
+	}
+	if filter.CreatedFrom != nil {
+		query.Where("created_at >= ?", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		query.Where("created_at <= ?", *filter.CreatedTo)
+	}
+	if filter.HasFax != nil {
+		if *filter.HasFax {
+			query.Where("COALESCE(fax_number, '') <> ''")
+		} else {
+			query.Where("COALESCE(fax_number, '') = ''")
+		}
+	}
+	if filter.HasCode != nil {
+		// Branch offices stored before codes existed hold NULL.
+		if *filter.HasCode {
+			query.Where("COALESCE(code, '') <> ''")
+		} else {
+			query.Where("COALESCE(code, '') = ''")
+		}
+	}
+	if filter.HasStreet != nil {
+		if *filter.HasStreet {
+			query.Where("street <> ''")
+		} else {
+			query.Where("street = ''")
+		}
+	}
+	if filter.HasServiceArea != nil {
+		if *filter.HasServiceArea {
+			query.Where("jsonb_typeof(service_area) = 'object'")
+		} else {
+			query.Where("COALESCE(jsonb_typeof(service_area), 'null') <> 'object'")
+		}
+	}
+	if filter.PostalCode != nil {
+		// Containment of a one-element array can use the GIN index on postal_codes.
+		query.Where("postal_codes @> jsonb_build_array(CAST(? AS text))", *filter.PostalCode)
+	}
+	if len(filter.GeocodeStatuses) > 0 {
+		query.Where("geocode_status IN ?", filter.GeocodeStatuses)
+	}
+	if filter.GeocodeDueAt != nil {
+		query.Where("(geocode_next_attempt_at IS NULL OR geocode_next_attempt_at <= ?)", *filter.GeocodeDueAt)
+	}
+	if filter.Serving != nil {
+		var conditions []string
+		var args []interface{}
+		for _, code := range filter.Serving.PostalCodes {
+			conditions = append(conditions, "postal_codes @> jsonb_build_array(CAST(? AS text))")
+			args = append(args, code)
+		}
+		if box := filter.Serving.Bounds; box != nil {
+			conditions = append(conditions, "(service_area_min_lng <= ? AND service_area_max_lng >= ? AND service_area_min_lat <= ? AND service_area_max_lat >= ?)")
+			args = append(args, box.MaxLng, box.MinLng, box.MaxLat, box.MinLat)
+		}
+		if len(conditions) == 0 {
+			query.Where("FALSE")
+		} else {
+			query.Where("("+strings.Join(conditions, " OR ")+")", args...)
+		}
+	}
+	if filter.OpenAt != nil {
+		query.Where("lifecycle_status = ?", models.BranchOfficeStatusActive)
+		query.Where(branchOfficeOpenAtSQL, map[string]interface{}{"at": *filter.OpenAt})
+	}
+}
+
+// jsonbArray reads the jsonb value of expr as an array, taking null for an empty one.
+func jsonbArray(expr string) string {
+	return fmt.Sprintf("(CASE WHEN jsonb_typeof(%s) = 'array' THEN %s ELSE '[]'::jsonb END)", expr, expr)
+}
+
+// branchOfficeOpenAtSQL holds for the rows whose hours on the local date of @at, taken
+// from the exception of that date when there is one and from the weekly hours otherwise,
+// have an interval around the local time of @at, as models.BranchOffice.OpenAt says.
+var branchOfficeOpenAtSQL = strings.NewReplacer(
+	"{local}", "(CAST(@at AS timestamptz) AT TIME ZONE timezone)",
+	"{exceptions}", jsonbArray("exceptions"),
+	"{opening_hours}", jsonbArray("opening_hours"),
+	"{intervals}", jsonbArray("e->'intervals'"),
+).Replace(`CASE
+	WHEN EXISTS (SELECT 1 FROM jsonb_array_elements({exceptions}) e WHERE e->>'date' = to_char({local}, 'YYYY-MM-DD'))
+	THEN EXISTS (
+		SELECT 1 FROM jsonb_array_elements({exceptions}) e, jsonb_array_elements({intervals}) i
+		WHERE e->>'date' = to_char({local}, 'YYYY-MM-DD')
+		AND i->>'opens' <= to_char({local}, 'HH24:MI') AND to_char({local}, 'HH24:MI') < i->>'closes'
+	)
+	ELSE EXISTS (
+		SELECT 1 FROM jsonb_array_elements({opening_hours}) i
+		WHERE (i->>'weekday')::int = EXTRACT(DOW FROM {local})
+		AND i->>'opens' <= to_char({local}, 'HH24:MI') AND to_char({local}, 'HH24:MI') < i->>'closes'
+	)
+END`)
+
+// listQuery builds the query of a list, which returns the rows in reverse order when
+// reverse is set.
+func (r *branchOfficeRepo) listQuery(ctx context.Context, filter GetBranchOfficeListFilter) (res *gorm.DB, reverse bool) {
+	res = conn(ctx, r.db).Model(&models.BranchOffice{})
+	r.applyListFilter(ctx, res, filter)
+
 	if filter.Limit != nil && filter.Page != nil {
 		util.Paginate(res, *filter.Limit, *filter.Page)
+	} else if filter.Limit != nil {
+		res.Limit(*filter.Limit)
 	}
 
-	if err := res.Order("name ASC").Find(&list).Error; err != nil {
+	sort := filter.Sort
+	if len(sort) == 0 {
+		sort = []SortField{{Column: "name"}}
+	}
+	if len(filter.Select) > 0 {
+		res.Select(branchOfficeSelectColumns(filter.Select, sort))
+	}
+	// Rows before a keyset are read in reverse so the limit keeps the closest ones.
+	reverse = filter.Keyset != nil && filter.Keyset.Before
+	if filter.Keyset != nil {
+		applyKeyset(res, sort, filter.Keyset)
+	}
+	for _, field := range sort {
+		res.Order(clause.OrderByColumn{Column: clause.Column{Name: branchOfficeSortExpression(field.Column), Raw: true}, Desc: field.Desc != reverse})
+	}
+	// The id breaks ties so pages stay stable between requests.
+	res.Order(clause.OrderByColumn{Column: clause.Column{Name: "id"}, Desc: reverse})
+	return res, reverse
+}
+
+func (r *branchOfficeRepo) GetBranchOfficeList(ctx context.Context, filter GetBranchOfficeListFilter) ([]*models.BranchOffice, error) {
+	var list []*models.BranchOffice
+	res, reverse := r.listQuery(ctx, filter)
+	if err := res.Find(&list).Error; err != nil {
+		return nil, err
+	}
+	if reverse {
+		slices.Reverse(list)
+	}
+	return list, nil
+}
+
+// StreamBranchOfficeList calls fn with each branch office of the list, in order, reading
+// them one at a time from the database cursor so large lists are never held in memory.
+// It stops at the first error fn returns.
+func (r *branchOfficeRepo) StreamBranchOfficeList(ctx context.Context, filter GetBranchOfficeListFilter, fn func(branchOffice *models.BranchOffice) error) error {
+	res, _ := r.listQuery(ctx, filter)
+	rows, err := res.Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var BranchOffice models.BranchOffice
+		if err := res.ScanRows(rows, &BranchOffice); err != nil {
+			return err
+		}
+		if err := fn(&BranchOffice); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// branchOfficeDistanceSQL computes geo.DistanceKm from the point @lat, @lng to the
+// coordinates of a row, so plain Postgres can search by distance without PostGIS.
+var branchOfficeDistanceSQL = fmt.Sprintf(`(2 * %v * ASIN(SQRT(LEAST(1,
+	POWER(SIN(RADIANS(latitude - @lat) / 2), 2) +
+	COS(RADIANS(@lat)) * COS(RADIANS(latitude)) * POWER(SIN(RADIANS(longitude - @lng) / 2), 2)
+))))`, geo.EarthRadiusKm)
+
+func (r *branchOfficeRepo) GetNearbyBranchOfficeList(ctx context.Context, filter GetBranchOfficeListFilter, near Near) ([]*BranchOfficeDistance, error) {
+	point := map[string]interface{}{"lat": near.Point.Lat, "lng": near.Point.Lng}
+	res := conn(ctx, r.db).Model(&models.BranchOffice{}).Select("*, "+branchOfficeDistanceSQL+" AS distance_km", point)
+	r.applyListFilter(ctx, res, filter)
+	res.Where("latitude IS NOT NULL AND longitude IS NOT NULL")
+
+	if near.RadiusKm != nil {
+		// The bounding box lets the location index rule out most rows before their
+		// distance is computed.
+		box := geo.BoundingBox(near.Point, *near.RadiusKm)
+		res.Where("latitude BETWEEN ? AND ?", box.MinLat, box.MaxLat)
+		switch {
+		case box.AllLng:
+		case box.MinLng <= box.MaxLng:
+			res.Where("longitude BETWEEN ? AND ?", box.MinLng, box.MaxLng)
+		default:
+			res.Where("(longitude >= ? OR longitude <= ?)", box.MinLng, box.MaxLng)
+		}
+		res.Where(branchOfficeDistanceSQL+" <= @radius", map[string]interface{}{"lat": near.Point.Lat, "lng": near.Point.Lng, "radius": *near.RadiusKm})
+	}
+	if filter.Limit != nil {
+		res.Limit(*filter.Limit)
+	}
+
+	var list []*BranchOfficeDistance
+	if err := res.Order("distance_km").Order("id").Find(&list).Error; err != nil {
 		return nil, err
 	}
 	return list, nil
@@ -68,7 +461,21 @@ func (r *branchOfficeRepo) GetBranchOfficeList(ctx context.Context, filter GetBr
 
 func (r *branchOfficeRepo) GetBranchOfficeById(ctx context.Context, id string, withTrash bool) (*models.BranchOffice, error) {
 	var BranchOffice models.BranchOffice
-	res := db.DB.Model(&models.BranchOffice{}).Where("id = ?", id)
+	res := conn(ctx, r.db).Model(&models.BranchOffice{}).Where("id = ?", id)
+	if withTrash {
+		res.Unscoped()
+	}
+	if err := res.First(&BranchOffice).Error; err != nil {
+		return nil, err
+	}
+	return &BranchOffice, nil
+}
+
+// LockBranchOfficeById loads the branch office with a row lock held until the
+// surrounding transaction ends.
+func (r *branchOfficeRepo) LockBranchOfficeById(ctx context.Context, id string, withTrash bool) (*models.BranchOffice, error) {
+	var BranchOffice models.BranchOffice
+	res := conn(ctx, r.db).Model(&models.BranchOffice{}).Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id)
 	if withTrash {
 		res.Unscoped()
 	}
@@ -78,9 +485,59 @@ func (r *branchOfficeRepo) GetBranchOfficeById(ctx context.Context, id string, w
 	return &BranchOffice, nil
 }
 
+// LockBranchOfficeList loads every branch office matching filter, ignoring its
+// pagination and sort, with row locks held until the surrounding transaction ends. Rows
+// are locked in id order so concurrent bulk writes cannot deadlock each other.
+func (r *branchOfficeRepo) LockBranchOfficeList(ctx context.Context, filter GetBranchOfficeListFilter) ([]*models.BranchOffice, error) {
+	var list []*models.BranchOffice
+	res := conn(ctx, r.db).Model(&models.BranchOffice{}).Clauses(clause.Locking{Strength: "UPDATE"})
+	r.applyListFilter(ctx, res, filter)
+	res.Order("id")
+	if err := res.Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// BranchOfficeLookupKey is the key by which GetBranchOfficeByField and
+// GetBranchOfficesByField match value in field: models.BranchOfficeNameKey for names and
+// the value itself for other fields.
+func BranchOfficeLookupKey(field string, value string) string {
+	if field == "name" {
+		return models.BranchOfficeNameKey(value)
+	}
+	return value
+}
+
+// lookupExpression computes BranchOfficeLookupKey of field in SQL.
+func lookupExpression(field string) string {
+	if field == "name" {
+		return models.BranchOfficeNameKeySQL
+	}
+	return field
+}
+
+// translateBranchOfficeError turns a unique violation of the primary key, the name index
+// or the code index into the validation error the checks before the write would have returned, had
+// a concurrent request not slipped in between.
+func translateBranchOfficeError(err error) error {
+	var pgErr *pgconn.PgError
+	if stdErrors.As(err, &pgErr) && pgErr.Code == "23505" {
+		switch pgErr.ConstraintName {
+		case models.BranchOfficeNameIndex:
+			return &errors.DBValidationError{Field: "name", Tag: "exists"}
+		case models.BranchOfficeCodeIndex:
+			return &errors.DBValidationError{Field: "code", Tag: "exists"}
+		case "branch_offices_pkey":
+			return &errors.DBValidationError{Field: "id", Tag: "exists"}
+		}
+	}
+	return err
+}
+
 func (r *branchOfficeRepo) GetBranchOfficeByField(ctx context.Context, field string, value string, withTrash bool) (*models.BranchOffice, error) {
 	var BranchOffice models.BranchOffice
-	res := db.DB.Model(&models.BranchOffice{}).Where(fmt.Sprintf("%s = ?", field), value)
+	res := conn(ctx, r.db).Model(&models.BranchOffice{}).Where(fmt.Sprintf("%s = ?", lookupExpression(field)), BranchOfficeLookupKey(field, value))
 	if withTrash {
 		res.Unscoped()
 	}
@@ -90,25 +547,60 @@ func (r *branchOfficeRepo) GetBranchOfficeByField(ctx context.Context, field str
 	return &BranchOffice, nil
 }
 
+// GetBranchOfficesByField returns the branch offices whose field holds any of values,
+// matched by BranchOfficeLookupKey, in no particular order.
+func (r *branchOfficeRepo) GetBranchOfficesByField(ctx context.Context, field string, values []string, withTrash bool) ([]*models.BranchOffice, error) {
+	var BranchOffices []*models.BranchOffice
+	keys := make([]string, len(values))
+	for i, value := range values {
+		keys[i] = BranchOfficeLookupKey(field, value)
+	}
+	res := conn(ctx, r.db).Model(&models.BranchOffice{}).Where(fmt.Sprintf("%s IN ?", lookupExpression(field)), keys)
+	if withTrash {
+		res.Unscoped()
+	}
+	if err := res.Find(&BranchOffices).Error; err != nil {
+		return nil, err
+	}
+	return BranchOffices, nil
+}
+
 func (r *branchOfficeRepo) CreateBranchOffice(ctx context.Context, BranchOffice models.BranchOffice) (*models.BranchOffice, error) {
-	res := db.DB.Create(&BranchOffice)
+	BranchOffice.SetServiceAreaBounds()
+	res := conn(ctx, r.db).Create(&BranchOffice)
 	if err := res.Error; err != nil {
-		return nil, err
+		return nil, translateBranchOfficeError(err)
 	}
 	return &BranchOffice, nil
 }
 
-func (r *branchOfficeRepo) UpdateBranchOfficeById(ctx context.Context, id string, BranchOffice models.BranchOffice) (*models.BranchOffice, error) {
-	res := db.DB.Model(&models.BranchOffice{}).Where("id = ?", id).Updates(&BranchOffice).First(&BranchOffice)
+func (r *branchOfficeRepo) UpdateBranchOfficeById(ctx context.Context, id string, BranchOffice models.BranchOffice, columns []string) (*models.BranchOffice, error) {
+	if slices.Contains(columns, "service_area") {
+		BranchOffice.SetServiceAreaBounds()
+		columns = append(slices.Clip(columns), models.BranchOfficeServiceAreaBoundsColumns...)
+	}
+	res := conn(ctx, r.db).Model(&models.BranchOffice{}).Where("id = ?", id).Select(columns).Updates(&BranchOffice)
 	if res.Error != nil {
-		return nil, res.Error
+		return nil, translateBranchOfficeError(res.Error)
 	}
-	return &BranchOffice, nil
+	// The select would carry over to a chained First, so the row is read separately.
+	var updated models.BranchOffice
+	if err := conn(ctx, r.db).Where("id = ?", id).First(&updated).Error; err != nil {
+		return nil, err
+	}
+	return &updated, nil
 }
 
-func (r *branchOfficeRepo) SoftDeleteBranchOfficeById(ctx context.Context, id string) error {
+func (r *branchOfficeRepo) SoftDeleteBranchOfficeById(ctx context.Context, id string, deletedBy string) error {
 	BranchOffice := &models.BranchOffice{Id: id}
-	res := db.DB.Delete(BranchOffice)
+	res := conn(ctx, r.db).Model(&models.BranchOffice{}).Where("id = ?", id).UpdateColumns(map[string]interface{}{
+		"deleted_by": deletedBy,
+		"version":    gorm.Expr("version + 1"),
+	})
+	if err := res.Error; err != nil {
+		return err
+	}
+	res = conn(ctx, r.db).Delete(BranchOffice)
 	if err := res.Error; err != nil {
 		return err
 	}
@@ -117,43 +609,130 @@ func (r *branchOfficeRepo) SoftDeleteBranchOfficeById(ctx context.Context, id st
 
 func (r *branchOfficeRepo) HardDeleteBranchOfficeById(ctx context.Context, id string) error {
 	BranchOffice := &models.BranchOffice{Id: id}
-	res := db.DB.Unscoped().Delete(BranchOffice)
+	res := conn(ctx, r.db).Unscoped().Delete(BranchOffice)
 	if err := res.Error; err != nil {
 		return err
 	}
 	return nil
 }
 
-func (r *branchOfficeRepo) RestoreBranchOfficeById(ctx context.Context, id string) error {
-	res := db.DB.Model(&models.BranchOffice{}).Unscoped().Where("id = ?", id).Update("deleted_at", nil)
+func (r *branchOfficeRepo) RestoreBranchOfficeById(ctx context.Context, id string, name string, restoredBy string) error {
+	values := map[string]interface{}{
+		"deleted_at": nil,
+		"deleted_by": "",
+		"updated_by": restoredBy,
+		"version":    gorm.Expr("version + 1"),
+	}
+	if name != "" {
+		values["name"] = name
+	}
+	res := conn(ctx, r.db).Model(&models.BranchOffice{}).Unscoped().Where("id = ?", id).Updates(values)
 	if err := res.Error; err != nil {
-		return err
+		return translateBranchOfficeError(err)
 	}
 	return nil
 }
 
-func (r *branchOfficeRepo) GetBranchOfficeCount(ctx context.Context, filter GetBranchOfficeListFilter) (int64, error) {
-	var res int64
+// SoftDeleteBranchOfficesByIds soft deletes the live branch offices among ids and
+// returns how many it deleted.
+func (r *branchOfficeRepo) SoftDeleteBranchOfficesByIds(ctx context.Context, ids []string, deletedBy string) (int64, error) {
+	res := conn(ctx, r.db).Model(&models.BranchOffice{}).Where("id IN ?", ids).UpdateColumns(map[string]interface{}{
+		"deleted_by": deletedBy,
+		"version":    gorm.Expr("version + 1"),
+	})
+	if err := res.Error; err != nil {
+		return 0, err
+	}
+	res = conn(ctx, r.db).Where("id IN ?", ids).Delete(&models.BranchOffice{})
+	if err := res.Error; err != nil {
+		return 0, err
+	}
+	return res.RowsAffected, nil
+}
 
-	query := db.DB.Model(&models.BranchOffice{})
-	if filter.Fields != nil && filter.Keyword != nil {
-		if len(*filter.Fields) > 0 && *filter.Keyword != "" {
-			subQuery := db.DB
-			for _, field := range *filter.Fields {
-				subQuery = query.Or(fmt.Sprintf("%s ILIKE ?", field), "%"+*filter.Keyword+"%")
-			}
-			query.Where(subQuery)
-		}
+// HardDeleteBranchOfficesByIds permanently deletes the branch offices among ids, live or
+// trashed, and returns how many it deleted.
+func (r *branchOfficeRepo) HardDeleteBranchOfficesByIds(ctx context.Context, ids []string) (int64, error) {
+	res := conn(ctx, r.db).Unscoped().Where("id IN ?", ids).Delete(&models.BranchOffice{})
+	if err := res.Error; err != nil {
+		return 0, err
 	}
+	return res.RowsAffected, nil
+}
 
-	if filter.Status != nil {
-		if *filter.Status == constant.StatusDeleted {
-			query.Unscoped().Where("deleted_at is not null")
-		}
+// RestoreBranchOfficesByIds restores the trashed branch offices among ids, updated at
+// now by restoredBy, and returns how many it restored.
+func (r *branchOfficeRepo) RestoreBranchOfficesByIds(ctx context.Context, ids []string, restoredBy string) (int64, error) {
+	res := conn(ctx, r.db).Model(&models.BranchOffice{}).Unscoped().Where("id IN ?", ids).Where("deleted_at is not null").Updates(map[string]interface{}{
+		"deleted_at": nil,
+		"deleted_by": "",
+		"updated_by": restoredBy,
+		"version":    gorm.Expr("version + 1"),
+	})
+	if err := res.Error; err != nil {
+		return 0, translateBranchOfficeError(err)
 	}
+	return res.RowsAffected, nil
+}
+
+func (r *branchOfficeRepo) GetBranchOfficeCount(ctx context.Context, filter GetBranchOfficeListFilter) (int64, error) {
+	var res int64
+
+	query := conn(ctx, r.db).Model(&models.BranchOffice{})
+	r.applyListFilter(ctx, query, filter)
 	err := query.Count(&res).Error
 	if err != nil {
 		return 0, err
 	}
 	return res, nil
 }
+
+func (r *branchOfficeRepo) NextBranchOfficeCodeSequence(ctx context.Context, scope string) (int64, error) {
+	var value int64
+	// The upsert locks the sequence row, so concurrent creates in a scope take turns. It
+	// runs on r.db rather than the transaction of ctx, which would hold the lock until
+	// the whole create commits.
+	res := r.db.WithContext(ctx).Raw(
+		"INSERT INTO branch_office_code_sequences (scope, value) VALUES (?, 1) "+
+			"ON CONFLICT (scope) DO UPDATE SET value = branch_office_code_sequences.value + 1 RETURNING value",
+		scope,
+	).Scan(&value)
+	if err := res.Error; err != nil {
+		return 0, err
+	}
+	return value, nil
+}
+
+// branchOfficeServiceAreaLockKey is the advisory lock LockBranchOfficeServiceAreas takes.
+const branchOfficeServiceAreaLockKey = 0x62725f6172656173
+
+func (r *branchOfficeRepo) LockBranchOfficeServiceAreas(ctx context.Context) error {
+	return conn(ctx, r.db).Exec("SELECT pg_advisory_xact_lock(?)", int64(branchOfficeServiceAreaLockKey)).Error
+}
+
+func (r *branchOfficeRepo) ClaimBranchOfficesForGeocoding(ctx context.Context, now time.Time, until time.Time, limit int) ([]*models.BranchOffice, error) {
+	var list []*models.BranchOffice
+	res := conn(ctx, r.db).Model(&models.BranchOffice{}).Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+	r.applyListFilter(ctx, res, GetBranchOfficeListFilter{
+		GeocodeStatuses: []string{models.BranchOfficeGeocodePending},
+		GeocodeDueAt:    &now,
+	})
+	if err := res.Order("id").Limit(limit).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return list, nil
+	}
+
+	ids := make([]string, 0, len(list))
+	for _, branchOffice := range list {
+		ids = append(ids, branchOffice.Id)
+		branchOffice.GeocodeNextAttemptAt = &until
+	}
+	// A claim is bookkeeping, so it leaves updated_at alone.
+	err := conn(ctx, r.db).Model(&models.BranchOffice{}).Where("id IN ?", ids).UpdateColumn("geocode_next_attempt_at", until).Error
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
@@ -0,0 +1,64 @@
+package repos
+
+import (
+	"context"
+
+	"github.com/jangkartech/twin-branch-office/pkg/models"
+	"github.com/jangkartech/twin-util/pkg/util"
+	"gorm.io/gorm"
+)
+
+type BranchOfficeAuditRepoInterface interface {
+	GetBranchOfficeAuditList(ctx context.Context, filter GetBranchOfficeAuditListFilter) ([]*models.BranchOfficeAudit, error)
+	CreateBranchOfficeAudit(ctx context.Context, BranchOfficeAudit models.BranchOfficeAudit) (*models.BranchOfficeAudit, error)
+	GetBranchOfficeAuditCount(ctx context.Context, filter GetBranchOfficeAuditListFilter) (int64, error)
+}
+
+type branchOfficeAuditRepo struct {
+	db *gorm.DB
+}
+
+type GetBranchOfficeAuditListFilter struct {
+	BranchOfficeId string
+	Limit          *int
+	Page           *int
+}
+
+func NewBranchOfficeAuditRepo(db *gorm.DB) BranchOfficeAuditRepoInterface {
+	return &branchOfficeAuditRepo{
+		db: db,
+	}
+}
+
+func (r *branchOfficeAuditRepo) GetBranchOfficeAuditList(ctx context.Context, filter GetBranchOfficeAuditListFilter) ([]*models.BranchOfficeAudit, error) {
+	var list []*models.BranchOfficeAudit
+	res := conn(ctx, r.db).Model(&models.BranchOfficeAudit{}).Where("branch_office_id = ?", filter.BranchOfficeId)
+
+	if filter.Limit != nil && filter.Page != nil {
+		util.Paginate(res, *filter.Limit, *filter.Page)
+	}
+
+	if err := res.Order("created_at DESC, id DESC").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *branchOfficeAuditRepo) CreateBranchOfficeAudit(ctx context.Context, BranchOfficeAudit models.BranchOfficeAudit) (*models.BranchOfficeAudit, error) {
+	res := conn(ctx, r.db).Create(&BranchOfficeAudit)
+	if err := res.Error; err != nil {
+		return nil, err
+	}
+	return &BranchOfficeAudit, nil
+}
+
+func (r *branchOfficeAuditRepo) GetBranchOfficeAuditCount(ctx context.Context, filter GetBranchOfficeAuditListFilter) (int64, error) {
+	var res int64
+
+	query := conn(ctx, r.db).Model(&models.BranchOfficeAudit{}).Where("branch_office_id = ?", filter.BranchOfficeId)
+	err := query.Count(&res).Error
+	if err != nil {
+		return 0, err
+	}
+	return res, nil
+}
@@ -0,0 +1,59 @@
+package repos
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/jangkartech/twin-branch-office/pkg/models"
+)
+
+type memoryBranchOfficeAuditRepo struct {
+	store *MemoryStore
+}
+
+func NewMemoryBranchOfficeAuditRepo(store *MemoryStore) BranchOfficeAuditRepoInterface {
+	return &memoryBranchOfficeAuditRepo{
+		store: store,
+	}
+}
+
+func (r *memoryBranchOfficeAuditRepo) filter(filter GetBranchOfficeAuditListFilter) []*models.BranchOfficeAudit {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var list []*models.BranchOfficeAudit
+	for _, audit := range r.store.branchOfficeAudits {
+		if audit.BranchOfficeId == filter.BranchOfficeId {
+			item := audit
+			list = append(list, &item)
+		}
+	}
+	return list
+}
+
+func (r *memoryBranchOfficeAuditRepo) GetBranchOfficeAuditList(ctx context.Context, filter GetBranchOfficeAuditListFilter) ([]*models.BranchOfficeAudit, error) {
+	list := r.filter(filter)
+	sort.SliceStable(list, func(i, j int) bool {
+		if !list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].CreatedAt.After(list[j].CreatedAt)
+		}
+		return list[i].Id > list[j].Id
+	})
+	return paginate(list, filter.Limit, filter.Page), nil
+}
+
+func (r *memoryBranchOfficeAuditRepo) CreateBranchOfficeAudit(ctx context.Context, BranchOfficeAudit models.BranchOfficeAudit) (*models.BranchOfficeAudit, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if BranchOfficeAudit.CreatedAt.IsZero() {
+		BranchOfficeAudit.CreatedAt = time.Now()
+	}
+	res := r.store.addBranchOfficeAudit(ctx, BranchOfficeAudit)
+	return &res, nil
+}
+
+func (r *memoryBranchOfficeAuditRepo) GetBranchOfficeAuditCount(ctx context.Context, filter GetBranchOfficeAuditListFilter) (int64, error) {
+	return int64(len(r.filter(filter))), nil
+}
@@ -0,0 +1,638 @@
+package repos
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jangkartech/twin-branch-office/pkg/geo"
+	"github.com/jangkartech/twin-branch-office/pkg/models"
+	"github.com/jangkartech/twin-util/pkg/constant"
+	"github.com/jangkartech/twin-util/pkg/errors"
+	"gorm.io/gorm"
+)
+
+type memoryBranchOfficeRepo struct {
+	store *MemoryStore
+}
+
+// NewMemoryBranchOfficeRepo returns a BranchOfficeRepoInterface kept in store. It follows
+// the semantics of the GORM repo, including soft deletes and not-found errors.
+func NewMemoryBranchOfficeRepo(store *MemoryStore) BranchOfficeRepoInterface {
+	return &memoryBranchOfficeRepo{
+		store: store,
+	}
+}
+
+// branchOfficeColumn returns the value of the column a filter refers to.
+func branchOfficeColumn(m *models.BranchOffice, column string) string {
+	switch column {
+	case "id":
+		return m.Id
+	case "code":
+		return m.Code
+	case "name":
+		return m.Name
+	case "address":
+		return m.Address
+	case "phone_number":
+		return m.PhoneNumber
+	case "fax_number":
+		return m.FaxNumber
+	case "city":
+		return m.City
+	case "lifecycle_status":
+		return m.LifecycleStatus
+	case "lifecycle_status_reason":
+		return m.LifecycleStatusReason
+	case "timezone":
+		return m.Timezone
+	}
+	return ""
+}
+
+// projectBranchOffice copies only the given columns of m, as a SELECT of them would.
+func projectBranchOffice(m *models.BranchOffice, columns []string) *models.BranchOffice {
+	res := &models.BranchOffice{}
+	copyBranchOfficeColumns(res, m, columns)
+	return res
+}
+
+// copyBranchOfficeColumns copies the given columns of src to dst.
+func copyBranchOfficeColumns(dst *models.BranchOffice, src *models.BranchOffice, columns []string) {
+	for _, column := range columns {
+		switch column {
+		case "id":
+			dst.Id = src.Id
+		case "code":
+			dst.Code = src.Code
+		case "name":
+			dst.Name = src.Name
+		case "address":
+			dst.Address = src.Address
+		case "street":
+			dst.Street = src.Street
+		case "rt":
+			dst.Rt = src.Rt
+		case "rw":
+			dst.Rw = src.Rw
+		case "kelurahan":
+			dst.Kelurahan = src.Kelurahan
+		case "kecamatan":
+			dst.Kecamatan = src.Kecamatan
+		case "province":
+			dst.Province = src.Province
+		case "postal_code":
+			dst.PostalCode = src.PostalCode
+		case "country":
+			dst.Country = src.Country
+		case "phone_number":
+			dst.PhoneNumber = src.PhoneNumber
+		case "fax_number":
+			dst.FaxNumber = src.FaxNumber
+		case "city":
+			dst.City = src.City
+		case "city_code":
+			dst.CityCode = src.CityCode
+		case "latitude":
+			dst.Latitude = src.Latitude
+		case "longitude":
+			dst.Longitude = src.Longitude
+		case "service_area":
+			dst.ServiceArea = src.ServiceArea
+			dst.SetServiceAreaBounds()
+		case "postal_codes":
+			dst.PostalCodes = src.PostalCodes
+		case "geocode_status":
+			dst.GeocodeStatus = src.GeocodeStatus
+		case "geocoded_address":
+			dst.GeocodedAddress = src.GeocodedAddress
+		case "geocode_attempts":
+			dst.GeocodeAttempts = src.GeocodeAttempts
+		case "geocode_next_attempt_at":
+			dst.GeocodeNextAttemptAt = src.GeocodeNextAttemptAt
+		case "lifecycle_status":
+			dst.LifecycleStatus = src.LifecycleStatus
+		case "lifecycle_status_reason":
+			dst.LifecycleStatusReason = src.LifecycleStatusReason
+		case "lifecycle_status_effective_at":
+			dst.LifecycleStatusEffectiveAt = src.LifecycleStatusEffectiveAt
+		case "timezone":
+			dst.Timezone = src.Timezone
+		case "opening_hours":
+			dst.OpeningHours = src.OpeningHours
+		case "exceptions":
+			dst.Exceptions = src.Exceptions
+		case "version":
+			dst.Version = src.Version
+		case "created_at":
+			dst.CreatedAt = src.CreatedAt
+		case "created_by":
+			dst.CreatedBy = src.CreatedBy
+		case "updated_at":
+			dst.UpdatedAt = src.UpdatedAt
+		case "updated_by":
+			dst.UpdatedBy = src.UpdatedBy
+		case "deleted_at":
+			dst.DeletedAt = src.DeletedAt
+		case "deleted_by":
+			dst.DeletedBy = src.DeletedBy
+		}
+	}
+}
+
+// compareBranchOffices orders a and b the way the GORM repo orders rows for sort.
+func compareBranchOffices(a *models.BranchOffice, b *models.BranchOffice, sort []SortField) int {
+	for _, field := range sort {
+		var res int
+		switch field.Column {
+		case "created_at":
+			res = a.CreatedAt.Compare(b.CreatedAt)
+		case "updated_at":
+			res = a.UpdatedAt.Compare(b.UpdatedAt)
+		default:
+			res = strings.Compare(branchOfficeColumn(a, field.Column), branchOfficeColumn(b, field.Column))
+		}
+		if field.Desc {
+			res = -res
+		}
+		if res != 0 {
+			return res
+		}
+	}
+	return strings.Compare(a.Id, b.Id)
+}
+
+func (r *memoryBranchOfficeRepo) filter(filter GetBranchOfficeListFilter) []*models.BranchOffice {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	onlyTrashed := filter.Status != nil && *filter.Status == constant.StatusDeleted
+	var keyword string
+	var fields []string
+	if filter.Fields != nil && filter.Keyword != nil {
+		keyword = strings.ToLower(*filter.Keyword)
+		fields = *filter.Fields
+	}
+
+	var list []*models.BranchOffice
+	for _, branchOffice := range r.store.branchOffices {
+		if onlyTrashed && !branchOffice.DeletedAt.Valid {
+			continue
+		}
+		if !onlyTrashed && !filter.WithTrash && branchOffice.DeletedAt.Valid {
+			continue
+		}
+		if filter.CreatedBy != nil && branchOffice.CreatedBy != *filter.CreatedBy {
+			continue
+		}
+		if filter.UpdatedBy != nil && branchOffice.UpdatedBy != *filter.UpdatedBy {
+			continue
+		}
+		if filter.DeletedBy != nil && branchOffice.DeletedBy != *filter.DeletedBy {
+			continue
+		}
+		if len(filter.Cities) > 0 && !slices.Contains(filter.Cities, branchOffice.City) {
+			continue
+		}
+		if len(filter.CityCodes) > 0 && !slices.Contains(filter.CityCodes, branchOffice.CityCode) {
+			continue
+		}
+		if len(filter.Provinces) > 0 && !slices.Contains(filter.Provinces, branchOffice.Province) {
+			continue
+		}
+		if len(filter.AddressPostalCodes) > 0 && !slices.Contains(filter.AddressPostalCodes, branchOffice.PostalCode) {
+			continue
+		}
+		if len(filter.Codes) > 0 && !slices.Contains(filter.Codes, branchOffice.Code) {
+			continue
+		}
+		if len(filter.LifecycleStatuses) > 0 && !slices.Contains(filter.LifecycleStatuses, branchOffice.LifecycleStatus) {
+			continue
+		}
+		if len(filter.Ids) > 0 && !slices.Contains(filter.Ids, branchOffice.Id) {
+			continue
+		}
+		if filter.CreatedFrom != nil && branchOffice.CreatedAt.Before(*filter.CreatedFrom) {
+			continue
+		}
+		if filter.CreatedTo != nil && branchOffice.CreatedAt.After(*filter.CreatedTo) {
+			continue
+		}
+		if filter.HasFax != nil && (branchOffice.FaxNumber != "") != *filter.HasFax {
+			continue
+		}
+		if filter.HasCode != nil && (branchOffice.Code != "") != *filter.HasCode {
+			continue
+		}
+		if filter.HasStreet != nil && (branchOffice.Street != "") != *filter.HasStreet {
+			continue
+		}
+		if filter.HasServiceArea != nil && (len(branchOffice.ServiceArea) > 0) != *filter.HasServiceArea {
+			continue
+		}
+		if filter.PostalCode != nil && !slices.Contains(branchOffice.PostalCodes, *filter.PostalCode) {
+			continue
+		}
+		if filter.Serving != nil && !serves(&branchOffice, filter.Serving) {
+			continue
+		}
+		if len(filter.GeocodeStatuses) > 0 && !slices.Contains(filter.GeocodeStatuses, branchOffice.GeocodeStatus) {
+			continue
+		}
+		if filter.GeocodeDueAt != nil && branchOffice.GeocodeNextAttemptAt != nil && branchOffice.GeocodeNextAttemptAt.After(*filter.GeocodeDueAt) {
+			continue
+		}
+		if filter.OpenAt != nil && !branchOffice.OpenAt(*filter.OpenAt) {
+			continue
+		}
+		if len(fields) > 0 && keyword != "" {
+			matched := false
+			for _, field := range fields {
+				if strings.Contains(strings.ToLower(branchOfficeColumn(&branchOffice, field)), keyword) {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				continue
+			}
+		}
+		item := branchOffice
+		list = append(list, &item)
+	}
+	return list
+}
+
+func (r *memoryBranchOfficeRepo) GetBranchOfficeList(ctx context.Context, filter GetBranchOfficeListFilter) ([]*models.BranchOffice, error) {
+	list := r.filter(filter)
+	order := filter.Sort
+	if len(order) == 0 {
+		order = []SortField{{Column: "name"}}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return compareBranchOffices(list[i], list[j], order) < 0
+	})
+
+	if filter.Keyset != nil {
+		var res []*models.BranchOffice
+		for _, item := range list {
+			position := compareBranchOffices(item, filter.Keyset.Row, order)
+			if (filter.Keyset.Before && position < 0) || (!filter.Keyset.Before && position > 0) {
+				res = append(res, item)
+			}
+		}
+		list = res
+	}
+	if filter.Limit != nil && filter.Page == nil && len(list) > *filter.Limit {
+		if filter.Keyset != nil && filter.Keyset.Before {
+			list = list[len(list)-*filter.Limit:]
+		} else {
+			list = list[:*filter.Limit]
+		}
+	}
+	list = paginate(list, filter.Limit, filter.Page)
+
+	if len(filter.Select) > 0 {
+		columns := branchOfficeSelectColumns(filter.Select, order)
+		for i, item := range list {
+			list[i] = projectBranchOffice(item, columns)
+		}
+	}
+	return list, nil
+}
+
+func (r *memoryBranchOfficeRepo) StreamBranchOfficeList(ctx context.Context, filter GetBranchOfficeListFilter, fn func(branchOffice *models.BranchOffice) error) error {
+	list, err := r.GetBranchOfficeList(ctx, filter)
+	if err != nil {
+		return err
+	}
+	for _, item := range list {
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *memoryBranchOfficeRepo) GetNearbyBranchOfficeList(ctx context.Context, filter GetBranchOfficeListFilter, near Near) ([]*BranchOfficeDistance, error) {
+	var list []*BranchOfficeDistance
+	for _, item := range r.filter(filter) {
+		point, ok := item.Point()
+		if !ok {
+			continue
+		}
+		distance := geo.DistanceKm(near.Point, point)
+		if near.RadiusKm != nil && distance > *near.RadiusKm {
+			continue
+		}
+		list = append(list, &BranchOfficeDistance{BranchOffice: *item, DistanceKm: distance})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].DistanceKm != list[j].DistanceKm {
+			return list[i].DistanceKm < list[j].DistanceKm
+		}
+		return list[i].Id < list[j].Id
+	})
+	if filter.Limit != nil && len(list) > *filter.Limit {
+		list = list[:*filter.Limit]
+	}
+	return list, nil
+}
+
+func (r *memoryBranchOfficeRepo) get(id string, withTrash bool) (*models.BranchOffice, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	branchOffice, ok := r.store.branchOffices[id]
+	if !ok || (branchOffice.DeletedAt.Valid && !withTrash) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &branchOffice, nil
+}
+
+func (r *memoryBranchOfficeRepo) GetBranchOfficeById(ctx context.Context, id string, withTrash bool) (*models.BranchOffice, error) {
+	return r.get(id, withTrash)
+}
+
+func (r *memoryBranchOfficeRepo) LockBranchOfficeById(ctx context.Context, id string, withTrash bool) (*models.BranchOffice, error) {
+	return r.get(id, withTrash)
+}
+
+func (r *memoryBranchOfficeRepo) LockBranchOfficeList(ctx context.Context, filter GetBranchOfficeListFilter) ([]*models.BranchOffice, error) {
+	list := r.filter(filter)
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Id < list[j].Id
+	})
+	return list, nil
+}
+
+func (r *memoryBranchOfficeRepo) GetBranchOfficeByField(ctx context.Context, field string, value string, withTrash bool) (*models.BranchOffice, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	for _, branchOffice := range r.store.branchOffices {
+		if branchOffice.DeletedAt.Valid && !withTrash {
+			continue
+		}
+		if BranchOfficeLookupKey(field, branchOfficeColumn(&branchOffice, field)) == BranchOfficeLookupKey(field, value) {
+			return &branchOffice, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memoryBranchOfficeRepo) GetBranchOfficesByField(ctx context.Context, field string, values []string, withTrash bool) ([]*models.BranchOffice, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	keys := make([]string, len(values))
+	for i, value := range values {
+		keys[i] = BranchOfficeLookupKey(field, value)
+	}
+	var res []*models.BranchOffice
+	for _, branchOffice := range r.store.branchOffices {
+		if branchOffice.DeletedAt.Valid && !withTrash {
+			continue
+		}
+		if slices.Contains(keys, BranchOfficeLookupKey(field, branchOfficeColumn(&branchOffice, field))) {
+			item := branchOffice
+			res = append(res, &item)
+		}
+	}
+	return res, nil
+}
+
+// nameTaken tells whether a live branch office other than id holds name, which the name
+// index of the GORM repo rejects. The caller holds the store lock.
+func (r *memoryBranchOfficeRepo) nameTaken(id string, name string) bool {
+	key := models.BranchOfficeNameKey(name)
+	for _, branchOffice := range r.store.branchOffices {
+		if branchOffice.Id != id && !branchOffice.DeletedAt.Valid && models.BranchOfficeNameKey(branchOffice.Name) == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *memoryBranchOfficeRepo) CreateBranchOffice(ctx context.Context, BranchOffice models.BranchOffice) (*models.BranchOffice, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.branchOffices[BranchOffice.Id]; ok {
+		return nil, &errors.DBValidationError{Field: "id", Tag: "exists"}
+	}
+	if r.nameTaken(BranchOffice.Id, BranchOffice.Name) {
+		return nil, &errors.DBValidationError{Field: "name", Tag: "exists"}
+	}
+	if BranchOffice.Code != "" {
+		for _, branchOffice := range r.store.branchOffices {
+			if branchOffice.Code == BranchOffice.Code {
+				return nil, &errors.DBValidationError{Field: "code", Tag: "exists"}
+			}
+		}
+	}
+	if BranchOffice.Version == 0 {
+		BranchOffice.Version = 1
+	}
+	BranchOffice.SetServiceAreaBounds()
+	if BranchOffice.LifecycleStatus == "" {
+		BranchOffice.LifecycleStatus = models.BranchOfficeStatusActive
+	}
+	if BranchOffice.Timezone == "" {
+		BranchOffice.Timezone = models.DefaultBranchOfficeTimezone
+	}
+	if BranchOffice.GeocodeStatus == "" {
+		BranchOffice.GeocodeStatus = models.BranchOfficeGeocodeOff
+	}
+	if BranchOffice.Country == "" {
+		BranchOffice.Country = models.DefaultBranchOfficeCountry
+	}
+	now := time.Now()
+	if BranchOffice.CreatedAt.IsZero() {
+		BranchOffice.CreatedAt = now
+	}
+	if BranchOffice.UpdatedAt.IsZero() {
+		BranchOffice.UpdatedAt = now
+	}
+	r.store.putBranchOffice(ctx, BranchOffice)
+	return &BranchOffice, nil
+}
+
+func (r *memoryBranchOfficeRepo) UpdateBranchOfficeById(ctx context.Context, id string, BranchOffice models.BranchOffice, columns []string) (*models.BranchOffice, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	current, ok := r.store.branchOffices[id]
+	if !ok || current.DeletedAt.Valid {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	if slices.Contains(columns, "name") && r.nameTaken(id, BranchOffice.Name) {
+		return nil, &errors.DBValidationError{Field: "name", Tag: "exists"}
+	}
+	copyBranchOfficeColumns(&current, &BranchOffice, columns)
+	current.UpdatedAt = time.Now()
+	r.store.putBranchOffice(ctx, current)
+	return &current, nil
+}
+
+func (r *memoryBranchOfficeRepo) SoftDeleteBranchOfficeById(ctx context.Context, id string, deletedBy string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	branchOffice, ok := r.store.branchOffices[id]
+	if ok && !branchOffice.DeletedAt.Valid {
+		branchOffice.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+		branchOffice.DeletedBy = deletedBy
+		branchOffice.Version++
+		r.store.putBranchOffice(ctx, branchOffice)
+	}
+	return nil
+}
+
+func (r *memoryBranchOfficeRepo) HardDeleteBranchOfficeById(ctx context.Context, id string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.deleteBranchOffice(ctx, id)
+	return nil
+}
+
+func (r *memoryBranchOfficeRepo) RestoreBranchOfficeById(ctx context.Context, id string, name string, restoredBy string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if branchOffice, ok := r.store.branchOffices[id]; ok {
+		if name != "" {
+			branchOffice.Name = name
+		}
+		if r.nameTaken(id, branchOffice.Name) {
+			return &errors.DBValidationError{Field: "name", Tag: "exists"}
+		}
+		branchOffice.DeletedAt = gorm.DeletedAt{}
+		branchOffice.DeletedBy = ""
+		branchOffice.UpdatedAt = time.Now()
+		branchOffice.UpdatedBy = restoredBy
+		branchOffice.Version++
+		r.store.putBranchOffice(ctx, branchOffice)
+	}
+	return nil
+}
+
+func (r *memoryBranchOfficeRepo) SoftDeleteBranchOfficesByIds(ctx context.Context, ids []string, deletedBy string) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var res int64
+	now := time.Now()
+	for _, id := range ids {
+		if branchOffice, ok := r.store.branchOffices[id]; ok && !branchOffice.DeletedAt.Valid {
+			branchOffice.DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
+			branchOffice.DeletedBy = deletedBy
+			branchOffice.Version++
+			r.store.putBranchOffice(ctx, branchOffice)
+			res++
+		}
+	}
+	return res, nil
+}
+
+func (r *memoryBranchOfficeRepo) HardDeleteBranchOfficesByIds(ctx context.Context, ids []string) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var res int64
+	for _, id := range ids {
+		if _, ok := r.store.branchOffices[id]; ok {
+			r.store.deleteBranchOffice(ctx, id)
+			res++
+		}
+	}
+	return res, nil
+}
+
+func (r *memoryBranchOfficeRepo) RestoreBranchOfficesByIds(ctx context.Context, ids []string, restoredBy string) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	// The GORM repo restores with a single statement, which the name index fails as a
+	// whole when one of the names is taken, by a live branch office or within ids.
+	restored := map[string]string{}
+	for _, id := range ids {
+		if branchOffice, ok := r.store.branchOffices[id]; ok && branchOffice.DeletedAt.Valid {
+			key := models.BranchOfficeNameKey(branchOffice.Name)
+			if (restored[key] != "" && restored[key] != id) || r.nameTaken(id, branchOffice.Name) {
+				return 0, &errors.DBValidationError{Field: "name", Tag: "exists"}
+			}
+			restored[key] = id
+		}
+	}
+
+	var res int64
+	now := time.Now()
+	for _, id := range ids {
+		if branchOffice, ok := r.store.branchOffices[id]; ok && branchOffice.DeletedAt.Valid {
+			branchOffice.DeletedAt = gorm.DeletedAt{}
+			branchOffice.DeletedBy = ""
+			branchOffice.UpdatedAt = now
+			branchOffice.UpdatedBy = restoredBy
+			branchOffice.Version++
+			r.store.putBranchOffice(ctx, branchOffice)
+			res++
+		}
+	}
+	return res, nil
+}
+
+func (r *memoryBranchOfficeRepo) GetBranchOfficeCount(ctx context.Context, filter GetBranchOfficeListFilter) (int64, error) {
+	return int64(len(r.filter(filter))), nil
+}
+
+func (r *memoryBranchOfficeRepo) NextBranchOfficeCodeSequence(ctx context.Context, scope string) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	return r.store.nextBranchOfficeCodeSequence(scope), nil
+}
+
+// serves tells whether branchOffice is a candidate of serving, as the GORM repo finds
+// them.
+func serves(branchOffice *models.BranchOffice, serving *Serving) bool {
+	if slices.ContainsFunc(serving.PostalCodes, func(code string) bool { return slices.Contains(branchOffice.PostalCodes, code) }) {
+		return true
+	}
+	bounds, ok := branchOffice.ServiceArea.Bounds()
+	return ok && serving.Bounds != nil && bounds.Intersects(*serving.Bounds)
+}
+
+// LockBranchOfficeServiceAreas has nothing to do, as the memory store runs one
+// transaction at a time.
+func (r *memoryBranchOfficeRepo) LockBranchOfficeServiceAreas(ctx context.Context) error {
+	return nil
+}
+
+func (r *memoryBranchOfficeRepo) ClaimBranchOfficesForGeocoding(ctx context.Context, now time.Time, until time.Time, limit int) ([]*models.BranchOffice, error) {
+	list := r.filter(GetBranchOfficeListFilter{
+		GeocodeStatuses: []string{models.BranchOfficeGeocodePending},
+		GeocodeDueAt:    &now,
+	})
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Id < list[j].Id
+	})
+	if len(list) > limit {
+		list = list[:limit]
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, branchOffice := range list {
+		branchOffice.GeocodeNextAttemptAt = &until
+		current := r.store.branchOffices[branchOffice.Id]
+		current.GeocodeNextAttemptAt = &until
+		r.store.putBranchOffice(ctx, current)
+	}
+	return list, nil
+}
@@ -0,0 +1,133 @@
+package repos_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jangkartech/twin-branch-office/pkg/models"
+	"github.com/jangkartech/twin-branch-office/pkg/repos"
+	"github.com/jangkartech/twin-branch-office/pkg/repos/repotest"
+	"gorm.io/gorm"
+)
+
+func TestMemoryBranchOfficeRepo(t *testing.T) {
+	repotest.TestBranchOfficeRepo(t, func(t *testing.T) repos.BranchOfficeRepoInterface {
+		return repos.NewMemoryBranchOfficeRepo(repos.NewMemoryStore())
+	})
+}
+
+func TestMemoryBranchOfficeAuditRepo(t *testing.T) {
+	repotest.TestBranchOfficeAuditRepo(t, func(t *testing.T) repos.BranchOfficeAuditRepoInterface {
+		return repos.NewMemoryBranchOfficeAuditRepo(repos.NewMemoryStore())
+	})
+}
+
+func TestMemoryTransactionRollback(t *testing.T) {
+	ctx := context.Background()
+	errRollback := errors.New("rollback")
+	store := repos.NewMemoryStore()
+	transactionRepo := repos.NewMemoryTransactionRepo(store)
+	repo := repos.NewMemoryBranchOfficeRepo(store)
+	auditRepo := repos.NewMemoryBranchOfficeAuditRepo(store)
+
+	create := func(ctx context.Context, id string, name string) {
+		t.Helper()
+		if _, err := repo.CreateBranchOffice(ctx, models.BranchOffice{Id: id, Name: name, City: "Jakarta"}); err != nil {
+			t.Fatalf("CreateBranchOffice(%s): %v", id, err)
+		}
+	}
+	exists := func(id string) bool {
+		_, err := repo.GetBranchOfficeById(ctx, id, true)
+		return err == nil
+	}
+
+	t.Run("KeepsWritesOutsideTheTransaction", func(t *testing.T) {
+		err := transactionRepo.Transaction(ctx, func(txCtx context.Context) error {
+			create(txCtx, "bo-tx", "In Transaction")
+			if _, err := auditRepo.CreateBranchOfficeAudit(txCtx, models.BranchOfficeAudit{BranchOfficeId: "bo-tx"}); err != nil {
+				t.Fatalf("CreateBranchOfficeAudit: %v", err)
+			}
+			// Written while the transaction runs, but not by it.
+			create(ctx, "bo-outside", "Outside Transaction")
+			return errRollback
+		})
+		if !errors.Is(err, errRollback) {
+			t.Fatalf("Transaction error = %v, want %v", err, errRollback)
+		}
+		if exists("bo-tx") {
+			t.Error("the branch office created by the transaction survived its rollback")
+		}
+		if count, _ := auditRepo.GetBranchOfficeAuditCount(ctx, repos.GetBranchOfficeAuditListFilter{BranchOfficeId: "bo-tx"}); count != 0 {
+			t.Errorf("%d audit entries survived the rollback, want 0", count)
+		}
+		if !exists("bo-outside") {
+			t.Error("the rollback threw away a branch office created outside the transaction")
+		}
+	})
+
+	t.Run("RestoresUpdatedAndDeletedRows", func(t *testing.T) {
+		create(ctx, "bo-updated", "Before")
+		create(ctx, "bo-deleted", "Deleted")
+		err := transactionRepo.Transaction(ctx, func(txCtx context.Context) error {
+			if _, err := repo.UpdateBranchOfficeById(txCtx, "bo-updated", models.BranchOffice{Name: "After"}, []string{"name"}); err != nil {
+				t.Fatalf("UpdateBranchOfficeById: %v", err)
+			}
+			if err := repo.HardDeleteBranchOfficeById(txCtx, "bo-deleted"); err != nil {
+				t.Fatalf("HardDeleteBranchOfficeById: %v", err)
+			}
+			return errRollback
+		})
+		if !errors.Is(err, errRollback) {
+			t.Fatalf("Transaction error = %v, want %v", err, errRollback)
+		}
+		if res, err := repo.GetBranchOfficeById(ctx, "bo-updated", false); err != nil || res.Name != "Before" {
+			t.Errorf("GetBranchOfficeById = %+v, %v, want the name before the rollback", res, err)
+		}
+		if !exists("bo-deleted") {
+			t.Error("the rollback did not bring back a deleted branch office")
+		}
+	})
+
+	t.Run("NestedRollback", func(t *testing.T) {
+		err := transactionRepo.Transaction(ctx, func(txCtx context.Context) error {
+			create(txCtx, "bo-outer", "Outer")
+			err := transactionRepo.Transaction(txCtx, func(txCtx context.Context) error {
+				create(txCtx, "bo-inner", "Inner")
+				return errRollback
+			})
+			if !errors.Is(err, errRollback) {
+				t.Fatalf("nested Transaction error = %v, want %v", err, errRollback)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Transaction: %v", err)
+		}
+		if !exists("bo-outer") {
+			t.Error("the outer transaction lost its write to the nested rollback")
+		}
+		if exists("bo-inner") {
+			t.Error("the write of the nested transaction survived its rollback")
+		}
+	})
+
+	t.Run("NestedCommitRolledBackByOuter", func(t *testing.T) {
+		err := transactionRepo.Transaction(ctx, func(txCtx context.Context) error {
+			err := transactionRepo.Transaction(txCtx, func(txCtx context.Context) error {
+				create(txCtx, "bo-nested", "Nested")
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("nested Transaction: %v", err)
+			}
+			return errRollback
+		})
+		if !errors.Is(err, errRollback) {
+			t.Fatalf("Transaction error = %v, want %v", err, errRollback)
+		}
+		if _, err := repo.GetBranchOfficeById(ctx, "bo-nested", true); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("GetBranchOfficeById error = %v, want the nested write rolled back", err)
+		}
+	})
+}
@@ -0,0 +1,160 @@
+package repos
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/jangkartech/twin-branch-office/pkg/models"
+)
+
+// MemoryStore is the in-memory counterpart of the database handle used by the GORM
+// repos. It lets tests and local development run without Postgres.
+type MemoryStore struct {
+	mu   sync.RWMutex
+	txMu sync.Mutex
+
+	branchOffices             map[string]models.BranchOffice
+	branchOfficeAudits        []models.BranchOfficeAudit
+	lastBranchOfficeAuditId   uint64
+	branchOfficeCodeSequences map[string]int64
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		branchOffices:             map[string]models.BranchOffice{},
+		branchOfficeCodeSequences: map[string]int64{},
+	}
+}
+
+// memoryJournal holds what undoes the writes made in a transaction, in the order they
+// were made.
+type memoryJournal struct {
+	undo []func()
+}
+
+// record keeps undo to revert a write made with ctx when ctx carries a transaction. The
+// caller holds s.mu.
+func (s *MemoryStore) record(ctx context.Context, undo func()) {
+	if journal, ok := ctx.Value(memoryTxContextKey{}).(*memoryJournal); ok {
+		journal.undo = append(journal.undo, undo)
+	}
+}
+
+// rollback reverts the writes kept in journal, newest first, leaving those of others.
+func (s *MemoryStore) rollback(journal *memoryJournal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := len(journal.undo) - 1; i >= 0; i-- {
+		journal.undo[i]()
+	}
+}
+
+// putBranchOffice stores branchOffice under its id. The caller holds s.mu.
+func (s *MemoryStore) putBranchOffice(ctx context.Context, branchOffice models.BranchOffice) {
+	id := branchOffice.Id
+	previous, existed := s.branchOffices[id]
+	s.branchOffices[id] = branchOffice
+	s.record(ctx, func() {
+		if existed {
+			s.branchOffices[id] = previous
+		} else {
+			delete(s.branchOffices, id)
+		}
+	})
+}
+
+// deleteBranchOffice removes the branch office with the given id. The caller holds s.mu.
+func (s *MemoryStore) deleteBranchOffice(ctx context.Context, id string) {
+	previous, existed := s.branchOffices[id]
+	if !existed {
+		return
+	}
+	delete(s.branchOffices, id)
+	s.record(ctx, func() {
+		s.branchOffices[id] = previous
+	})
+}
+
+// addBranchOfficeAudit appends audit. Like a database sequence, the audit ids it hands
+// out are not given back on rollback. The caller holds s.mu.
+func (s *MemoryStore) addBranchOfficeAudit(ctx context.Context, audit models.BranchOfficeAudit) models.BranchOfficeAudit {
+	s.lastBranchOfficeAuditId++
+	audit.Id = s.lastBranchOfficeAuditId
+	s.branchOfficeAudits = append(s.branchOfficeAudits, audit)
+	s.record(ctx, func() {
+		s.branchOfficeAudits = slices.DeleteFunc(s.branchOfficeAudits, func(item models.BranchOfficeAudit) bool {
+			return item.Id == audit.Id
+		})
+	})
+	return audit
+}
+
+// nextBranchOfficeCodeSequence increments the code sequence of scope. Like the GORM
+// repo, it does so outside any transaction, so a rollback does not give the number
+// back. The caller holds s.mu.
+func (s *MemoryStore) nextBranchOfficeCodeSequence(scope string) int64 {
+	s.branchOfficeCodeSequences[scope]++
+	return s.branchOfficeCodeSequences[scope]
+}
+
+type memoryTransactionRepo struct {
+	store *MemoryStore
+}
+
+type memoryTxContextKey struct{}
+
+func NewMemoryTransactionRepo(store *MemoryStore) TransactionRepoInterface {
+	return &memoryTransactionRepo{
+		store: store,
+	}
+}
+
+// Transaction serializes transactions against the store and rolls back the changes made
+// by fn when it returns an error. Only those changes are undone, so writes made outside
+// the transaction in the meantime are kept. Nested calls roll back only their own
+// changes, and hand the rest to the enclosing transaction.
+func (r *memoryTransactionRepo) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	parent, nested := ctx.Value(memoryTxContextKey{}).(*memoryJournal)
+	if !nested {
+		r.store.txMu.Lock()
+		defer r.store.txMu.Unlock()
+	}
+
+	journal := &memoryJournal{}
+	committed := false
+	defer func() {
+		if !committed {
+			r.store.rollback(journal)
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, memoryTxContextKey{}, journal)); err != nil {
+		return err
+	}
+	committed = true
+	if nested {
+		parent.undo = append(parent.undo, journal.undo...)
+	}
+	return nil
+}
+
+// paginate mirrors util.Paginate for slices kept in memory.
+func paginate[T any](list []T, limit *int, page *int) []T {
+	if limit == nil || page == nil {
+		return list
+	}
+	offset := (*page - 1) * *limit
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > len(list) {
+		offset = len(list)
+	}
+	end := offset + *limit
+	if end > len(list) {
+		end = len(list)
+	}
+	return list[offset:end]
+}
@@ -0,0 +1,963 @@
+// Package repotest holds conformance suites shared by every implementation of the
+// repos interfaces. Call them from a _test.go file with a constructor for the
+// implementation under test.
+package repotest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jangkartech/twin-branch-office/pkg/geo"
+	"github.com/jangkartech/twin-branch-office/pkg/models"
+	"github.com/jangkartech/twin-branch-office/pkg/repos"
+	"github.com/jangkartech/twin-util/pkg/constant"
+	utilErrors "github.com/jangkartech/twin-util/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// TestBranchOfficeRepo checks newRepo against the behaviour expected from a
+// BranchOfficeRepoInterface. newRepo must return a repo over an empty store each call.
+func TestBranchOfficeRepo(t *testing.T, newRepo func(t *testing.T) repos.BranchOfficeRepoInterface) {
+	ctx := context.Background()
+
+	t.Run("CreateAndGet", func(t *testing.T) {
+		repo := newRepo(t)
+		seed(t, repo, branchOffice("bo-1", "Jakarta Pusat"))
+
+		res, err := repo.GetBranchOfficeById(ctx, "bo-1", false)
+		if err != nil {
+			t.Fatalf("GetBranchOfficeById: %v", err)
+		}
+		if res.Name != "Jakarta Pusat" || res.City != "Jakarta" {
+			t.Errorf("GetBranchOfficeById = %+v, want the created branch office", res)
+		}
+		if res.CreatedAt.IsZero() {
+			t.Error("CreatedAt was not set on create")
+		}
+		if res.Version != 1 {
+			t.Errorf("Version = %d on create, want 1", res.Version)
+		}
+		if res.UpdatedAt.IsZero() {
+			t.Error("UpdatedAt was not set on create")
+		}
+
+		res, err = repo.GetBranchOfficeByField(ctx, "name", "Jakarta Pusat", false)
+		if err != nil || res.Id != "bo-1" {
+			t.Errorf("GetBranchOfficeByField = %+v, %v, want bo-1", res, err)
+		}
+	})
+
+	t.Run("CreateDuplicateId", func(t *testing.T) {
+		repo := newRepo(t)
+		seed(t, repo, branchOffice("bo-1", "Bandung"))
+
+		if _, err := repo.CreateBranchOffice(ctx, branchOffice("bo-1", "Bogor")); err == nil {
+			t.Error("CreateBranchOffice with a duplicate id succeeded")
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		repo := newRepo(t)
+
+		if _, err := repo.GetBranchOfficeById(ctx, "missing", true); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("GetBranchOfficeById error = %v, want gorm.ErrRecordNotFound", err)
+		}
+		if _, err := repo.GetBranchOfficeByField(ctx, "name", "missing", true); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("GetBranchOfficeByField error = %v, want gorm.ErrRecordNotFound", err)
+		}
+		if _, err := repo.UpdateBranchOfficeById(ctx, "missing", models.BranchOffice{Name: "x"}, []string{"name"}); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("UpdateBranchOfficeById error = %v, want gorm.ErrRecordNotFound", err)
+		}
+	})
+
+	t.Run("GetByFieldValues", func(t *testing.T) {
+		repo := newRepo(t)
+		seed(t, repo, branchOffice("bo-1", "Medan"), branchOffice("bo-2", "Makassar"), branchOffice("bo-3", "Manado"))
+		if err := repo.SoftDeleteBranchOfficeById(ctx, "bo-2", "user-1"); err != nil {
+			t.Fatalf("SoftDeleteBranchOfficeById: %v", err)
+		}
+
+		res, err := repo.GetBranchOfficesByField(ctx, "name", []string{"Medan", "Makassar", "missing"}, false)
+		if err != nil {
+			t.Fatalf("GetBranchOfficesByField: %v", err)
+		}
+		assertIds(t, res, "bo-1")
+
+		res, err = repo.GetBranchOfficesByField(ctx, "id", []string{"bo-1", "bo-2"}, true)
+		if err != nil {
+			t.Fatalf("GetBranchOfficesByField: %v", err)
+		}
+		sort.Slice(res, func(i, j int) bool { return res[i].Id < res[j].Id })
+		assertIds(t, res, "bo-1", "bo-2")
+	})
+
+	t.Run("UpdateWritesGivenColumns", func(t *testing.T) {
+		repo := newRepo(t)
+		seed(t, repo, branchOffice("bo-1", "Surabaya"))
+
+		created, err := repo.GetBranchOfficeById(ctx, "bo-1", false)
+		if err != nil {
+			t.Fatalf("GetBranchOfficeById: %v", err)
+		}
+
+		res, err := repo.UpdateBranchOfficeById(ctx, "bo-1", models.BranchOffice{Name: "Ignored", PhoneNumber: "031-999", Version: 2, UpdatedBy: "user-2"},
+			[]string{"phone_number", "fax_number", "version", "updated_by"})
+		if err != nil {
+			t.Fatalf("UpdateBranchOfficeById: %v", err)
+		}
+		if res.PhoneNumber != "031-999" || res.FaxNumber != "" || res.Name != "Surabaya" || res.Version != 2 || res.UpdatedBy != "user-2" || res.CreatedBy != "user-1" {
+			t.Errorf("UpdateBranchOfficeById = %+v, want only the phone number, cleared fax number, version and updater changed", res)
+		}
+		if res.UpdatedAt.Before(created.UpdatedAt) {
+			t.Errorf("UpdatedAt = %v, want it moved forward from %v", res.UpdatedAt, created.UpdatedAt)
+		}
+	})
+
+	t.Run("NameUniqueness", func(t *testing.T) {
+		repo := newRepo(t)
+		seed(t, repo, branchOffice("bo-1", "Kantor  Pusat"), branchOffice("bo-2", "Bekasi"))
+
+		if res, err := repo.GetBranchOfficeByField(ctx, "name", " kantor pusat", false); err != nil || res.Id != "bo-1" {
+			t.Errorf("GetBranchOfficeByField by a differently spelled name = %+v, %v, want bo-1", res, err)
+		}
+		res, err := repo.GetBranchOfficesByField(ctx, "name", []string{"KANTOR PUSAT", "bekasi "}, false)
+		if err != nil {
+			t.Fatalf("GetBranchOfficesByField: %v", err)
+		}
+		sort.Slice(res, func(i, j int) bool { return res[i].Id < res[j].Id })
+		assertIds(t, res, "bo-1", "bo-2")
+
+		if _, err := repo.CreateBranchOffice(ctx, branchOffice("bo-3", "kantor pusat")); !isValidationError(err, "name") {
+			t.Errorf("CreateBranchOffice with a taken name error = %v, want a name validation error", err)
+		}
+		if _, err := repo.UpdateBranchOfficeById(ctx, "bo-2", models.BranchOffice{Name: "KANTOR PUSAT "}, []string{"name"}); !isValidationError(err, "name") {
+			t.Errorf("UpdateBranchOfficeById to a taken name error = %v, want a name validation error", err)
+		}
+		if _, err := repo.UpdateBranchOfficeById(ctx, "bo-1", models.BranchOffice{Name: "Kantor Pusat"}, []string{"name"}); err != nil {
+			t.Errorf("UpdateBranchOfficeById respelling its own name: %v", err)
+		}
+
+		// Soft-deleted branch offices give their name up, until they are restored.
+		if err := repo.SoftDeleteBranchOfficeById(ctx, "bo-1", "user-3"); err != nil {
+			t.Fatalf("SoftDeleteBranchOfficeById: %v", err)
+		}
+		seed(t, repo, branchOffice("bo-3", "Kantor Pusat"))
+		if err := repo.RestoreBranchOfficeById(ctx, "bo-1", "", "user-5"); !isValidationError(err, "name") {
+			t.Errorf("RestoreBranchOfficeById with a taken name error = %v, want a name validation error", err)
+		}
+		if _, err := repo.RestoreBranchOfficesByIds(ctx, []string{"bo-1"}, "user-5"); !isValidationError(err, "name") {
+			t.Errorf("RestoreBranchOfficesByIds with a taken name error = %v, want a name validation error", err)
+		}
+		trashed, err := repo.GetBranchOfficeById(ctx, "bo-1", true)
+		if err != nil || !trashed.DeletedAt.Valid {
+			t.Fatalf("GetBranchOfficeById = %+v, %v, want bo-1 left in the trash", trashed, err)
+		}
+
+		if err := repo.RestoreBranchOfficeById(ctx, "bo-1", "Kantor Pusat Lama", "user-5"); err != nil {
+			t.Fatalf("RestoreBranchOfficeById with a new name: %v", err)
+		}
+		if res, err := repo.GetBranchOfficeById(ctx, "bo-1", false); err != nil || res.Name != "Kantor Pusat Lama" || res.Version != trashed.Version+1 {
+			t.Errorf("GetBranchOfficeById = %+v, %v, want bo-1 restored as Kantor Pusat Lama at version %d", res, err, trashed.Version+1)
+		}
+	})
+
+	t.Run("SoftDeleteAndRestore", func(t *testing.T) {
+		repo := newRepo(t)
+		seed(t, repo, branchOffice("bo-1", "Medan"), branchOffice("bo-2", "Makassar"))
+
+		if err := repo.SoftDeleteBranchOfficeById(ctx, "bo-1", "user-3"); err != nil {
+			t.Fatalf("SoftDeleteBranchOfficeById: %v", err)
+		}
+		trashed, err := repo.GetBranchOfficeById(ctx, "bo-1", true)
+		if err != nil || trashed.DeletedBy != "user-3" || trashed.Version != 2 {
+			t.Errorf("GetBranchOfficeById = %+v, %v, want DeletedBy user-3 at version 2", trashed, err)
+		}
+		if err := repo.SoftDeleteBranchOfficeById(ctx, "bo-1", "user-4"); err != nil {
+			t.Fatalf("SoftDeleteBranchOfficeById on a trashed branch office: %v", err)
+		}
+		if res, err := repo.GetBranchOfficeById(ctx, "bo-1", true); err != nil || res.DeletedBy != "user-3" || res.Version != 2 {
+			t.Errorf("GetBranchOfficeById = %+v, %v, want a second soft delete to change nothing", res, err)
+		}
+		if _, err := repo.GetBranchOfficeById(ctx, "bo-1", false); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("soft-deleted branch office is visible without trash: %v", err)
+		}
+		if _, err := repo.GetBranchOfficeById(ctx, "bo-1", true); err != nil {
+			t.Errorf("soft-deleted branch office is not visible with trash: %v", err)
+		}
+		if _, err := repo.GetBranchOfficeByField(ctx, "name", "Medan", false); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("soft-deleted branch office is found by field without trash: %v", err)
+		}
+		if _, err := repo.UpdateBranchOfficeById(ctx, "bo-1", models.BranchOffice{Name: "x"}, []string{"name"}); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("UpdateBranchOfficeById on a soft-deleted branch office error = %v, want gorm.ErrRecordNotFound", err)
+		}
+
+		assertIds(t, list(t, repo, repos.GetBranchOfficeListFilter{}), "bo-2")
+		deleted := constant.StatusDeleted
+		assertIds(t, list(t, repo, repos.GetBranchOfficeListFilter{Status: &deleted}), "bo-1")
+		assertCount(t, repo, repos.GetBranchOfficeListFilter{Status: &deleted}, 1)
+		deletedBy := "user-3"
+		assertIds(t, list(t, repo, repos.GetBranchOfficeListFilter{Status: &deleted, DeletedBy: &deletedBy}), "bo-1")
+
+		if err := repo.RestoreBranchOfficeById(ctx, "bo-1", "", "user-5"); err != nil {
+			t.Fatalf("RestoreBranchOfficeById: %v", err)
+		}
+		assertIds(t, list(t, repo, repos.GetBranchOfficeListFilter{}), "bo-2", "bo-1")
+		res, err := repo.GetBranchOfficeById(ctx, "bo-1", false)
+		if err != nil || res.DeletedBy != "" || res.Version != 3 {
+			t.Fatalf("GetBranchOfficeById = %+v, %v, want DeletedBy cleared on restore at version 3", res, err)
+		}
+		if res.UpdatedBy != "user-5" || !res.UpdatedAt.After(trashed.UpdatedAt) {
+			t.Errorf("restore left UpdatedBy %q and UpdatedAt %v, want user-5 after %v", res.UpdatedBy, res.UpdatedAt, trashed.UpdatedAt)
+		}
+	})
+
+	t.Run("FilterByActor", func(t *testing.T) {
+		repo := newRepo(t)
+		a := branchOffice("bo-1", "Padang")
+		b := branchOffice("bo-2", "Palembang")
+		b.CreatedBy = "user-2"
+		b.UpdatedBy = "user-2"
+		seed(t, repo, a, b)
+
+		createdBy := "user-2"
+		assertIds(t, list(t, repo, repos.GetBranchOfficeListFilter{CreatedBy: &createdBy}), "bo-2")
+		assertCount(t, repo, repos.GetBranchOfficeListFilter{CreatedBy: &createdBy}, 1)
+		updatedBy := "user-1"
+		assertIds(t, list(t, repo, repos.GetBranchOfficeListFilter{UpdatedBy: &updatedBy}), "bo-1")
+	})
+
+	t.Run("HardDelete", func(t *testing.T) {
+		repo := newRepo(t)
+		seed(t, repo, branchOffice("bo-1", "Denpasar"))
+
+		if err := repo.SoftDeleteBranchOfficeById(ctx, "bo-1", "user-1"); err != nil {
+			t.Fatalf("SoftDeleteBranchOfficeById: %v", err)
+		}
+		if err := repo.HardDeleteBranchOfficeById(ctx, "bo-1"); err != nil {
+			t.Fatalf("HardDeleteBranchOfficeById: %v", err)
+		}
+		if _, err := repo.GetBranchOfficeById(ctx, "bo-1", true); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("hard-deleted branch office is still visible with trash: %v", err)
+		}
+	})
+
+	t.Run("BulkWrites", func(t *testing.T) {
+		repo := newRepo(t)
+		seed(t, repo, branchOffice("bo-1", "Ambon"), branchOffice("bo-2", "Bima"), branchOffice("bo-3", "Cilacap"))
+
+		if n, err := repo.SoftDeleteBranchOfficesByIds(ctx, []string{"bo-1", "bo-2", "missing"}, "user-4"); err != nil || n != 2 {
+			t.Fatalf("SoftDeleteBranchOfficesByIds = %d, %v, want 2", n, err)
+		}
+		if n, err := repo.SoftDeleteBranchOfficesByIds(ctx, []string{"bo-1"}, "user-5"); err != nil || n != 0 {
+			t.Errorf("SoftDeleteBranchOfficesByIds on a trashed branch office = %d, %v, want 0", n, err)
+		}
+		assertIds(t, list(t, repo, repos.GetBranchOfficeListFilter{}), "bo-3")
+		assertIds(t, list(t, repo, repos.GetBranchOfficeListFilter{WithTrash: true}), "bo-1", "bo-2", "bo-3")
+
+		locked, err := repo.LockBranchOfficeList(ctx, repos.GetBranchOfficeListFilter{Ids: []string{"bo-3", "bo-1"}, WithTrash: true})
+		if err != nil {
+			t.Fatalf("LockBranchOfficeList: %v", err)
+		}
+		assertIds(t, locked, "bo-1", "bo-3")
+
+		if n, err := repo.RestoreBranchOfficesByIds(ctx, []string{"bo-1", "bo-3"}, "user-6"); err != nil || n != 1 {
+			t.Fatalf("RestoreBranchOfficesByIds = %d, %v, want 1", n, err)
+		}
+		if res, err := repo.GetBranchOfficeById(ctx, "bo-1", false); err != nil || res.DeletedBy != "" || res.Version != 3 || res.UpdatedBy != "user-6" {
+			t.Errorf("GetBranchOfficeById = %+v, %v, want a restored branch office at version 3 updated by user-6", res, err)
+		}
+		if res, err := repo.GetBranchOfficeById(ctx, "bo-2", true); err != nil || res.Version != 2 {
+			t.Errorf("GetBranchOfficeById = %+v, %v, want a trashed branch office at version 2", res, err)
+		}
+
+		if n, err := repo.HardDeleteBranchOfficesByIds(ctx, []string{"bo-1", "bo-2", "missing"}); err != nil || n != 2 {
+			t.Fatalf("HardDeleteBranchOfficesByIds = %d, %v, want 2", n, err)
+		}
+		assertIds(t, list(t, repo, repos.GetBranchOfficeListFilter{WithTrash: true}), "bo-3")
+	})
+
+	t.Run("Codes", func(t *testing.T) {
+		repo := newRepo(t)
+		for i, want := range []int64{1, 2} {
+			if got, err := repo.NextBranchOfficeCodeSequence(ctx, "JKT-#"); err != nil || got != want {
+				t.Errorf("NextBranchOfficeCodeSequence call %d = %d, %v, want %d", i+1, got, err, want)
+			}
+		}
+		if got, err := repo.NextBranchOfficeCodeSequence(ctx, "BDG-#"); err != nil || got != 1 {
+			t.Errorf("NextBranchOfficeCodeSequence of another scope = %d, %v, want 1", got, err)
+		}
+
+		a := branchOffice("bo-1", "Kota")
+		a.Code = "JKT-001"
+		b := branchOffice("bo-2", "Dago")
+		b.Code = "BDG-001"
+		seed(t, repo, a, b, branchOffice("bo-3", "Legacy"))
+
+		duplicate := branchOffice("bo-4", "Menteng")
+		duplicate.Code = "JKT-001"
+		if _, err := repo.CreateBranchOffice(ctx, duplicate); !isValidationError(err, "code") {
+			t.Errorf("CreateBranchOffice with a taken code error = %v, want a code validation error", err)
+		}
+
+		assertIds(t, list(t, repo, repos.GetBranchOfficeListFilter{Codes: []string{"BDG-001", "JKT-001"}}), "bo-2", "bo-1")
+		keyword := "jkt"
+		assertIds(t, list(t, repo, repos.GetBranchOfficeListFilter{Fields: &[]string{"code"}, Keyword: &keyword}), "bo-1")
+		assertIds(t, list(t, repo, repos.GetBranchOfficeListFilter{Sort: []repos.SortField{{Column: "code"}}}), "bo-3", "bo-2", "bo-1")
+		hasCode := false
+		assertIds(t, list(t, repo, repos.GetBranchOfficeListFilter{HasCode: &hasCode}), "bo-3")
+		hasCode = true
+		assertIds(t, list(t, repo, repos.GetBranchOfficeListFilter{HasCode: &hasCode, Sort: []repos.SortField{{Column: "code"}}}), "bo-2", "bo-1")
+	})
+
+	t.Run("LifecycleStatus", func(t *testing.T) {
+		repo := newRepo(t)
+		planned := branchOffice("bo-2", "Dago")
+		planned.LifecycleStatus = models.BranchOfficeStatusPlanned
+		seed(t, repo, branchOffice("bo-1", "Kota"), planned)
+
+		created, err := repo.GetBranchOfficeById(ctx, "bo-1", false)
+		if err != nil {
+			t.Fatalf("GetBranchOfficeById: %v", err)
+		}
+		if created.LifecycleStatus != models.BranchOfficeStatusActive {
+			t.Errorf("LifecycleStatus = %q, want it to default to %q", created.LifecycleStatus, models.BranchOfficeStatusActive)
+		}
+
+		effectiveAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+		res, err := repo.UpdateBranchOfficeById(ctx, "bo-1", models.BranchOffice{
+			LifecycleStatus:            models.BranchOfficeStatusSuspended,
+			LifecycleStatusReason:      "Renovation",
+			LifecycleStatusEffectiveAt: &effectiveAt,
+		}, []string{"lifecycle_status", "lifecycle_status_reason", "lifecycle_status_effective_at"})
+		if err != nil {
+			t.Fatalf("UpdateBranchOfficeById: %v", err)
+		}
+		if res.LifecycleStatus != models.BranchOfficeStatusSuspended || res.LifecycleStatusReason != "Renovation" ||
+			res.LifecycleStatusEffectiveAt == nil || !res.LifecycleStatusEffectiveAt.Equal(effectiveAt) {
+			t.Errorf("UpdateBranchOfficeById = %+v, want it suspended for renovation since %v", res, effectiveAt)
+		}
+
+		assertIds(t, list(t, repo, repos.GetBranchOfficeListFilter{LifecycleStatuses: []string{models.BranchOfficeStatusSuspended}}), "bo-1")
+		assertIds(t, list(t, repo, repos.GetBranchOfficeListFilter{LifecycleStatuses: []string{models.BranchOfficeStatusPlanned, models.BranchOfficeStatusSuspended}}), "bo-2", "bo-1")
+		assertCount(t, repo, repos.GetBranchOfficeListFilter{LifecycleStatuses: []string{models.BranchOfficeStatusActive}}, 0)
+	})
+
+	t.Run("OpenAt", func(t *testing.T) {
+		repo := newRepo(t)
+		weekdays := []models.BranchOfficeOpeningHours{
+			{Weekday: int(time.Monday), Opens: "08:00", Closes: "12:00"},
+			{Weekday: int(time.Monday), Opens: "13:00", Closes: "16:00"},
+		}
+		jakarta := branchOffice("bo-1", "Kota")
+		jakarta.OpeningHours = weekdays
+		jakarta.Exceptions = []models.BranchOfficeHoursException{{Date: "2024-01-08", Name: "Holiday"}}
+		makassar := branchOffice("bo-2", "Losari")
+		makassar.Timezone = "Asia/Makassar"
+		makassar.OpeningHours = weekdays
+		makassar.Exceptions = []models.BranchOfficeHoursException{{Date: "2024-01-15", Intervals: []models.BranchOfficeHoursInterval{{Opens: "10:00", Closes: "11:00"}}}}
+		suspended := branchOffice("bo-3", "Dago")
+		suspended.OpeningHours = weekdays
+		suspended.LifecycleStatus = models.BranchOfficeStatusSuspended
+		seed(t, repo, jakarta, makassar, suspended, branchOffice("bo-4", "Legacy"))
+
+		// 2024-01-01 and 2024-01-08 are Mondays; Jakarta is UTC+7 and Makassar UTC+8.
+		for _, tc := range []struct {
+			at   time.Time
+			want []string
+		}{
+			{time.Date(2024, 1, 1, 0, 30, 0, 0, time.UTC), []string{"bo-2"}},
+			{time.Date(2024, 1, 1, 1, 30, 0, 0, time.UTC), []string{"bo-1", "bo-2"}},
+			{time.Date(2024, 1, 1, 5, 0, 0, 0, time.UTC), []string{"bo-2"}},
+			{time.Date(2024, 1, 1, 8, 59, 0, 0, time.UTC), []string{"bo-1"}},
+			{time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC), nil},
+			{time.Date(2024, 1, 2, 1, 30, 0, 0, time.UTC), nil},
+			{time.Date(2024, 1, 8, 2, 0, 0, 0, time.UTC), []string{"bo-2"}},
+			{time.Date(2024, 1, 15, 1, 0, 0, 0, time.UTC), []string{"bo-1"}},
+			{time.Date(2024, 1, 15, 2, 30, 0, 0, time.UTC), []string{"bo-1", "bo-2"}},
+		} {
+			at := tc.at
+			assertIds(t, list(t, repo, repos.GetBranchOfficeListFilter{OpenAt: &at, Sort: []repos.SortField{{Column: "id"}}}), tc.want...)
+		}
+	})
+
+	t.Run("OpenAtAcrossMidnight", func(t *testing.T) {
+		repo := newRepo(t)
+		jayapura := branchOffice("bo-1", "Sentani")
+		jayapura.Timezone = "Asia/Jayapura"
+		jayapura.OpeningHours = []models.BranchOfficeOpeningHours{
+			{Weekday: int(time.Sunday), Opens: "22:00", Closes: "24:00"},
+			{Weekday: int(time.Monday), Opens: "00:00", Closes: "02:00"},
+		}
+		jayapura.Exceptions = []models.BranchOfficeHoursException{{Date: "2024-01-08", Name: "Holiday"}}
+		jakarta := branchOffice("bo-2", "Kota")
+		jakarta.Exceptions = []models.BranchOfficeHoursException{{Date: "2024-01-06", Name: "Stocktaking", Intervals: []models.BranchOfficeHoursInterval{{Opens: "23:00", Closes: "24:00"}}}}
+		seed(t, repo, jayapura, jakarta)
+
+		// Jayapura is UTC+9 all year, so its Monday starts at 15:00 UTC on Sunday, and
+		// Jakarta UTC+7. 2023-12-31 and 2024-01-07 are Sundays; 2024-01-06 and 2024-01-13
+		// are Saturdays, which Jakarta keeps no weekly hours on.
+		for _, tc := range []struct {
+			at   time.Time
+			want []string
+		}{
+			{time.Date(2023, 12, 31, 12, 59, 0, 0, time.UTC), nil},
+			{time.Date(2023, 12, 31, 13, 30, 0, 0, time.UTC), []string{"bo-1"}},
+			{time.Date(2023, 12, 31, 14, 59, 0, 0, time.UTC), []string{"bo-1"}},
+			{time.Date(2023, 12, 31, 15, 0, 0, 0, time.UTC), []string{"bo-1"}},
+			{time.Date(2023, 12, 31, 16, 59, 0, 0, time.UTC), []string{"bo-1"}},
+			{time.Date(2023, 12, 31, 17, 0, 0, 0, time.UTC), nil},
+			// Monday in UTC, but Tuesday in Jayapura.
+			{time.Date(2024, 1, 1, 15, 30, 0, 0, time.UTC), nil},
+			// The holiday closes the Monday hours after midnight, not the Sunday ones.
+			{time.Date(2024, 1, 7, 14, 30, 0, 0, time.UTC), []string{"bo-1"}},
+			{time.Date(2024, 1, 7, 15, 30, 0, 0, time.UTC), nil},
+			// The exception opens Jakarta late on a Saturday, up to midnight only.
+			{time.Date(2024, 1, 6, 15, 59, 0, 0, time.UTC), nil},
+			{time.Date(2024, 1, 6, 16, 30, 0, 0, time.UTC), []string{"bo-2"}},
+			{time.Date(2024, 1, 6, 16, 59, 0, 0, time.UTC), []string{"bo-2"}},
+			{time.Date(2024, 1, 6, 17, 0, 0, 0, time.UTC), nil},
+			{time.Date(2024, 1, 13, 16, 30, 0, 0, time.UTC), nil},
+		} {
+			at := tc.at
+			assertIds(t, list(t, repo, repos.GetBranchOfficeListFilter{OpenAt: &at, Sort: []repos.SortField{{Column: "id"}}}), tc.want...)
+		}
+	})
+
+	t.Run("Nearby", func(t *testing.T) {
+		repo := newRepo(t)
+		seed(t, repo,
+			at(branchOffice("bo-1", "Monas"), -6.1754, 106.8272),
+			inCity(at(branchOffice("bo-2", "Dago"), -6.9175, 107.6191), "Bandung"),
+			at(branchOffice("bo-3", "Bogor"), -6.5971, 106.8060),
+			branchOffice("bo-4", "Legacy"),
+			at(branchOffice("bo-5", "Taveuni"), -16.8, -179.97),
+		)
+		nearby := func(filter repos.GetBranchOfficeListFilter, near repos.Near) []*models.BranchOffice {
+			t.Helper()
+			res, err := repo.GetNearbyBranchOfficeList(ctx, filter, near)
+			if err != nil {
+				t.Fatalf("GetNearbyBranchOfficeList: %v", err)
+			}
+			var list []*models.BranchOffice
+			for _, item := range res {
+				point, _ := item.Point()
+				if want := geo.DistanceKm(near.Point, point); math.Abs(item.DistanceKm-want) > 0.001 {
+					t.Errorf("DistanceKm of %s = %v, want %v", item.Id, item.DistanceKm, want)
+				}
+				list = append(list, &item.BranchOffice)
+			}
+			return list
+		}
+		jakarta := geo.Point{Lat: -6.2, Lng: 106.85}
+		radius := 100.0
+		limit := 2
+
+		assertIds(t, nearby(repos.GetBranchOfficeListFilter{}, repos.Near{Point: jakarta}), "bo-1", "bo-3", "bo-2", "bo-5")
+		assertIds(t, nearby(repos.GetBranchOfficeListFilter{Limit: &limit}, repos.Near{Point: jakarta}), "bo-1", "bo-3")
+		assertIds(t, nearby(repos.GetBranchOfficeListFilter{}, repos.Near{Point: jakarta, RadiusKm: &radius}), "bo-1", "bo-3")
+		assertIds(t, nearby(repos.GetBranchOfficeListFilter{Cities: []string{"Bandung"}}, repos.Near{Point: jakarta}), "bo-2")
+
+		// The radius reaches across the antimeridian from Suva.
+		fiji := 250.0
+		assertIds(t, nearby(repos.GetBranchOfficeListFilter{}, repos.Near{Point: geo.Point{Lat: -18.1416, Lng: 178.4419}, RadiusKm: &fiji}), "bo-5")
+
+		if err := repo.SoftDeleteBranchOfficeById(ctx, "bo-1", "user-1"); err != nil {
+			t.Fatalf("SoftDeleteBranchOfficeById: %v", err)
+		}
+		assertIds(t, nearby(repos.GetBranchOfficeListFilter{}, repos.Near{Point: jakarta, RadiusKm: &radius}), "bo-3")
+	})
+
+	t.Run("ServiceArea", func(t *testing.T) {
+		repo := newRepo(t)
+		seed(t, repo, branchOffice("bo-1", "Kota"), branchOffice("bo-2", "Dago"))
+
+		area := geo.MultiPolygon{{{{106.7, -6.3}, {106.9, -6.3}, {106.9, -6.1}, {106.7, -6.1}, {106.7, -6.3}}}}
+		res, err := repo.UpdateBranchOfficeById(ctx, "bo-1", models.BranchOffice{
+			ServiceArea: area,
+			PostalCodes: []string{"10110", "10120"},
+		}, []string{"service_area", "postal_codes"})
+		if err != nil {
+			t.Fatalf("UpdateBranchOfficeById: %v", err)
+		}
+		if !reflect.DeepEqual(res.ServiceArea, area) || !reflect.DeepEqual(res.PostalCodes, []string{"10110", "10120"}) {
+			t.Errorf("UpdateBranchOfficeById = %+v, want the service area and postal codes written", res)
+		}
+
+		hasServiceArea, noServiceArea := true, false
+		postalCode := "10120"
+		assertIds(t, list(t, repo, repos.GetBranchOfficeListFilter{HasServiceArea: &hasServiceArea}), "bo-1")
+		assertIds(t, list(t, repo, repos.GetBranchOfficeListFilter{HasServiceArea: &noServiceArea}), "bo-2")
+		assertIds(t, list(t, repo, repos.GetBranchOfficeListFilter{PostalCode: &postalCode}), "bo-1")
+
+		postalCode = "1012"
+		assertCount(t, repo, repos.GetBranchOfficeListFilter{PostalCode: &postalCode}, 0)
+	})
+
+	t.Run("Serving", func(t *testing.T) {
+		repo := newRepo(t)
+		kota := branchOffice("bo-1", "Kota")
+		kota.ServiceArea = geo.MultiPolygon{{{{106.7, -6.3}, {106.9, -6.3}, {106.9, -6.1}, {106.7, -6.1}, {106.7, -6.3}}}}
+		dago := branchOffice("bo-2", "Dago")
+		dago.PostalCodes = []string{"40135"}
+		seed(t, repo, kota, dago, branchOffice("bo-3", "Legacy"))
+
+		// The area written later gets its bounds too.
+		_, err := repo.UpdateBranchOfficeById(ctx, "bo-3", models.BranchOffice{
+			ServiceArea: geo.MultiPolygon{{{{107.5, -7}, {107.7, -7}, {107.7, -6.8}, {107.5, -6.8}, {107.5, -7}}}},
+		}, []string{"service_area"})
+		if err != nil {
+			t.Fatalf("UpdateBranchOfficeById: %v", err)
+		}
+
+		serving := func(postalCodes []string, box *geo.Box) []*models.BranchOffice {
+			return list(t, repo, repos.GetBranchOfficeListFilter{Serving: &repos.Serving{PostalCodes: postalCodes, Bounds: box}, Sort: []repos.SortField{{Column: "id"}}})
+		}
+		assertIds(t, serving([]string{"40135"}, nil), "bo-2")
+		assertIds(t, serving(nil, &geo.Box{MinLat: -6.2, MaxLat: -6.2, MinLng: 106.8, MaxLng: 106.8}), "bo-1")
+		// Bounds that only touch count, as areas sharing a border are compared on them.
+		assertIds(t, serving([]string{"40135"}, &geo.Box{MinLat: -6.8, MaxLat: -6, MinLng: 106.9, MaxLng: 107.5}), "bo-1", "bo-2", "bo-3")
+		assertIds(t, serving([]string{"10110"}, &geo.Box{MinLat: 0, MaxLat: 1, MinLng: 100, MaxLng: 101}))
+		assertIds(t, serving(nil, nil))
+
+		if _, err := repo.UpdateBranchOfficeById(ctx, "bo-1", models.BranchOffice{}, []string{"service_area"}); err != nil {
+			t.Fatalf("UpdateBranchOfficeById: %v", err)
+		}
+		assertIds(t, serving(nil, &geo.Box{MinLat: -6.2, MaxLat: -6.2, MinLng: 106.8, MaxLng: 106.8}))
+	})
+
+	t.Run("GeocodeDue", func(t *testing.T) {
+		repo := newRepo(t)
+		now := time.Now().Truncate(time.Second)
+		later := now.Add(time.Hour)
+		due := branchOffice("bo-1", "Kota")
+		due.GeocodeStatus = models.BranchOfficeGeocodePending
+		retrying := branchOffice("bo-2", "Dago")
+		retrying.GeocodeStatus = models.BranchOfficeGeocodePending
+		retrying.GeocodeAttempts = 1
+		retrying.GeocodeNextAttemptAt = &later
+		geocoded := branchOffice("bo-3", "Tunjungan")
+		geocoded.GeocodeStatus = models.BranchOfficeGeocodeSucceeded
+		seed(t, repo, due, retrying, geocoded, branchOffice("bo-4", "Legacy"))
+
+		pending := []string{models.BranchOfficeGeocodePending}
+		assertIds(t, list(t, repo, repos.GetBranchOfficeListFilter{GeocodeStatuses: pending, Sort: []repos.SortField{{Column: "id"}}}), "bo-1", "bo-2")
+		assertIds(t, list(t, repo, repos.GetBranchOfficeListFilter{GeocodeStatuses: pending, GeocodeDueAt: &now}), "bo-1")
+		assertIds(t, list(t, repo, repos.GetBranchOfficeListFilter{GeocodeStatuses: pending, GeocodeDueAt: &later, Sort: []repos.SortField{{Column: "id"}}}), "bo-1", "bo-2")
+
+		lat, lng := -6.9175, 107.6191
+		res, err := repo.UpdateBranchOfficeById(ctx, "bo-2", models.BranchOffice{
+			Latitude:        &lat,
+			Longitude:       &lng,
+			GeocodeStatus:   models.BranchOfficeGeocodeSucceeded,
+			GeocodedAddress: "Jl. Ir. H. Juanda 1, Bandung",
+			GeocodeAttempts: 2,
+		}, []string{"latitude", "longitude", "geocode_status", "geocoded_address", "geocode_attempts", "geocode_next_attempt_at"})
+		if err != nil {
+			t.Fatalf("UpdateBranchOfficeById: %v", err)
+		}
+		if res.GeocodeStatus != models.BranchOfficeGeocodeSucceeded || res.GeocodedAddress == "" || res.GeocodeAttempts != 2 || res.GeocodeNextAttemptAt != nil {
+			t.Errorf("UpdateBranchOfficeById = %+v, want it geocoded after two attempts", res)
+		}
+		assertCount(t, repo, repos.GetBranchOfficeListFilter{GeocodeStatuses: pending}, 1)
+	})
+
+	t.Run("ClaimForGeocoding", func(t *testing.T) {
+		repo := newRepo(t)
+		now := time.Now().Truncate(time.Second)
+		var seeded []models.BranchOffice
+		for _, id := range []string{"bo-1", "bo-2", "bo-3"} {
+			item := branchOffice(id, id)
+			item.GeocodeStatus = models.BranchOfficeGeocodePending
+			seeded = append(seeded, item)
+		}
+		retrying := branchOffice("bo-4", "Dago")
+		retrying.GeocodeStatus = models.BranchOfficeGeocodePending
+		later := now.Add(time.Hour)
+		retrying.GeocodeNextAttemptAt = &later
+		seed(t, repo, append(seeded, retrying, branchOffice("bo-5", "Legacy"))...)
+
+		claim := func(at time.Time, limit int) []*models.BranchOffice {
+			t.Helper()
+			res, err := repo.ClaimBranchOfficesForGeocoding(ctx, at, at.Add(10*time.Minute), limit)
+			if err != nil {
+				t.Fatalf("ClaimBranchOfficesForGeocoding: %v", err)
+			}
+			return res
+		}
+		first := claim(now, 2)
+		assertIds(t, first, "bo-1", "bo-2")
+		if until := now.Add(10 * time.Minute); first[0].GeocodeNextAttemptAt == nil || !first[0].GeocodeNextAttemptAt.Equal(until) {
+			t.Errorf("claimed GeocodeNextAttemptAt = %v, want %v", first[0].GeocodeNextAttemptAt, until)
+		}
+		// Claimed branch offices are left to their worker until the claim runs out.
+		assertIds(t, claim(now, 2), "bo-3")
+		assertIds(t, claim(now, 2))
+		assertIds(t, claim(now.Add(11*time.Minute), 10), "bo-1", "bo-2", "bo-3")
+
+		res, err := repo.GetBranchOfficeById(ctx, "bo-1", false)
+		if err != nil || res.Version != 1 || res.GeocodeStatus != models.BranchOfficeGeocodePending {
+			t.Errorf("GetBranchOfficeById = %+v, %v, want it pending at its version", res, err)
+		}
+	})
+
+	t.Run("Address", func(t *testing.T) {
+		repo := newRepo(t)
+		a := branchOffice("bo-1", "Kota")
+		a.SetAddress(models.BranchOfficeAddress{
+			Street:     "Jl. Merdeka 1",
+			Rt:         "001",
+			Rw:         "002",
+			Kelurahan:  "Gambir",
+			Kecamatan:  "Gambir",
+			Province:   "DKI Jakarta",
+			PostalCode: "10110",
+			Country:    "ID",
+		})
+		b := inCity(branchOffice("bo-2", "Dago"), "Bandung")
+		b.SetAddress(models.BranchOfficeAddress{Street: "Jl. Ir. H. Juanda 1", Province: "Jawa Barat", PostalCode: "40132"})
+		seed(t, repo, a, b)
+
+		got, err := repo.GetBranchOfficeById(ctx, "bo-1", false)
+		if err != nil {
+			t.Fatalf("GetBranchOfficeById: %v", err)
+		}
+		if got.StructuredAddress() != a.StructuredAddress() || got.Address != a.Address {
+			t.Errorf("GetBranchOfficeById = %+v, want the address of %+v", got.StructuredAddress(), a.StructuredAddress())
+		}
+		got, err = repo.GetBranchOfficeById(ctx, "bo-2", false)
+		if err != nil {
+			t.Fatalf("GetBranchOfficeById: %v", err)
+		}
+		if got.Country != models.DefaultBranchOfficeCountry {
+			t.Errorf("Country = %q, want %q by default", got.Country, models.DefaultBranchOfficeCountry)
+		}
+
+		assertIds(t, list(t, repo, repos.GetBranchOfficeListFilter{Provinces: []string{"Jawa Barat"}}), "bo-2")
+		assertIds(t, list(t, repo, repos.GetBranchOfficeListFilter{AddressPostalCodes: []string{"10110", "99999"}}), "bo-1")
+
+		address := a.StructuredAddress()
+		address.Kelurahan = "Kebon Kelapa"
+		var update models.BranchOffice
+		update.SetAddress(address)
+		res, err := repo.UpdateBranchOfficeById(ctx, "bo-1", update, models.BranchOfficeAddressColumns)
+		if err != nil {
+			t.Fatalf("UpdateBranchOfficeById: %v", err)
+		}
+		if res.Kelurahan != "Kebon Kelapa" || res.Address != address.String() || res.Name != "Kota" {
+			t.Errorf("UpdateBranchOfficeById = %+v, want the new kelurahan rendered into the address", res)
+		}
+
+		res = list(t, repo, repos.GetBranchOfficeListFilter{Select: []string{"address_detail"}, Ids: []string{"bo-1"}})[0]
+		if res.StructuredAddress() != address || res.PhoneNumber != "" {
+			t.Errorf("GetBranchOfficeList = %+v, want only the address selected", res)
+		}
+
+		// The flat address rendered from the longest parts allowed fits.
+		address = models.BranchOfficeAddress{
+			Street:    strings.Repeat("s", 255),
+			Rt:        "001",
+			Rw:        "002",
+			Kelurahan: strings.Repeat("k", 100),
+			Kecamatan: strings.Repeat("c", 100),
+			Country:   "ID",
+		}
+		update = models.BranchOffice{}
+		update.SetAddress(address)
+		if res, err := repo.UpdateBranchOfficeById(ctx, "bo-2", update, models.BranchOfficeAddressColumns); err != nil || res.Address != address.String() {
+			t.Errorf("UpdateBranchOfficeById with a long address = %v, want it written", err)
+		}
+
+		hasStreet := false
+		legacy := branchOffice("bo-3", "Legacy")
+		legacy.Address = "Jl. Lama 1"
+		seed(t, repo, legacy)
+		assertIds(t, list(t, repo, repos.GetBranchOfficeListFilter{HasStreet: &hasStreet}), "bo-3")
+		hasStreet = true
+		assertIds(t, list(t, repo, repos.GetBranchOfficeListFilter{HasStreet: &hasStreet, Sort: []repos.SortField{{Column: "id"}}}), "bo-1", "bo-2")
+	})
+
+	t.Run("CityCode", func(t *testing.T) {
+		repo := newRepo(t)
+		a := inCity(branchOffice("bo-1", "Kota"), "Jakarta Pusat")
+		a.CityCode = "31.73"
+		b := inCity(branchOffice("bo-2", "Dago"), "Bandung")
+		b.CityCode = "32.73"
+		seed(t, repo, a, b, inCity(branchOffice("bo-3", "Legacy"), "Jkt"))
+
+		assertIds(t, list(t, repo, repos.GetBranchOfficeListFilter{CityCodes: []string{"31.73", "35.78"}}), "bo-1")
+		assertIds(t, list(t, repo, repos.GetBranchOfficeListFilter{CityCodes: []string{""}}), "bo-3")
+
+		res, err := repo.UpdateBranchOfficeById(ctx, "bo-3", models.BranchOffice{CityCode: "31.71"}, []string{"city_code"})
+		if err != nil {
+			t.Fatalf("UpdateBranchOfficeById: %v", err)
+		}
+		if res.CityCode != "31.71" || res.City != "Jkt" {
+			t.Errorf("UpdateBranchOfficeById = %+v, want the city code set and the city kept", res)
+		}
+		assertCount(t, repo, repos.GetBranchOfficeListFilter{CityCodes: []string{""}}, 0)
+	})
+
+	t.Run("StructuredFilters", func(t *testing.T) {
+		repo := newRepo(t)
+		a := inCity(branchOffice("bo-1", "Kota"), "Jakarta")
+		b := inCity(branchOffice("bo-2", "Dago"), "Bandung")
+		b.FaxNumber = ""
+		c := inCity(branchOffice("bo-3", "Tunjungan"), "Surabaya")
+		c.CreatedAt = time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+		seed(t, repo, a, b, c)
+
+		byCity := repos.GetBranchOfficeListFilter{Cities: []string{"Jakarta", "Bandung"}}
+		assertIds(t, list(t, repo, byCity), "bo-2", "bo-1")
+		assertCount(t, repo, byCity, 2)
+
+		byIds := repos.GetBranchOfficeListFilter{Ids: []string{"bo-1", "bo-3", "missing"}}
+		assertIds(t, list(t, repo, byIds), "bo-1", "bo-3")
+		assertCount(t, repo, byIds, 2)
+
+		from := time.Now().Add(-24 * time.Hour)
+		assertIds(t, list(t, repo, repos.GetBranchOfficeListFilter{CreatedFrom: &from}), "bo-2", "bo-1")
+		assertIds(t, list(t, repo, repos.GetBranchOfficeListFilter{CreatedTo: &from}), "bo-3")
+
+		hasFax := false
+		withoutFax := repos.GetBranchOfficeListFilter{HasFax: &hasFax}
+		assertIds(t, list(t, repo, withoutFax), "bo-2")
+		assertCount(t, repo, withoutFax, 1)
+	})
+
+	t.Run("Keyset", func(t *testing.T) {
+		repo := newRepo(t)
+		seed(t, repo,
+			branchOffice("bo-1", "Cirebon"),
+			branchOffice("bo-2", "Ambon"),
+			inCity(branchOffice("bo-3", "Bekasi"), "Surabaya"),
+			inCity(branchOffice("bo-4", "Depok"), "Surabaya"),
+			branchOffice("bo-5", "Bogor"),
+		)
+		sort := []repos.SortField{{Column: "city", Desc: true}, {Column: "name"}}
+		pivot, err := repo.GetBranchOfficeById(ctx, "bo-2", false)
+		if err != nil {
+			t.Fatalf("GetBranchOfficeById: %v", err)
+		}
+
+		limit := 2
+		after := repos.GetBranchOfficeListFilter{Sort: sort, Limit: &limit, Keyset: &repos.Keyset{Row: pivot}}
+		assertIds(t, list(t, repo, after), "bo-5", "bo-1")
+		before := repos.GetBranchOfficeListFilter{Sort: sort, Limit: &limit, Keyset: &repos.Keyset{Row: pivot, Before: true}}
+		assertIds(t, list(t, repo, before), "bo-3", "bo-4")
+		assertCount(t, repo, after, 5)
+	})
+
+	t.Run("KeysetWithoutCodes", func(t *testing.T) {
+		repo := newRepo(t)
+		for i, code := range []string{"", "JKT-0001", "", "BDG-0001", ""} {
+			item := branchOffice(fmt.Sprintf("bo-%d", i+1), fmt.Sprintf("Kantor %d", i+1))
+			item.Code = code
+			seed(t, repo, item)
+		}
+		want := []string{"bo-1", "bo-3", "bo-5", "bo-4", "bo-2"}
+
+		// Every row shows up once, whichever way the pages are walked.
+		sort := []repos.SortField{{Column: "code"}}
+		limit := 2
+		var got []string
+		filter := repos.GetBranchOfficeListFilter{Sort: sort, Limit: &limit}
+		for {
+			page := list(t, repo, filter)
+			for _, item := range page {
+				got = append(got, item.Id)
+			}
+			if len(page) < limit {
+				break
+			}
+			filter.Keyset = &repos.Keyset{Row: page[len(page)-1]}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("pages after = %v, want %v", got, want)
+		}
+
+		last, err := repo.GetBranchOfficeById(ctx, "bo-2", false)
+		if err != nil {
+			t.Fatalf("GetBranchOfficeById: %v", err)
+		}
+		got = []string{last.Id}
+		filter = repos.GetBranchOfficeListFilter{Sort: sort, Limit: &limit, Keyset: &repos.Keyset{Row: last, Before: true}}
+		for {
+			page := list(t, repo, filter)
+			for i := len(page) - 1; i >= 0; i-- {
+				got = append([]string{page[i].Id}, got...)
+			}
+			if len(page) < limit {
+				break
+			}
+			filter.Keyset = &repos.Keyset{Row: page[0], Before: true}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("pages before = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("Select", func(t *testing.T) {
+		repo := newRepo(t)
+		seed(t, repo, branchOffice("bo-1", "Malang"))
+
+		res := list(t, repo, repos.GetBranchOfficeListFilter{Select: []string{"name"}})
+		if len(res) != 1 || res[0].Id != "bo-1" || res[0].Name != "Malang" {
+			t.Fatalf("GetBranchOfficeList = %+v, want the id and the selected name", res)
+		}
+		if res[0].Address != "" || res[0].City != "" {
+			t.Errorf("GetBranchOfficeList = %+v, want columns outside the selection left empty", res[0])
+		}
+	})
+
+	t.Run("Stream", func(t *testing.T) {
+		repo := newRepo(t)
+		seed(t, repo, inCity(branchOffice("bo-1", "Malang"), "Jawa"), inCity(branchOffice("bo-2", "Blitar"), "Jawa"), inCity(branchOffice("bo-3", "Kupang"), "Nusa"))
+
+		filter := repos.GetBranchOfficeListFilter{Cities: []string{"Jawa"}, Sort: []repos.SortField{{Column: "name", Desc: true}}}
+		var res []*models.BranchOffice
+		err := repo.StreamBranchOfficeList(ctx, filter, func(branchOffice *models.BranchOffice) error {
+			res = append(res, branchOffice)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("StreamBranchOfficeList: %v", err)
+		}
+		assertIds(t, res, "bo-1", "bo-2")
+
+		stop := errors.New("stop")
+		calls := 0
+		err = repo.StreamBranchOfficeList(ctx, repos.GetBranchOfficeListFilter{}, func(branchOffice *models.BranchOffice) error {
+			calls++
+			return stop
+		})
+		if !errors.Is(err, stop) || calls != 1 {
+			t.Errorf("StreamBranchOfficeList = %v after %d calls, want the error of the first call", err, calls)
+		}
+	})
+
+	t.Run("KeywordSearch", func(t *testing.T) {
+		repo := newRepo(t)
+		a := branchOffice("bo-1", "Cabang Utama")
+		a.Address = "Jl. Sudirman 1"
+		b := branchOffice("bo-2", "Sudirman Plaza")
+		b.Address = "Jl. Thamrin 2"
+		c := branchOffice("bo-3", "Kemang")
+		c.Address = "Jl. Kemang Raya"
+		seed(t, repo, a, b, c)
+
+		keyword := "sudirman"
+		nameOnly := repos.GetBranchOfficeListFilter{Fields: &[]string{"name"}, Keyword: &keyword}
+		assertIds(t, list(t, repo, nameOnly), "bo-2")
+		assertCount(t, repo, nameOnly, 1)
+
+		nameAndAddress := repos.GetBranchOfficeListFilter{Fields: &[]string{"name", "address"}, Keyword: &keyword}
+		assertIds(t, list(t, repo, nameAndAddress), "bo-1", "bo-2")
+		assertCount(t, repo, nameAndAddress, 2)
+
+		empty := ""
+		assertCount(t, repo, repos.GetBranchOfficeListFilter{Fields: &[]string{"name"}, Keyword: &empty}, 3)
+	})
+
+	t.Run("OrderAndPagination", func(t *testing.T) {
+		repo := newRepo(t)
+		seed(t, repo,
+			branchOffice("bo-1", "Cirebon"),
+			branchOffice("bo-2", "Ambon"),
+			inCity(branchOffice("bo-3", "Bekasi"), "Surabaya"),
+			inCity(branchOffice("bo-4", "Depok"), "Surabaya"),
+			branchOffice("bo-5", "Bogor"),
+		)
+
+		assertIds(t, list(t, repo, repos.GetBranchOfficeListFilter{}), "bo-2", "bo-3", "bo-5", "bo-1", "bo-4")
+
+		sorted := repos.GetBranchOfficeListFilter{Sort: []repos.SortField{{Column: "city", Desc: true}, {Column: "name"}}}
+		assertIds(t, list(t, repo, sorted), "bo-3", "bo-4", "bo-2", "bo-5", "bo-1")
+
+		limit := 2
+		for page, want := range [][]string{{"bo-2", "bo-3"}, {"bo-5", "bo-1"}, {"bo-4"}, nil} {
+			page := page + 1
+			filter := repos.GetBranchOfficeListFilter{Limit: &limit, Page: &page}
+			t.Run(fmt.Sprintf("Page%d", page), func(t *testing.T) {
+				assertIds(t, list(t, repo, filter), want...)
+				assertCount(t, repo, filter, 5)
+			})
+		}
+	})
+}
+
+// isValidationError tells whether err rejects field, as the unique indexes do.
+func isValidationError(err error, field string) bool {
+	var validationErr *utilErrors.DBValidationError
+	return errors.As(err, &validationErr) && validationErr.Field == field
+}
+
+func branchOffice(id string, name string) models.BranchOffice {
+	return models.BranchOffice{
+		Id:          id,
+		Name:        name,
+		Address:     "Jl. Merdeka 1",
+		PhoneNumber: "021-555",
+		FaxNumber:   "021-556",
+		City:        "Jakarta",
+		CreatedBy:   "user-1",
+		UpdatedBy:   "user-1",
+	}
+}
+
+func inCity(branchOffice models.BranchOffice, city string) models.BranchOffice {
+	branchOffice.City = city
+	return branchOffice
+}
+
+func at(branchOffice models.BranchOffice, lat float64, lng float64) models.BranchOffice {
+	branchOffice.Latitude = &lat
+	branchOffice.Longitude = &lng
+	return branchOffice
+}
+
+func seed(t *testing.T, repo repos.BranchOfficeRepoInterface, branchOffices ...models.BranchOffice) {
+	t.Helper()
+	for _, branchOffice := range branchOffices {
+		if _, err := repo.CreateBranchOffice(context.Background(), branchOffice); err != nil {
+			t.Fatalf("CreateBranchOffice(%s): %v", branchOffice.Id, err)
+		}
+	}
+}
+
+func list(t *testing.T, repo repos.BranchOfficeRepoInterface, filter repos.GetBranchOfficeListFilter) []*models.BranchOffice {
+	t.Helper()
+	res, err := repo.GetBranchOfficeList(context.Background(), filter)
+	if err != nil {
+		t.Fatalf("GetBranchOfficeList: %v", err)
+	}
+	return res
+}
+
+func assertIds(t *testing.T, list []*models.BranchOffice, want ...string) {
+	t.Helper()
+	var got []string
+	for _, item := range list {
+		got = append(got, item.Id)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("ids = %v, want %v", got, want)
+	}
+}
+
+func assertCount(t *testing.T, repo repos.BranchOfficeRepoInterface, filter repos.GetBranchOfficeListFilter, want int64) {
+	t.Helper()
+	got, err := repo.GetBranchOfficeCount(context.Background(), filter)
+	if err != nil {
+		t.Fatalf("GetBranchOfficeCount: %v", err)
+	}
+	if got != want {
+		t.Errorf("GetBranchOfficeCount = %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,65 @@
+package repotest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jangkartech/twin-branch-office/pkg/models"
+	"github.com/jangkartech/twin-branch-office/pkg/repos"
+)
+
+// TestBranchOfficeAuditRepo checks newRepo against the behaviour expected from a
+// BranchOfficeAuditRepoInterface. newRepo must return a repo over an empty store each call.
+func TestBranchOfficeAuditRepo(t *testing.T, newRepo func(t *testing.T) repos.BranchOfficeAuditRepoInterface) {
+	ctx := context.Background()
+
+	t.Run("NewestFirstPerBranchOffice", func(t *testing.T) {
+		repo := newRepo(t)
+		base := time.Now().Add(-time.Hour).Truncate(time.Second)
+		name := "Jakarta"
+		for i, action := range []string{models.BranchOfficeAuditActionCreate, models.BranchOfficeAuditActionUpdate, models.BranchOfficeAuditActionSoftDelete} {
+			_, err := repo.CreateBranchOfficeAudit(ctx, models.BranchOfficeAudit{
+				BranchOfficeId: "bo-1",
+				Action:         action,
+				Actor:          "user-1",
+				Changes:        []models.BranchOfficeAuditChange{{Field: "name", After: &name}},
+				CreatedAt:      base.Add(time.Duration(i) * time.Minute),
+			})
+			if err != nil {
+				t.Fatalf("CreateBranchOfficeAudit: %v", err)
+			}
+		}
+		if _, err := repo.CreateBranchOfficeAudit(ctx, models.BranchOfficeAudit{BranchOfficeId: "bo-2", Action: models.BranchOfficeAuditActionCreate}); err != nil {
+			t.Fatalf("CreateBranchOfficeAudit: %v", err)
+		}
+
+		filter := repos.GetBranchOfficeAuditListFilter{BranchOfficeId: "bo-1"}
+		list, err := repo.GetBranchOfficeAuditList(ctx, filter)
+		if err != nil {
+			t.Fatalf("GetBranchOfficeAuditList: %v", err)
+		}
+		var actions []string
+		for _, item := range list {
+			actions = append(actions, item.Action)
+		}
+		want := []string{models.BranchOfficeAuditActionSoftDelete, models.BranchOfficeAuditActionUpdate, models.BranchOfficeAuditActionCreate}
+		if len(actions) != len(want) || actions[0] != want[0] || actions[2] != want[2] {
+			t.Errorf("actions = %v, want %v", actions, want)
+		}
+		if len(list) > 0 && (len(list[0].Changes) != 1 || list[0].Changes[0].After == nil || *list[0].Changes[0].After != name) {
+			t.Errorf("Changes = %+v, want the recorded change", list[0].Changes)
+		}
+
+		count, err := repo.GetBranchOfficeAuditCount(ctx, filter)
+		if err != nil || count != 3 {
+			t.Errorf("GetBranchOfficeAuditCount = %d, %v, want 3", count, err)
+		}
+
+		limit, page := 2, 2
+		list, err = repo.GetBranchOfficeAuditList(ctx, repos.GetBranchOfficeAuditListFilter{BranchOfficeId: "bo-1", Limit: &limit, Page: &page})
+		if err != nil || len(list) != 1 || list[0].Action != models.BranchOfficeAuditActionCreate {
+			t.Errorf("second page = %+v, %v, want the create record", list, err)
+		}
+	})
+}
@@ -0,0 +1,39 @@
+package repos
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type TransactionRepoInterface interface {
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type transactionRepo struct {
+	db *gorm.DB
+}
+
+type txContextKey struct{}
+
+func NewTransactionRepo(db *gorm.DB) TransactionRepoInterface {
+	return &transactionRepo{
+		db: db,
+	}
+}
+
+// Transaction runs fn inside a database transaction carried by the ctx passed to fn.
+// When ctx already carries a transaction the call nests into it through a savepoint.
+func (r *transactionRepo) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(ctx, txContextKey{}, tx))
+	})
+}
+
+// conn returns the transaction carried by ctx, falling back to db, bound to ctx.
+func conn(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txContextKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
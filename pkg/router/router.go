@@ -3,21 +3,107 @@ package router
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/jangkartech/twin-branch-office/pkg/controllers"
+	"github.com/jangkartech/twin-branch-office/pkg/geocode"
 	"github.com/jangkartech/twin-branch-office/pkg/repos"
 	"github.com/jangkartech/twin-branch-office/pkg/services"
+	"gorm.io/gorm"
 )
 
-func Register(route gin.IRoutes) {
-	branchOfficeRepo := repos.NewBranchOfficeRepo()
-	branchOfficeService := services.NewBranchOfficeService(branchOfficeRepo)
-	branchOfficeController := controllers.NewBranchOfficeController(branchOfficeService)
-	route.GET("/branch-offices", branchOfficeController.GetBranchOffices)
-	route.POST("/branch-office", branchOfficeController.CreateBranchOffice)
-	route.GET("/branch-office/:id", branchOfficeController.ShowBranchOffice)
-	route.PUT("/branch-office/:id", branchOfficeController.UpdateBranchOffice)
-	route.DELETE("/branch-office/:id", branchOfficeController.SoftDeleteBranchOffice)
-	route.DELETE("/branch-office/hard-delete/:id", branchOfficeController.HardDeleteBranchOffice)
-	route.PATCH("/branch-office/:id", branchOfficeController.RestoreBranchOffice)
-	route.GET("/branch-offices/simple", branchOfficeController.GetSimpleBranchOffices)
+// Dependencies holds everything Register needs to wire the branch office routes.
+type Dependencies struct {
+	DB *gorm.DB
+	// BranchCodePattern formats the codes of new branch offices, as described by
+	// services.BranchCodePattern. It defaults to services.DefaultBranchCodePattern.
+	BranchCodePattern string
+	// Geocoder resolves the addresses of branch offices to coordinates in the background.
+	// Geocoding is off when it is nil.
+	Geocoder geocode.Geocoder
+}
+
+// requestId exposes the X-Request-ID header to the services, unless an upstream
+// middleware already stored a request id on the context. It runs on the routes of
+// Register only, not on those the host mounts on the same routes.
+func requestId(ctx *gin.Context) {
+	if _, exists := ctx.Get(services.RequestIdContextKey); !exists {
+		if id := ctx.GetHeader("X-Request-ID"); id != "" {
+			ctx.Set(services.RequestIdContextKey, id)
+		}
+	}
+	ctx.Next()
+}
+
+// Register wires the branch office repo, service and controller on top of deps and
+// mounts their routes, along with those browsing the regions of the regions package.
+// Queries are bound to the request context, so enable gin.Engine.ContextWithFallback to
+// have them cancelled when the client disconnects. Register panics when
+// deps.BranchCodePattern is invalid.
+//
+// With a deps.Geocoder, Register returns the geocoding worker, for the host to start
+// with go worker.Run(ctx) on the instances that should geocode; workers claim what they
+// geocode, so any number may run. Without one it returns nil.
+func Register(route gin.IRoutes, deps Dependencies) services.BranchOfficeGeocodeServiceInterface {
+	branchOfficeService, branchOfficeAuditService, branchOfficeGeocodeService := newBranchOfficeServices(deps)
+	branchOfficeController := controllers.NewBranchOfficeController(branchOfficeService, branchOfficeAuditService)
+	regionController := controllers.NewRegionController(services.NewRegionService())
+	route.GET("/branch-offices", requestId, branchOfficeController.GetBranchOffices)
+	route.GET("/branch-offices/export", requestId, branchOfficeController.ExportBranchOffices)
+	route.POST("/branch-office", requestId, branchOfficeController.CreateBranchOffice)
+	route.POST("/branch-offices/bulk", requestId, branchOfficeController.BulkCreateBranchOffices)
+	route.POST("/branch-offices/import", requestId, branchOfficeController.ImportBranchOffices)
+	route.GET("/branch-office/:id", requestId, branchOfficeController.ShowBranchOffice)
+	route.PUT("/branch-office/:id", requestId, branchOfficeController.UpdateBranchOffice)
+	route.PATCH("/branch-office/:id", requestId, branchOfficeController.PatchBranchOffice)
+	route.DELETE("/branch-office/:id", requestId, branchOfficeController.SoftDeleteBranchOffice)
+	route.DELETE("/branch-office/hard-delete/:id", requestId, branchOfficeController.HardDeleteBranchOffice)
+	route.PATCH("/branch-office/restore/:id", requestId, branchOfficeController.RestoreBranchOffice)
+	route.POST("/branch-offices/bulk-delete", requestId, branchOfficeController.BulkSoftDeleteBranchOffices)
+	route.POST("/branch-offices/bulk-restore", requestId, branchOfficeController.BulkRestoreBranchOffices)
+	route.POST("/branch-offices/bulk-hard-delete", requestId, branchOfficeController.BulkHardDeleteBranchOffices)
+	route.POST("/branch-office/:id/transitions", requestId, branchOfficeController.TransitionBranchOffice)
+	route.PUT("/branch-office/:id/opening-hours", requestId, branchOfficeController.UpdateBranchOfficeHours)
+	route.GET("/branch-office/:id/availability", requestId, branchOfficeController.GetBranchOfficeAvailability)
+	route.PUT("/branch-office/:id/location", requestId, branchOfficeController.UpdateBranchOfficeLocation)
+	route.GET("/branch-offices/nearby", requestId, branchOfficeController.GetNearbyBranchOffices)
+	route.PUT("/branch-office/:id/service-area", requestId, branchOfficeController.UpdateBranchOfficeServiceArea)
+	route.GET("/branch-offices/resolve", requestId, branchOfficeController.ResolveBranchOffice)
+	route.GET("/branch-office/:id/history", requestId, branchOfficeController.GetBranchOfficeHistory)
+	route.GET("/branch-offices/simple", requestId, branchOfficeController.GetSimpleBranchOffices)
+	route.GET("/regions/provinces", requestId, regionController.GetProvinces)
+	route.GET("/regions/provinces/:code/regencies", requestId, regionController.GetRegencies)
+	route.GET("/regions/regencies/:code/districts", requestId, regionController.GetDistricts)
+	return branchOfficeGeocodeService
+}
+
+// RegisterMigrations mounts the one-off backfills of branch office data: city codes,
+// branch codes and structured addresses. Register leaves them out, as any caller could
+// run them; mount them behind the host's admin authentication while a migration is due,
+// and drop them once it is done.
+func RegisterMigrations(route gin.IRoutes, deps Dependencies) {
+	branchOfficeService, branchOfficeAuditService, _ := newBranchOfficeServices(deps)
+	branchOfficeController := controllers.NewBranchOfficeController(branchOfficeService, branchOfficeAuditService)
+	route.POST("/branch-offices/city-codes/migrate", requestId, branchOfficeController.MigrateBranchOfficeCityCodes)
+	route.POST("/branch-offices/codes/migrate", requestId, branchOfficeController.MigrateBranchOfficeCodes)
+	route.POST("/branch-offices/addresses/migrate", requestId, branchOfficeController.MigrateBranchOfficeAddresses)
+}
+
+// newBranchOfficeServices wires the branch office services on top of deps, with the
+// geocoding worker when deps has a geocoder. It panics when deps.BranchCodePattern is
+// invalid.
+func newBranchOfficeServices(deps Dependencies) (services.BranchOfficeServiceInterface, services.BranchOfficeAuditServiceInterface, services.BranchOfficeGeocodeServiceInterface) {
+	codePattern := deps.BranchCodePattern
+	if codePattern == "" {
+		codePattern = services.DefaultBranchCodePattern
+	}
 
+	transactionRepo := repos.NewTransactionRepo(deps.DB)
+	branchOfficeRepo := repos.NewBranchOfficeRepo(deps.DB)
+	branchOfficeAuditRepo := repos.NewBranchOfficeAuditRepo(deps.DB)
+	unitOfWork := services.NewUnitOfWork(transactionRepo)
+	branchOfficeAuditService := services.NewBranchOfficeAuditService(branchOfficeAuditRepo)
+	var branchOfficeGeocodeService services.BranchOfficeGeocodeServiceInterface
+	if deps.Geocoder != nil {
+		branchOfficeGeocodeService = services.NewBranchOfficeGeocodeService(branchOfficeRepo, branchOfficeAuditService, unitOfWork, deps.Geocoder)
+	}
+	branchOfficeService := services.NewBranchOfficeService(branchOfficeRepo, branchOfficeAuditService, unitOfWork, services.MustParseBranchCodePattern(codePattern), branchOfficeGeocodeService)
+	return branchOfficeService, branchOfficeAuditService, branchOfficeGeocodeService
 }
@@ -3,10 +3,16 @@ package services
 import (
 	"context"
 	"errors"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/jangkartech/twin-branch-office/pkg/dto"
 	"github.com/jangkartech/twin-branch-office/pkg/models"
 	"github.com/jangkartech/twin-branch-office/pkg/repos"
+	"github.com/jangkartech/twin-util/pkg/constant"
+	utilErrors "github.com/jangkartech/twin-util/pkg/errors"
 	"github.com/jangkartech/twin-util/pkg/util"
 	"gorm.io/gorm"
 )
@@ -14,36 +20,196 @@ import (
 type BranchOfficeServiceInterface interface {
 	ExistsBranchOfficeById(ctx context.Context, id string, withTrash bool) (bool, error)
 	ExistsBranchOfficeByField(ctx context.Context, input ExistsBranchOfficeByFieldInput) (bool, error)
+	ExistsBranchOfficesByField(ctx context.Context, input ExistsBranchOfficesByFieldInput) (map[string]bool, error)
+	GetBranchOfficeIdsByField(ctx context.Context, input ExistsBranchOfficesByFieldInput) (map[string]string, error)
 	GetBranchOfficeList(ctx context.Context, req dto.GetBranchOfficeRequest) ([]*models.BranchOffice, error)
 	GetBranchOfficeById(ctx context.Context, id string) (*models.BranchOffice, error)
 	CreateBranchOffice(ctx context.Context, req dto.CreateBranchOfficeRequest) (*models.BranchOffice, error)
-	UpdateBranchOfficeById(ctx context.Context, id string, req dto.UpdateBranchOfficeRequest) (*models.BranchOffice, error)
-	SoftDeleteBranchOfficeById(ctx context.Context, id string) error
-	HardDeleteBranchOfficeById(ctx context.Context, id string) error
-	RestoreBranchOfficeById(ctx context.Context, id string) error
+	BulkCreateBranchOffice(ctx context.Context, reqs []dto.CreateBranchOfficeRequest, atomic bool) ([]BulkCreateBranchOfficeResult, error)
+	ImportBranchOffices(ctx context.Context, items []ImportBranchOfficeItem, dryRun bool) (*ImportBranchOfficeResult, error)
+	UpdateBranchOfficeById(ctx context.Context, id string, req dto.UpdateBranchOfficeRequest, version *int64) (*models.BranchOffice, error)
+	PatchBranchOfficeById(ctx context.Context, id string, req dto.PatchBranchOfficeRequest, version *int64) (*models.BranchOffice, error)
+	SoftDeleteBranchOfficeById(ctx context.Context, id string, version *int64) error
+	HardDeleteBranchOfficeById(ctx context.Context, id string, version *int64) error
+	RestoreBranchOfficeById(ctx context.Context, id string, req dto.RestoreBranchOfficeRequest, version *int64) (*models.BranchOffice, error)
+	UpdateBranchOfficeServiceAreaById(ctx context.Context, id string, req dto.UpdateBranchOfficeServiceAreaRequest, version *int64) (*models.BranchOffice, error)
+	ResolveBranchOffice(ctx context.Context, req dto.ResolveBranchOfficeRequest) (*models.BranchOffice, error)
+	TransitionBranchOfficeById(ctx context.Context, id string, req dto.TransitionBranchOfficeRequest, version *int64) (*models.BranchOffice, error)
+	UpdateBranchOfficeHoursById(ctx context.Context, id string, req dto.UpdateBranchOfficeHoursRequest, version *int64) (*models.BranchOffice, error)
+	GetBranchOfficeAvailability(ctx context.Context, id string, at time.Time) (*BranchOfficeAvailability, error)
+	UpdateBranchOfficeLocationById(ctx context.Context, id string, req dto.UpdateBranchOfficeLocationRequest, version *int64) (*models.BranchOffice, error)
+	GetNearbyBranchOfficeList(ctx context.Context, req dto.GetNearbyBranchOfficeRequest) ([]*repos.BranchOfficeDistance, error)
+	BulkSoftDeleteBranchOffice(ctx context.Context, req dto.BulkBranchOfficeRequest) (*BulkBranchOfficeResult, error)
+	BulkHardDeleteBranchOffice(ctx context.Context, req dto.BulkBranchOfficeRequest) (*BulkBranchOfficeResult, error)
+	BulkRestoreBranchOffice(ctx context.Context, req dto.BulkBranchOfficeRequest) (*BulkBranchOfficeResult, error)
 	GetTotalRowsAndPages(ctx context.Context, req dto.GetBranchOfficeRequest) (int64, int64, error)
+	GetBranchOfficeListByCursor(ctx context.Context, req dto.GetBranchOfficeRequest) ([]*models.BranchOffice, *string, *string, error)
+	ExportBranchOffices(ctx context.Context, req dto.GetBranchOfficeRequest, fn func(branchOffice *models.BranchOffice) error) error
+	MigrateBranchOfficeCityCodes(ctx context.Context, dryRun bool) (*MigrateBranchOfficeCityCodesResult, error)
+	MigrateBranchOfficeCodes(ctx context.Context, dryRun bool) (*MigrateBranchOfficeCodesResult, error)
+	MigrateBranchOfficeAddresses(ctx context.Context, dryRun bool) (*MigrateBranchOfficeAddressesResult, error)
 
 	GetSimpleBranchOfficeList(ctx context.Context, req dto.GetSimpleBranchOfficeRequest) ([]*models.BranchOffice, error)
 }
+
+// BranchOfficeKeywordFields are the columns the keyword of GET /branch-offices may search.
+var BranchOfficeKeywordFields = []string{"name", "address", "code"}
+
+// BranchOfficeSortColumns are the columns GET /branch-offices may be sorted by.
+var BranchOfficeSortColumns = []string{"name", "code", "city", "address", "phone_number", "lifecycle_status", "created_at", "updated_at"}
+
+const DefaultBranchOfficeSort = "name"
+
+// BranchOfficeSelectColumns are the resource fields GET /branch-offices may be limited
+// to with the select parameter.
+var BranchOfficeSelectColumns = []string{"id", "code", "name", "address", "address_detail", "phone_number", "fax_number", "city", "city_code", "latitude", "longitude", "service_area", "postal_codes", "geocode_status", "geocoded_address", "lifecycle_status", "lifecycle_status_reason", "lifecycle_status_effective_at", "timezone", "opening_hours", "exceptions", "version", "created_at", "created_by", "updated_at", "updated_by", "deleted_by"}
+
+// SimpleBranchOfficeSelect is the projection behind GET /branch-offices/simple.
+var SimpleBranchOfficeSelect = []string{"id", "code", "name"}
+
+// Modes of a bulk create: atomic creates every item or none, partial creates the items
+// it can.
+const (
+	BulkModeAtomic  = "atomic"
+	BulkModePartial = "partial"
+)
+
+var (
+	ErrBranchOfficeNotFound        = errors.New("branch office not found")
+	ErrBranchOfficeVersionMismatch = errors.New("branch office was modified by another request")
+)
+
+// BranchOfficeConflictError reports the live branch office already holding what a write
+// would give another one: the name, or for active branch offices part of the service
+// area or a postal code. Field names which of them.
+type BranchOfficeConflictError struct {
+	Field        string
+	BranchOffice *models.BranchOffice
+}
+
+func (e *BranchOfficeConflictError) Error() string {
+	switch e.Field {
+	case "service_area":
+		return "branch office service area overlaps the one of " + e.BranchOffice.Id
+	case "postal_codes":
+		return "branch office postal code is already served by " + e.BranchOffice.Id
+	}
+	return "branch office name is already held by " + e.BranchOffice.Id
+}
+
 type ExistsBranchOfficeByFieldInput struct {
 	Field     string
 	Value     string
 	ExceptId  *string
 	WithTrash *bool
 }
+
+type ExistsBranchOfficesByFieldInput struct {
+	Field     string
+	Values    []string
+	WithTrash *bool
+}
+
+// BulkCreateBranchOfficeResult is the outcome of one item of a bulk create. Neither field
+// is set for the items of a failed atomic batch that were rolled back or never tried.
+type BulkCreateBranchOfficeResult struct {
+	BranchOffice *models.BranchOffice
+	Err          error
+}
+
+// BulkBranchOfficeResult is the outcome of a bulk soft delete, restore or hard delete.
+// NotFoundIds holds the requested ids that matched no branch office the write applies to.
+type BulkBranchOfficeResult struct {
+	Affected    int64
+	NotFoundIds []string
+}
+
 type branchOfficeService struct {
-	branchOfficeRepo repos.BranchOfficeRepoInterface
+	branchOfficeRepo         repos.BranchOfficeRepoInterface
+	branchOfficeAuditService BranchOfficeAuditServiceInterface
+	unitOfWork               UnitOfWorkInterface
+	codePattern              BranchCodePattern
+	geocodeService           BranchOfficeGeocodeServiceInterface
 }
 
-func NewBranchOfficeService(branchOfficeRepo repos.BranchOfficeRepoInterface) BranchOfficeServiceInterface {
+// NewBranchOfficeService returns the branch office service. Addresses are geocoded by
+// geocodeService, and not at all when it is nil.
+func NewBranchOfficeService(branchOfficeRepo repos.BranchOfficeRepoInterface, branchOfficeAuditService BranchOfficeAuditServiceInterface, unitOfWork UnitOfWorkInterface, codePattern BranchCodePattern, geocodeService BranchOfficeGeocodeServiceInterface) BranchOfficeServiceInterface {
 	return &branchOfficeService{
-		branchOfficeRepo: branchOfficeRepo,
+		branchOfficeRepo:         branchOfficeRepo,
+		branchOfficeAuditService: branchOfficeAuditService,
+		unitOfWork:               unitOfWork,
+		codePattern:              codePattern,
+		geocodeService:           geocodeService,
 	}
 }
 
+// ParseBranchOfficeSort parses a sort parameter such as "-created_at,city" into the
+// fields to order by. It returns false when a column is not in BranchOfficeSortColumns
+// or is repeated.
+func ParseBranchOfficeSort(sort string) ([]repos.SortField, bool) {
+	var res []repos.SortField
+	seen := map[string]bool{}
+	for _, item := range strings.Split(sort, ",") {
+		item = strings.TrimSpace(item)
+		field := repos.SortField{Column: strings.TrimPrefix(item, "-"), Desc: strings.HasPrefix(item, "-")}
+		if !slices.Contains(BranchOfficeSortColumns, field.Column) || seen[field.Column] {
+			return nil, false
+		}
+		seen[field.Column] = true
+		res = append(res, field)
+	}
+	return res, true
+}
+
+// splitListParam accepts both repeated (city=a&city=b) and comma separated
+// (city=a,b) query parameters.
+func splitListParam(values *[]string) []string {
+	if values == nil {
+		return nil
+	}
+	var res []string
+	for _, value := range *values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				res = append(res, item)
+			}
+		}
+	}
+	return res
+}
+
+// ParseBranchOfficeSelect parses a select parameter into the fields to return, without
+// duplicates. It returns false when a field is not in BranchOfficeSelectColumns.
+func ParseBranchOfficeSelect(values []string) ([]string, bool) {
+	var res []string
+	for _, field := range splitListParam(&values) {
+		if !slices.Contains(BranchOfficeSelectColumns, field) {
+			return nil, false
+		}
+		if !slices.Contains(res, field) {
+			res = append(res, field)
+		}
+	}
+	return res, true
+}
+
+// FormatBranchOfficeSort is the inverse of ParseBranchOfficeSort.
+func FormatBranchOfficeSort(sort []repos.SortField) string {
+	items := make([]string, 0, len(sort))
+	for _, field := range sort {
+		if field.Desc {
+			items = append(items, "-"+field.Column)
+		} else {
+			items = append(items, field.Column)
+		}
+	}
+	return strings.Join(items, ",")
+}
+
 func (s *branchOfficeService) convertToBranchOfficeListFilter(req dto.GetBranchOfficeRequest) repos.GetBranchOfficeListFilter {
 	if req.Fields != nil {
-		fields := util.ClearInvalidFields(*req.Fields, []string{"name", "address"})
+		fields := util.ClearInvalidFields(*req.Fields, BranchOfficeKeywordFields)
 		req.Fields = &fields
 	} else {
 		req.Fields = &[]string{"name"}
@@ -54,15 +220,71 @@ func (s *branchOfficeService) convertToBranchOfficeListFilter(req dto.GetBranchO
 		req.Keyword = &keyword
 	}
 
+	var sort []repos.SortField
+	if req.Sort != nil {
+		sort, _ = ParseBranchOfficeSort(*req.Sort)
+	}
+
+	var createdFrom, createdTo, openAt *time.Time
+	if req.CreatedFrom != nil {
+		t := time.Unix(*req.CreatedFrom, 0)
+		createdFrom = &t
+	}
+	if req.CreatedTo != nil {
+		t := time.Unix(*req.CreatedTo, 0)
+		createdTo = &t
+	}
+	if req.OpenNow != nil && *req.OpenNow {
+		t := time.Now()
+		openAt = &t
+	} else if req.OpenAt != nil {
+		t := time.Unix(*req.OpenAt, 0)
+		openAt = &t
+	}
+
 	return repos.GetBranchOfficeListFilter{
-		Fields:  req.Fields,
-		Keyword: req.Keyword,
-		Limit:   req.Limit,
-		Page:    req.Page,
-		Status:  req.Status,
+		Cities:             splitListParam(req.City),
+		CityCodes:          splitListParam(req.CityCode),
+		Provinces:          splitListParam(req.Province),
+		AddressPostalCodes: splitListParam(req.PostalCode),
+		Codes:              splitListParam(req.Code),
+		LifecycleStatuses:  splitListParam(req.LifecycleStatus),
+		GeocodeStatuses:    splitListParam(req.GeocodeStatus),
+		Ids:                splitListParam(req.Ids),
+		CreatedFrom:        createdFrom,
+		CreatedTo:          createdTo,
+		HasFax:             req.HasFax,
+		OpenAt:             openAt,
+		Select:             splitListParam(req.Select),
+		Sort:               sort,
+		Fields:             req.Fields,
+		Keyword:            req.Keyword,
+		Limit:              req.Limit,
+		Page:               req.Page,
+		Status:             req.Status,
+		CreatedBy:          req.CreatedBy,
+		UpdatedBy:          req.UpdatedBy,
+		DeletedBy:          req.DeletedBy,
 	}
 }
 
+// lockBranchOffice loads and row-locks the branch office so the write that follows in
+// the same unit of work cannot race with a concurrent one. When version is given the
+// branch office must still be at that version.
+func (s *branchOfficeService) lockBranchOffice(ctx context.Context, id string, withTrash bool, version *int64) (*models.BranchOffice, error) {
+	branchOffice, err := s.branchOfficeRepo.LockBranchOfficeById(ctx, id, withTrash)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBranchOfficeNotFound
+		}
+		return nil, err
+	}
+	if version != nil && *version != branchOffice.Version {
+		return nil, ErrBranchOfficeVersionMismatch
+	}
+	return branchOffice, nil
+}
+
 func (s *branchOfficeService) ExistsBranchOfficeById(ctx context.Context, id string, withTrash bool) (bool, error) {
 	branchOffice, err := s.branchOfficeRepo.GetBranchOfficeById(ctx, id, withTrash)
 	if err != nil {
@@ -93,6 +315,61 @@ func (s *branchOfficeService) ExistsBranchOfficeByField(ctx context.Context, inp
 	return false, nil
 }
 
+// ExistsBranchOfficesByField returns which of input.Values are already held by a branch
+// office, with a single query.
+func (s *branchOfficeService) ExistsBranchOfficesByField(ctx context.Context, input ExistsBranchOfficesByFieldInput) (map[string]bool, error) {
+	ids, err := s.GetBranchOfficeIdsByField(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]bool, len(ids))
+	for value := range ids {
+		res[value] = true
+	}
+	return res, nil
+}
+
+// GetBranchOfficeIdsByField maps each of input.Values held by a branch office to the id
+// of that branch office, with a single query. Names match as repos.BranchOfficeLookupKey
+// says.
+func (s *branchOfficeService) GetBranchOfficeIdsByField(ctx context.Context, input ExistsBranchOfficesByFieldInput) (map[string]string, error) {
+	res := map[string]string{}
+	if len(input.Values) == 0 {
+		return res, nil
+	}
+	withTrash := false
+	if input.WithTrash != nil {
+		withTrash = *input.WithTrash
+	}
+	branchOffices, err := s.branchOfficeRepo.GetBranchOfficesByField(ctx, input.Field, input.Values, withTrash)
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]string, len(branchOffices))
+	for _, branchOffice := range branchOffices {
+		ids[repos.BranchOfficeLookupKey(input.Field, branchOfficeColumnValue(branchOffice, input.Field))] = branchOffice.Id
+	}
+	for _, value := range input.Values {
+		if id, ok := ids[repos.BranchOfficeLookupKey(input.Field, value)]; ok {
+			res[value] = id
+		}
+	}
+	return res, nil
+}
+
+// branchOfficeColumnValue returns the value of the lookup column of m.
+func branchOfficeColumnValue(m *models.BranchOffice, field string) string {
+	switch field {
+	case "id":
+		return m.Id
+	case "name":
+		return m.Name
+	case "city":
+		return m.City
+	}
+	return ""
+}
+
 func (s *branchOfficeService) GetBranchOfficeList(ctx context.Context, req dto.GetBranchOfficeRequest) ([]*models.BranchOffice, error) {
 	filter := s.convertToBranchOfficeListFilter(req)
 
@@ -112,71 +389,605 @@ func (s *branchOfficeService) GetBranchOfficeById(ctx context.Context, id string
 }
 
 func (s *branchOfficeService) CreateBranchOffice(ctx context.Context, req dto.CreateBranchOfficeRequest) (*models.BranchOffice, error) {
+	var res *models.BranchOffice
+	err := s.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		var err error
+		res, err = s.createBranchOffice(ctx, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.notifyGeocoder()
+	return res, nil
+}
+
+// BulkCreateBranchOffice creates the branch offices of reqs. When atomic is set they are
+// created in one unit of work and the first failure rolls all of them back; otherwise
+// each one commits on its own and a failure only affects its item. The results follow
+// the order of reqs.
+func (s *branchOfficeService) BulkCreateBranchOffice(ctx context.Context, reqs []dto.CreateBranchOfficeRequest, atomic bool) ([]BulkCreateBranchOfficeResult, error) {
+	res := make([]BulkCreateBranchOfficeResult, len(reqs))
+	if !atomic {
+		for i, req := range reqs {
+			err := s.unitOfWork.Do(ctx, func(ctx context.Context) error {
+				var err error
+				res[i].BranchOffice, err = s.createBranchOffice(ctx, req)
+				return err
+			})
+			if err != nil {
+				res[i] = BulkCreateBranchOfficeResult{Err: err}
+			}
+		}
+		s.notifyGeocoder()
+		return res, nil
+	}
+
+	failed := -1
+	err := s.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		for i, req := range reqs {
+			branchOffice, err := s.createBranchOffice(ctx, req)
+			if err != nil {
+				failed = i
+				return err
+			}
+			res[i].BranchOffice = branchOffice
+		}
+		return nil
+	})
+	if err != nil {
+		if failed < 0 {
+			return nil, err
+		}
+		res = make([]BulkCreateBranchOfficeResult, len(reqs))
+		res[failed].Err = err
+		return res, nil
+	}
+	s.notifyGeocoder()
+	return res, nil
+}
+
+// createBranchOffice creates the branch office, with a UUIDv7 id unless req has one and
+// the next branch code of its city, and records its audit entry. Callers run it inside
+// a unit of work.
+func (s *branchOfficeService) createBranchOffice(ctx context.Context, req dto.CreateBranchOfficeRequest) (*models.BranchOffice, error) {
+	// Checked first, so a rejected branch office takes no code sequence.
+	if err := checkBranchOfficeCityCode(req.City, req.CityCode); err != nil {
+		return nil, err
+	}
+	id := req.Id
+	if id == "" {
+		generated, err := uuid.NewV7()
+		if err != nil {
+			return nil, err
+		}
+		id = generated.String()
+	}
+
+	scope := s.codePattern.scope(req.City)
+	sequence, err := s.branchOfficeRepo.NextBranchOfficeCodeSequence(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	status := req.LifecycleStatus
+	if status == "" {
+		status = models.BranchOfficeStatusActive
+	}
+	timezone := req.Timezone
+	if timezone == "" {
+		timezone = models.DefaultBranchOfficeTimezone
+	}
+	now := time.Now()
+
 	branchOffice := models.BranchOffice{
-		Id:          req.Id,
-		Name:        req.Name,
-		Address:     req.Address,
-		PhoneNumber: req.PhoneNumber,
-		FaxNumber:   req.FaxNumber,
-		City:        req.City,
+		Id:                         id,
+		Code:                       s.codePattern.code(scope, sequence),
+		Name:                       req.Name,
+		PhoneNumber:                req.PhoneNumber,
+		FaxNumber:                  req.FaxNumber,
+		City:                       req.City,
+		CityCode:                   req.CityCode,
+		Latitude:                   req.Latitude,
+		Longitude:                  req.Longitude,
+		ServiceArea:                req.ServiceArea,
+		PostalCodes:                models.PostalCodesFromDto(req.PostalCodes),
+		LifecycleStatus:            status,
+		LifecycleStatusEffectiveAt: &now,
+		Timezone:                   timezone,
+		OpeningHours:               models.OpeningHoursFromDto(req.OpeningHours),
+		Exceptions:                 models.HoursExceptionsFromDto(req.Exceptions),
+		Version:                    1,
+		CreatedBy:                  actorFromContext(ctx),
+		UpdatedBy:                  actorFromContext(ctx),
+	}
+	if req.AddressDetail != nil {
+		branchOffice.SetAddress(models.BranchOfficeAddressFromDto(*req.AddressDetail))
+	} else {
+		branchOffice.SetFlatAddress(req.Address, models.ParseBranchOfficeAddress(req.Address, req.City))
+	}
+	if branchOffice.Latitude != nil {
+		branchOffice.GeocodeStatus = models.BranchOfficeGeocodeManual
+	} else {
+		s.queueGeocode(&branchOffice)
+	}
+	if err := s.checkBranchOfficeServiceAreaFree(ctx, &branchOffice); err != nil {
+		return nil, err
 	}
 	res, err := s.branchOfficeRepo.CreateBranchOffice(ctx, branchOffice)
 	if err != nil {
 		return nil, err
 	}
+	err = s.branchOfficeAuditService.RecordBranchOfficeAudit(ctx, RecordBranchOfficeAuditInput{
+		Action:         models.BranchOfficeAuditActionCreate,
+		BranchOfficeId: res.Id,
+		After:          res,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// UpdateBranchOfficeById replaces every editable field of the branch office, as a merge
+// patch that sets all of them would, except that a missing city code is kept.
+func (s *branchOfficeService) UpdateBranchOfficeById(ctx context.Context, id string, req dto.UpdateBranchOfficeRequest, version *int64) (*models.BranchOffice, error) {
+	patch := dto.PatchBranchOfficeRequest{
+		Name:        dto.PatchString{Set: true, Value: req.Name},
+		PhoneNumber: dto.PatchString{Set: true, Value: req.PhoneNumber},
+		City:        dto.PatchString{Set: true, Value: req.City},
+		CityCode:    dto.PatchString{Set: req.CityCode != "", Value: req.CityCode},
+		FaxNumber:   dto.PatchString{Set: true, Null: req.FaxNumber == "", Value: req.FaxNumber},
+	}
+	if req.AddressDetail != nil {
+		// Every member is set, so the structured address is replaced as a whole.
+		patch.AddressDetail = patchAllBranchOfficeAddress(*req.AddressDetail)
+	} else {
+		patch.Address = dto.PatchString{Set: true, Value: req.Address}
+	}
+	return s.PatchBranchOfficeById(ctx, id, patch, version)
+}
+
+func (s *branchOfficeService) PatchBranchOfficeById(ctx context.Context, id string, req dto.PatchBranchOfficeRequest, version *int64) (*models.BranchOffice, error) {
+	var res *models.BranchOffice
+	err := s.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		current, err := s.lockBranchOffice(ctx, id, false, version)
+		if err != nil {
+			return err
+		}
+		res, err = s.updateBranchOffice(ctx, current, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.notifyGeocoder()
 	return res, nil
 }
 
-func (s *branchOfficeService) UpdateBranchOfficeById(ctx context.Context, id string, req dto.UpdateBranchOfficeRequest) (*models.BranchOffice, error) {
-	branchOffice := models.BranchOffice{}
+// updateBranchOffice applies the merge patch req to current, which the caller has locked
+// in its unit of work, and records the audit entry. Only the members present in req are
+// written, null ones as empty.
+func (s *branchOfficeService) updateBranchOffice(ctx context.Context, current *models.BranchOffice, req dto.PatchBranchOfficeRequest) (*models.BranchOffice, error) {
+	branchOffice := models.BranchOffice{
+		Version:   current.Version + 1,
+		UpdatedBy: actorFromContext(ctx),
+	}
+	columns := []string{"version", "updated_by"}
 
-	if req.Name != nil {
-		branchOffice.Name = *req.Name
+	if req.Name.Set {
+		branchOffice.Name = req.Name.Value
+		columns = append(columns, "name")
+	}
+	address, addressSet := patchedBranchOfficeAddress(current, req)
+	if addressSet {
+		// The country and postal code may come from different sides of the patch.
+		if !address.ValidPostalCode() {
+			return nil, &utilErrors.DBValidationError{Field: "address_detail", Tag: "postcode_iso3166_alpha2"}
+		}
+		if req.Address.Set {
+			branchOffice.SetFlatAddress(req.Address.Value, address)
+		} else {
+			branchOffice.SetAddress(address)
+		}
+		columns = append(columns, models.BranchOfficeAddressColumns...)
 	}
-	if req.Address != nil {
-		branchOffice.Address = *req.Address
+	if req.PhoneNumber.Set {
+		branchOffice.PhoneNumber = req.PhoneNumber.Value
+		columns = append(columns, "phone_number")
 	}
-	if req.PhoneNumber != nil {
-		branchOffice.PhoneNumber = *req.PhoneNumber
+	if req.City.Set {
+		branchOffice.City = req.City.Value
+		columns = append(columns, "city")
+	}
+	if req.CityCode.Set {
+		branchOffice.CityCode = req.CityCode.Value
+		columns = append(columns, "city_code")
+	}
+	if req.City.Set || req.CityCode.Set {
+		city, cityCode := current.City, current.CityCode
+		if req.City.Set {
+			city = req.City.Value
+		}
+		if req.CityCode.Set {
+			cityCode = req.CityCode.Value
+		}
+		if err := checkBranchOfficeCityCode(city, cityCode); err != nil {
+			return nil, err
+		}
 	}
-	if req.City != nil {
-		branchOffice.City = *req.City
+	if req.FaxNumber.Set {
+		branchOffice.FaxNumber = req.FaxNumber.Value
+		columns = append(columns, "fax_number")
 	}
-	if req.FaxNumber != nil {
-		branchOffice.FaxNumber = *req.FaxNumber
+	// Coordinates given by hand stay, whatever the address becomes.
+	addressChanged := addressSet && address != current.StructuredAddress() || req.City.Set && req.City.Value != current.City
+	if addressChanged && current.GeocodeStatus != models.BranchOfficeGeocodeManual {
+		columns = append(columns, s.queueGeocode(&branchOffice)...)
 	}
 
-	res, err := s.branchOfficeRepo.UpdateBranchOfficeById(ctx, id, branchOffice)
+	res, err := s.branchOfficeRepo.UpdateBranchOfficeById(ctx, current.Id, branchOffice, columns)
+	if err != nil {
+		return nil, err
+	}
+	err = s.branchOfficeAuditService.RecordBranchOfficeAudit(ctx, RecordBranchOfficeAuditInput{
+		Action:         models.BranchOfficeAuditActionUpdate,
+		BranchOfficeId: current.Id,
+		Before:         current,
+		After:          res,
+	})
 	if err != nil {
 		return nil, err
 	}
 	return res, nil
 }
 
-func (s *branchOfficeService) SoftDeleteBranchOfficeById(ctx context.Context, id string) error {
-	err := s.branchOfficeRepo.SoftDeleteBranchOfficeById(ctx, id)
+func (s *branchOfficeService) SoftDeleteBranchOfficeById(ctx context.Context, id string, version *int64) error {
+	err := s.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		if _, err := s.lockBranchOffice(ctx, id, false, version); err != nil {
+			return err
+		}
+		if err := s.branchOfficeRepo.SoftDeleteBranchOfficeById(ctx, id, actorFromContext(ctx)); err != nil {
+			return err
+		}
+		return s.branchOfficeAuditService.RecordBranchOfficeAudit(ctx, RecordBranchOfficeAuditInput{
+			Action:         models.BranchOfficeAuditActionSoftDelete,
+			BranchOfficeId: id,
+		})
+	})
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (s *branchOfficeService) HardDeleteBranchOfficeById(ctx context.Context, id string) error {
-	err := s.branchOfficeRepo.HardDeleteBranchOfficeById(ctx, id)
+func (s *branchOfficeService) HardDeleteBranchOfficeById(ctx context.Context, id string, version *int64) error {
+	err := s.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		current, err := s.lockBranchOffice(ctx, id, true, version)
+		if err != nil {
+			return err
+		}
+		if err := s.branchOfficeRepo.HardDeleteBranchOfficeById(ctx, id); err != nil {
+			return err
+		}
+		return s.branchOfficeAuditService.RecordBranchOfficeAudit(ctx, RecordBranchOfficeAuditInput{
+			Action:         models.BranchOfficeAuditActionHardDelete,
+			BranchOfficeId: id,
+			Before:         current,
+		})
+	})
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (s *branchOfficeService) RestoreBranchOfficeById(ctx context.Context, id string) error {
-	err := s.branchOfficeRepo.RestoreBranchOfficeById(ctx, id)
+// RestoreBranchOfficeById brings a soft-deleted branch office back, renamed to req.Name
+// when given. It fails with a BranchOfficeConflictError when a live branch office has
+// taken the name in the meantime.
+func (s *branchOfficeService) RestoreBranchOfficeById(ctx context.Context, id string, req dto.RestoreBranchOfficeRequest, version *int64) (*models.BranchOffice, error) {
+	var res *models.BranchOffice
+	err := s.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		current, err := s.lockBranchOffice(ctx, id, true, version)
+		if err != nil {
+			return err
+		}
+
+		name, rename := current.Name, ""
+		if req.Name != nil && *req.Name != current.Name {
+			name, rename = *req.Name, *req.Name
+		}
+		if err := s.checkBranchOfficeNameFree(ctx, id, name); err != nil {
+			return err
+		}
+		// In a savepoint of its own, so the transaction survives the name index failing.
+		err = s.unitOfWork.Do(ctx, func(ctx context.Context) error {
+			return s.branchOfficeRepo.RestoreBranchOfficeById(ctx, id, rename, actorFromContext(ctx))
+		})
+		if err != nil {
+			// The name index catches a branch office created since the check.
+			var validationErr *utilErrors.DBValidationError
+			if errors.As(err, &validationErr) && validationErr.Field == "name" {
+				if conflictErr := s.checkBranchOfficeNameFree(ctx, id, name); conflictErr != nil {
+					return conflictErr
+				}
+			}
+			return err
+		}
+
+		res, err = s.branchOfficeRepo.GetBranchOfficeById(ctx, id, false)
+		if err != nil {
+			return err
+		}
+		if err := s.checkBranchOfficeServiceAreaFree(ctx, res); err != nil {
+			return err
+		}
+		return s.branchOfficeAuditService.RecordBranchOfficeAudit(ctx, RecordBranchOfficeAuditInput{
+			Action:         models.BranchOfficeAuditActionRestore,
+			BranchOfficeId: id,
+			Before:         current,
+			After:          res,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// patchAllBranchOfficeAddress patches every member of the structured address.
+func patchAllBranchOfficeAddress(address dto.BranchOfficeAddress) dto.PatchBranchOfficeAddress {
+	return dto.PatchBranchOfficeAddress{
+		Set:        true,
+		Street:     dto.PatchString{Set: true, Value: address.Street},
+		Rt:         dto.PatchString{Set: true, Value: address.Rt},
+		Rw:         dto.PatchString{Set: true, Value: address.Rw},
+		Kelurahan:  dto.PatchString{Set: true, Value: address.Kelurahan},
+		Kecamatan:  dto.PatchString{Set: true, Value: address.Kecamatan},
+		Province:   dto.PatchString{Set: true, Value: address.Province},
+		PostalCode: dto.PatchString{Set: true, Value: address.PostalCode},
+		Country:    dto.PatchString{Set: true, Value: address.Country},
+	}
+}
+
+// patchedBranchOfficeAddress returns the structured address of current once req is
+// applied, and whether req edits it at all. A flat address replaces the parts it
+// renders, and an address_detail is merged member by member.
+func patchedBranchOfficeAddress(current *models.BranchOffice, req dto.PatchBranchOfficeRequest) (models.BranchOfficeAddress, bool) {
+	address := current.StructuredAddress()
+	switch {
+	case req.Address.Set:
+		city := current.City
+		if req.City.Set {
+			city = req.City.Value
+		}
+		return address.WithFlatAddress(req.Address.Value, city), true
+	case req.AddressDetail.Set:
+		return address.Patch(req.AddressDetail), true
+	}
+	return address, false
+}
+
+// queueGeocode marks branchOffice pending for the geocoder, or off when there is none,
+// and returns the columns it set. The coordinates it has stay until new ones are found.
+func (s *branchOfficeService) queueGeocode(branchOffice *models.BranchOffice) []string {
+	if s.geocodeService == nil {
+		branchOffice.GeocodeStatus = models.BranchOfficeGeocodeOff
+		return []string{"geocode_status"}
+	}
+	branchOffice.GeocodeStatus = models.BranchOfficeGeocodePending
+	branchOffice.GeocodeAttempts = 0
+	branchOffice.GeocodeNextAttemptAt = nil
+	return []string{"geocode_status", "geocode_attempts", "geocode_next_attempt_at"}
+}
+
+// notifyGeocoder wakes the geocoder after a write that may have queued branch offices.
+func (s *branchOfficeService) notifyGeocoder() {
+	if s.geocodeService != nil {
+		s.geocodeService.Notify()
+	}
+}
+
+// checkBranchOfficeNameFree returns a BranchOfficeConflictError when a live branch
+// office other than id holds name.
+func (s *branchOfficeService) checkBranchOfficeNameFree(ctx context.Context, id string, name string) error {
+	holder, err := s.branchOfficeRepo.GetBranchOfficeByField(ctx, "name", name, false)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
 	if err != nil {
 		return err
 	}
+	if holder.Id != id {
+		return &BranchOfficeConflictError{Field: "name", BranchOffice: holder}
+	}
 	return nil
 }
 
+// checkRestoredBranchOfficeNamesFree returns a BranchOfficeConflictError when one of the
+// trashed branch offices with the given ids would share its name, once restored, with a
+// live branch office or with another one of them.
+func (s *branchOfficeService) checkRestoredBranchOfficeNamesFree(ctx context.Context, ids []string) error {
+	restored, err := s.branchOfficeRepo.GetBranchOfficesByField(ctx, "id", ids, true)
+	if err != nil {
+		return err
+	}
+	slices.SortFunc(restored, func(a, b *models.BranchOffice) int {
+		return strings.Compare(a.Id, b.Id)
+	})
+	names := make([]string, 0, len(restored))
+	for _, branchOffice := range restored {
+		names = append(names, branchOffice.Name)
+	}
+	holders, err := s.branchOfficeRepo.GetBranchOfficesByField(ctx, "name", names, false)
+	if err != nil {
+		return err
+	}
+
+	byName := map[string]*models.BranchOffice{}
+	for _, holder := range holders {
+		byName[models.BranchOfficeNameKey(holder.Name)] = holder
+	}
+	for _, branchOffice := range restored {
+		if !branchOffice.DeletedAt.Valid {
+			continue
+		}
+		key := models.BranchOfficeNameKey(branchOffice.Name)
+		if holder, ok := byName[key]; ok && holder.Id != branchOffice.Id {
+			return &BranchOfficeConflictError{Field: "name", BranchOffice: holder}
+		}
+		byName[key] = branchOffice
+	}
+	return nil
+}
+
+// convertToBulkBranchOfficeFilter selects the targets of a bulk write, by id or with the
+// list filters. It fails when the filter, as converted, would select every branch office,
+// as it does once the blank values dropped on the way leave nothing to filter on.
+func (s *branchOfficeService) convertToBulkBranchOfficeFilter(req dto.BulkBranchOfficeRequest) (repos.GetBranchOfficeListFilter, error) {
+	if req.Filter == nil {
+		return repos.GetBranchOfficeListFilter{Ids: req.Ids}, nil
+	}
+	filter := s.convertToBranchOfficeListFilter(dto.GetBranchOfficeRequest{
+		Fields:          req.Filter.Fields,
+		Keyword:         req.Filter.Keyword,
+		Status:          req.Filter.Status,
+		CreatedBy:       req.Filter.CreatedBy,
+		UpdatedBy:       req.Filter.UpdatedBy,
+		DeletedBy:       req.Filter.DeletedBy,
+		City:            req.Filter.City,
+		Code:            req.Filter.Code,
+		LifecycleStatus: req.Filter.LifecycleStatus,
+		CreatedFrom:     req.Filter.CreatedFrom,
+		CreatedTo:       req.Filter.CreatedTo,
+		HasFax:          req.Filter.HasFax,
+	})
+	keyword := filter.Keyword != nil && *filter.Keyword != "" && filter.Fields != nil && len(*filter.Fields) > 0
+	if !keyword && len(filter.Ids) == 0 && len(filter.Cities) == 0 && len(filter.Codes) == 0 && len(filter.LifecycleStatuses) == 0 &&
+		filter.CreatedBy == nil && filter.UpdatedBy == nil && filter.DeletedBy == nil &&
+		filter.CreatedFrom == nil && filter.CreatedTo == nil && filter.HasFax == nil && filter.Status == nil {
+		return filter, &utilErrors.DBValidationError{Field: "filter", Tag: "required"}
+	}
+	return filter, nil
+}
+
+// bulkBranchOffice locks the branch offices matching filter, applies write to them and
+// records an audit entry of action for each one, all in one unit of work.
+func (s *branchOfficeService) bulkBranchOffice(ctx context.Context, ids []string, filter repos.GetBranchOfficeListFilter, action string, write func(ctx context.Context, ids []string) (int64, error)) (*BulkBranchOfficeResult, error) {
+	res := &BulkBranchOfficeResult{NotFoundIds: []string{}}
+	err := s.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		targets, err := s.branchOfficeRepo.LockBranchOfficeList(ctx, filter)
+		if err != nil {
+			return err
+		}
+
+		found := map[string]bool{}
+		targetIds := make([]string, 0, len(targets))
+		for _, target := range targets {
+			found[target.Id] = true
+			targetIds = append(targetIds, target.Id)
+		}
+		for _, id := range ids {
+			if !found[id] && !slices.Contains(res.NotFoundIds, id) {
+				res.NotFoundIds = append(res.NotFoundIds, id)
+			}
+		}
+		if len(targetIds) == 0 {
+			return nil
+		}
+
+		if res.Affected, err = write(ctx, targetIds); err != nil {
+			return err
+		}
+		for _, target := range targets {
+			input := RecordBranchOfficeAuditInput{
+				Action:         action,
+				BranchOfficeId: target.Id,
+			}
+			// Like a single hard delete, keep the removed values in the trail.
+			if action == models.BranchOfficeAuditActionHardDelete {
+				input.Before = target
+			}
+			if err := s.branchOfficeAuditService.RecordBranchOfficeAudit(ctx, input); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// BulkSoftDeleteBranchOffice soft deletes the live branch offices selected by req.
+func (s *branchOfficeService) BulkSoftDeleteBranchOffice(ctx context.Context, req dto.BulkBranchOfficeRequest) (*BulkBranchOfficeResult, error) {
+	filter, err := s.convertToBulkBranchOfficeFilter(req)
+	if err != nil {
+		return nil, err
+	}
+	filter.Status = nil
+	deletedBy := actorFromContext(ctx)
+	return s.bulkBranchOffice(ctx, req.Ids, filter, models.BranchOfficeAuditActionSoftDelete, func(ctx context.Context, ids []string) (int64, error) {
+		return s.branchOfficeRepo.SoftDeleteBranchOfficesByIds(ctx, ids, deletedBy)
+	})
+}
+
+// BulkHardDeleteBranchOffice permanently deletes the branch offices selected by req, live
+// or trashed unless the filter asks for the trashed ones only.
+func (s *branchOfficeService) BulkHardDeleteBranchOffice(ctx context.Context, req dto.BulkBranchOfficeRequest) (*BulkBranchOfficeResult, error) {
+	filter, err := s.convertToBulkBranchOfficeFilter(req)
+	if err != nil {
+		return nil, err
+	}
+	filter.WithTrash = true
+	return s.bulkBranchOffice(ctx, req.Ids, filter, models.BranchOfficeAuditActionHardDelete, s.branchOfficeRepo.HardDeleteBranchOfficesByIds)
+}
+
+// BulkRestoreBranchOffice restores the trashed branch offices selected by req. Like a
+// single restore, it fails with a BranchOfficeConflictError when a restored branch
+// office would share its name with a live one, restored or not, or when a restored
+// active one would share its service area or postal codes with another live active one.
+func (s *branchOfficeService) BulkRestoreBranchOffice(ctx context.Context, req dto.BulkBranchOfficeRequest) (*BulkBranchOfficeResult, error) {
+	filter, err := s.convertToBulkBranchOfficeFilter(req)
+	if err != nil {
+		return nil, err
+	}
+	deleted := constant.StatusDeleted
+	filter.Status = &deleted
+	restoredBy := actorFromContext(ctx)
+	return s.bulkBranchOffice(ctx, req.Ids, filter, models.BranchOfficeAuditActionRestore, func(ctx context.Context, ids []string) (int64, error) {
+		if err := s.checkRestoredBranchOfficeNamesFree(ctx, ids); err != nil {
+			return 0, err
+		}
+		var res int64
+		err := s.unitOfWork.Do(ctx, func(ctx context.Context) (err error) {
+			res, err = s.branchOfficeRepo.RestoreBranchOfficesByIds(ctx, ids, restoredBy)
+			return err
+		})
+		if err != nil {
+			// The name index catches a branch office created since the check.
+			var validationErr *utilErrors.DBValidationError
+			if errors.As(err, &validationErr) && validationErr.Field == "name" {
+				if conflictErr := s.checkRestoredBranchOfficeNamesFree(ctx, ids); conflictErr != nil {
+					return 0, conflictErr
+				}
+			}
+			return 0, err
+		}
+		restored, err := s.branchOfficeRepo.GetBranchOfficesByField(ctx, "id", ids, false)
+		if err != nil {
+			return 0, err
+		}
+		for _, branchOffice := range restored {
+			if err := s.checkBranchOfficeServiceAreaFree(ctx, branchOffice); err != nil {
+				return 0, err
+			}
+		}
+		return res, nil
+	})
+}
+
 func (s *branchOfficeService) GetTotalRowsAndPages(ctx context.Context, req dto.GetBranchOfficeRequest) (int64, int64, error) {
 	filter := s.convertToBranchOfficeListFilter(req)
 	res, err := s.branchOfficeRepo.GetBranchOfficeCount(ctx, filter)
@@ -188,6 +999,64 @@ func (s *branchOfficeService) GetTotalRowsAndPages(ctx context.Context, req dto.
 	return totalRows, totalPages, nil
 }
 
+// GetBranchOfficeListByCursor returns the page at req.Cursor, or the first page, along
+// with the cursors of the next and previous pages when they exist.
+func (s *branchOfficeService) GetBranchOfficeListByCursor(ctx context.Context, req dto.GetBranchOfficeRequest) ([]*models.BranchOffice, *string, *string, error) {
+	filter := s.convertToBranchOfficeListFilter(req)
+	limit := *req.Limit
+	// One extra row tells whether another page follows in the direction of travel.
+	fetch := limit + 1
+	filter.Limit = &fetch
+	filter.Page = nil
+
+	if req.Cursor != nil && *req.Cursor != "" {
+		keyset, err := DecodeBranchOfficeCursor(*req.Cursor, *req.Sort)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		filter.Keyset = keyset
+	}
+	before := filter.Keyset != nil && filter.Keyset.Before
+
+	list, err := s.branchOfficeRepo.GetBranchOfficeList(ctx, filter)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	hasMore := len(list) > limit
+	if hasMore {
+		if before {
+			list = list[1:]
+		} else {
+			list = list[:limit]
+		}
+	}
+	if len(list) == 0 {
+		return list, nil, nil, nil
+	}
+
+	var next, prev *string
+	if hasMore || before {
+		if next, err = encodeBranchOfficeCursor(*req.Sort, list[len(list)-1], false); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	if (hasMore && before) || (!before && filter.Keyset != nil) {
+		if prev, err = encodeBranchOfficeCursor(*req.Sort, list[0], true); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	return list, next, prev, nil
+}
+
+// ExportBranchOffices calls fn with every branch office matching the filters of req, in
+// the order of req.Sort, without paging.
+func (s *branchOfficeService) ExportBranchOffices(ctx context.Context, req dto.GetBranchOfficeRequest, fn func(branchOffice *models.BranchOffice) error) error {
+	filter := s.convertToBranchOfficeListFilter(req)
+	filter.Limit = nil
+	filter.Page = nil
+	return s.branchOfficeRepo.StreamBranchOfficeList(ctx, filter, fn)
+}
+
 func (s *branchOfficeService) GetSimpleBranchOfficeList(ctx context.Context, req dto.GetSimpleBranchOfficeRequest) ([]*models.BranchOffice, error) {
 	if req.Keyword != nil {
 		keyword := util.ClearInvalidKeyword(*req.Keyword)
@@ -197,6 +1066,7 @@ func (s *branchOfficeService) GetSimpleBranchOfficeList(ctx context.Context, req
 	var filter = repos.GetBranchOfficeListFilter{
 		Fields:  &[]string{"name"},
 		Keyword: req.Keyword,
+		Select:  SimpleBranchOfficeSelect,
 	}
 
 	res, err := s.branchOfficeRepo.GetBranchOfficeList(ctx, filter)
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"slices"
+
+	"github.com/jangkartech/twin-branch-office/pkg/models"
+	"github.com/jangkartech/twin-branch-office/pkg/repos"
+)
+
+// MigrateBranchOfficeAddressesResult counts the branch offices an address migration
+// structured, or would have structured in a dry run, out of those without a street.
+type MigrateBranchOfficeAddressesResult struct {
+	Total      int
+	Structured int
+}
+
+// MigrateBranchOfficeAddresses parses the flat address of the live branch offices stored
+// before addresses were structured, those without a street, into the structured address
+// columns, as ParseBranchOfficeAddress reads it. The flat address itself is kept as it
+// was written. Every structured branch office gets a new version and an audit entry, all
+// in one unit of work; a dry run writes nothing. Branch offices whose address parses to
+// what they hold already are skipped, so the migration can run again, after a restore
+// for one.
+func (s *branchOfficeService) MigrateBranchOfficeAddresses(ctx context.Context, dryRun bool) (*MigrateBranchOfficeAddressesResult, error) {
+	res := &MigrateBranchOfficeAddressesResult{}
+	hasStreet := false
+	err := s.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		list, err := s.branchOfficeRepo.GetBranchOfficeList(ctx, repos.GetBranchOfficeListFilter{
+			HasStreet: &hasStreet,
+			Sort:      []repos.SortField{{Column: "id"}},
+			Select:    []string{"address", "address_detail", "city"},
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, branchOffice := range list {
+			if branchOffice.Address == "" {
+				continue
+			}
+			res.Total++
+			if parsedBranchOfficeAddress(branchOffice) == branchOffice.StructuredAddress() {
+				continue
+			}
+
+			res.Structured++
+			if dryRun {
+				continue
+			}
+			if err := s.structureBranchOfficeAddress(ctx, branchOffice.Id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// parsedBranchOfficeAddress parses the flat address of branchOffice, keeping the
+// province and country it has.
+func parsedBranchOfficeAddress(branchOffice *models.BranchOffice) models.BranchOfficeAddress {
+	return branchOffice.StructuredAddress().WithFlatAddress(branchOffice.Address, branchOffice.City)
+}
+
+// structureBranchOfficeAddress writes the structured address parsed from the flat address
+// of a branch office, leaving the flat address alone, and records the audit entry, in
+// the unit of work of the caller.
+func (s *branchOfficeService) structureBranchOfficeAddress(ctx context.Context, id string) error {
+	current, err := s.lockBranchOffice(ctx, id, false, nil)
+	if err != nil {
+		return err
+	}
+	branchOffice := models.BranchOffice{
+		Version:   current.Version + 1,
+		UpdatedBy: actorFromContext(ctx),
+	}
+	branchOffice.SetFlatAddress(current.Address, parsedBranchOfficeAddress(current))
+	columns := slices.DeleteFunc(slices.Clone(models.BranchOfficeAddressColumns), func(column string) bool { return column == "address" })
+	res, err := s.branchOfficeRepo.UpdateBranchOfficeById(ctx, id, branchOffice, append(columns, "version", "updated_by"))
+	if err != nil {
+		return err
+	}
+	return s.branchOfficeAuditService.RecordBranchOfficeAudit(ctx, RecordBranchOfficeAuditInput{
+		Action:         models.BranchOfficeAuditActionUpdate,
+		BranchOfficeId: id,
+		Before:         current,
+		After:          res,
+	})
+}
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/jangkartech/twin-branch-office/pkg/dto"
+	"github.com/jangkartech/twin-branch-office/pkg/geo"
+	"github.com/jangkartech/twin-branch-office/pkg/models"
+	"github.com/jangkartech/twin-branch-office/pkg/repos"
+	"github.com/jangkartech/twin-util/pkg/util"
+)
+
+type BranchOfficeAuditServiceInterface interface {
+	RecordBranchOfficeAudit(ctx context.Context, input RecordBranchOfficeAuditInput) error
+	GetBranchOfficeAuditList(ctx context.Context, id string, req dto.GetBranchOfficeHistoryRequest) ([]*models.BranchOfficeAudit, error)
+	GetTotalRowsAndPages(ctx context.Context, id string, req dto.GetBranchOfficeHistoryRequest) (int64, int64, error)
+}
+
+// RecordBranchOfficeAuditInput describes one change to a branch office. Before is nil
+// on create and After is nil on hard delete; both are nil when no field changed.
+type RecordBranchOfficeAuditInput struct {
+	Action         string
+	BranchOfficeId string
+	Before         *models.BranchOffice
+	After          *models.BranchOffice
+}
+
+type branchOfficeAuditService struct {
+	branchOfficeAuditRepo repos.BranchOfficeAuditRepoInterface
+}
+
+func NewBranchOfficeAuditService(branchOfficeAuditRepo repos.BranchOfficeAuditRepoInterface) BranchOfficeAuditServiceInterface {
+	return &branchOfficeAuditService{
+		branchOfficeAuditRepo: branchOfficeAuditRepo,
+	}
+}
+
+// branchOfficeAuditValues lists the audited fields of a branch office in a stable order.
+func branchOfficeAuditValues(m *models.BranchOffice) [][2]string {
+	return [][2]string{
+		{"name", m.Name},
+		{"address", m.Address},
+		{"street", m.Street},
+		{"rt", m.Rt},
+		{"rw", m.Rw},
+		{"kelurahan", m.Kelurahan},
+		{"kecamatan", m.Kecamatan},
+		{"province", m.Province},
+		{"postal_code", m.PostalCode},
+		{"country", m.Country},
+		{"phone_number", m.PhoneNumber},
+		{"fax_number", m.FaxNumber},
+		{"city", m.City},
+		{"city_code", m.CityCode},
+		{"latitude", formatAuditFloat(m.Latitude)},
+		{"longitude", formatAuditFloat(m.Longitude)},
+		{"service_area", formatAuditArea(m.ServiceArea)},
+		{"postal_codes", formatAuditJSON(m.PostalCodes)},
+		{"geocode_status", m.GeocodeStatus},
+		{"geocoded_address", m.GeocodedAddress},
+		{"lifecycle_status", m.LifecycleStatus},
+		{"lifecycle_status_reason", m.LifecycleStatusReason},
+		{"lifecycle_status_effective_at", formatAuditTime(m.LifecycleStatusEffectiveAt)},
+		{"timezone", m.Timezone},
+		{"opening_hours", formatAuditJSON(m.OpeningHours)},
+		{"exceptions", formatAuditJSON(m.Exceptions)},
+	}
+}
+
+// formatAuditJSON renders a list field as JSON, or empty when it has no items.
+func formatAuditJSON[T any](items []T) string {
+	if len(items) == 0 {
+		return ""
+	}
+	res, _ := json.Marshal(items)
+	return string(res)
+}
+
+// formatAuditArea renders a service area as GeoJSON, or empty when there is none.
+func formatAuditArea(area geo.MultiPolygon) string {
+	if len(area) == 0 {
+		return ""
+	}
+	res, _ := json.Marshal(area)
+	return string(res)
+}
+
+// formatAuditFloat renders an optional number in its shortest exact form, or empty.
+func formatAuditFloat(f *float64) string {
+	if f == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*f, 'f', -1, 64)
+}
+
+// formatAuditTime renders an optional timestamp as RFC 3339 in UTC, or empty.
+func formatAuditTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func diffBranchOffice(before *models.BranchOffice, after *models.BranchOffice) []models.BranchOfficeAuditChange {
+	var beforeValues, afterValues [][2]string
+	if before != nil {
+		beforeValues = branchOfficeAuditValues(before)
+	}
+	if after != nil {
+		afterValues = branchOfficeAuditValues(after)
+	}
+
+	var changes []models.BranchOfficeAuditChange
+	for i := 0; i < len(beforeValues) || i < len(afterValues); i++ {
+		var change models.BranchOfficeAuditChange
+		if i < len(beforeValues) {
+			change.Field = beforeValues[i][0]
+			change.Before = &beforeValues[i][1]
+		}
+		if i < len(afterValues) {
+			change.Field = afterValues[i][0]
+			change.After = &afterValues[i][1]
+		}
+		if change.Before != nil && change.After != nil && *change.Before == *change.After {
+			continue
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+func (s *branchOfficeAuditService) convertToBranchOfficeAuditListFilter(id string, req dto.GetBranchOfficeHistoryRequest) repos.GetBranchOfficeAuditListFilter {
+	return repos.GetBranchOfficeAuditListFilter{
+		BranchOfficeId: id,
+		Limit:          req.Limit,
+		Page:           req.Page,
+	}
+}
+
+func (s *branchOfficeAuditService) RecordBranchOfficeAudit(ctx context.Context, input RecordBranchOfficeAuditInput) error {
+	_, err := s.branchOfficeAuditRepo.CreateBranchOfficeAudit(ctx, models.BranchOfficeAudit{
+		BranchOfficeId: input.BranchOfficeId,
+		Action:         input.Action,
+		Actor:          actorFromContext(ctx),
+		RequestId:      requestIdFromContext(ctx),
+		Changes:        diffBranchOffice(input.Before, input.After),
+	})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *branchOfficeAuditService) GetBranchOfficeAuditList(ctx context.Context, id string, req dto.GetBranchOfficeHistoryRequest) ([]*models.BranchOfficeAudit, error) {
+	filter := s.convertToBranchOfficeAuditListFilter(id, req)
+
+	res, err := s.branchOfficeAuditRepo.GetBranchOfficeAuditList(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *branchOfficeAuditService) GetTotalRowsAndPages(ctx context.Context, id string, req dto.GetBranchOfficeHistoryRequest) (int64, int64, error) {
+	filter := s.convertToBranchOfficeAuditListFilter(id, req)
+	res, err := s.branchOfficeAuditRepo.GetBranchOfficeAuditCount(ctx, filter)
+	if err != nil {
+		return 0, 0, err
+	}
+	totalRows := res
+	totalPages := util.CalculateTotalPage(totalRows, *req.Limit)
+	return totalRows, totalPages, nil
+}
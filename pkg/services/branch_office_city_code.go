@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"sort"
+
+	"github.com/jangkartech/twin-branch-office/pkg/models"
+	"github.com/jangkartech/twin-branch-office/pkg/regions"
+	"github.com/jangkartech/twin-branch-office/pkg/repos"
+	utilErrors "github.com/jangkartech/twin-util/pkg/errors"
+)
+
+// Reasons a city is left unmapped by MigrateBranchOfficeCityCodes.
+const (
+	UnmappedCityUnknown   = "unknown"
+	UnmappedCityAmbiguous = "ambiguous"
+)
+
+// MigrateBranchOfficeCityCodesResult counts the branch offices a city code migration
+// mapped, or would have mapped in a dry run, and reports the cities it could not map.
+type MigrateBranchOfficeCityCodesResult struct {
+	Total    int
+	Mapped   int
+	Unmapped []UnmappedBranchOfficeCity
+}
+
+// UnmappedBranchOfficeCity is a city written on BranchOffices branch offices that
+// matches no regency, or Candidates, more than one.
+type UnmappedBranchOfficeCity struct {
+	City          string
+	BranchOffices int
+	Reason        string
+	Candidates    []regions.Region
+}
+
+// MigrateBranchOfficeCityCodes gives the live branch offices without a city code the code
+// of the one regency their city matches, as regions.MatchRegencies matches them. The
+// rest are reported by city, ordered by name, to be fixed by hand or given an alias in
+// the dataset. Every mapped branch office gets a new version and an audit entry, all in
+// one unit of work; a dry run writes nothing. Branch offices already coded are skipped,
+// so the migration can run again, after a restore for one.
+func (s *branchOfficeService) MigrateBranchOfficeCityCodes(ctx context.Context, dryRun bool) (*MigrateBranchOfficeCityCodesResult, error) {
+	res := &MigrateBranchOfficeCityCodesResult{}
+	err := s.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		list, err := s.branchOfficeRepo.GetBranchOfficeList(ctx, repos.GetBranchOfficeListFilter{
+			CityCodes: []string{""},
+			Sort:      []repos.SortField{{Column: "id"}},
+			Select:    []string{"city"},
+		})
+		if err != nil {
+			return err
+		}
+		res.Total = len(list)
+
+		unmapped := map[string]*UnmappedBranchOfficeCity{}
+		for _, branchOffice := range list {
+			matches := regions.MatchRegencies(branchOffice.City)
+			if len(matches) != 1 {
+				city, ok := unmapped[branchOffice.City]
+				if !ok {
+					city = &UnmappedBranchOfficeCity{City: branchOffice.City, Reason: UnmappedCityUnknown, Candidates: matches}
+					if len(matches) > 1 {
+						city.Reason = UnmappedCityAmbiguous
+					}
+					unmapped[branchOffice.City] = city
+				}
+				city.BranchOffices++
+				continue
+			}
+
+			res.Mapped++
+			if dryRun {
+				continue
+			}
+			if err := s.setBranchOfficeCityCode(ctx, branchOffice.Id, matches[0].Code); err != nil {
+				return err
+			}
+		}
+
+		for _, city := range unmapped {
+			res.Unmapped = append(res.Unmapped, *city)
+		}
+		sort.Slice(res.Unmapped, func(i, j int) bool {
+			return res.Unmapped[i].City < res.Unmapped[j].City
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// setBranchOfficeCityCode writes the city code of a branch office and records the audit
+// entry, in the unit of work of the caller.
+func (s *branchOfficeService) setBranchOfficeCityCode(ctx context.Context, id string, code string) error {
+	current, err := s.lockBranchOffice(ctx, id, false, nil)
+	if err != nil {
+		return err
+	}
+	branchOffice := models.BranchOffice{
+		CityCode:  code,
+		Version:   current.Version + 1,
+		UpdatedBy: actorFromContext(ctx),
+	}
+	res, err := s.branchOfficeRepo.UpdateBranchOfficeById(ctx, id, branchOffice, []string{"city_code", "version", "updated_by"})
+	if err != nil {
+		return err
+	}
+	return s.branchOfficeAuditService.RecordBranchOfficeAudit(ctx, RecordBranchOfficeAuditInput{
+		Action:         models.BranchOfficeAuditActionUpdate,
+		BranchOfficeId: id,
+		Before:         current,
+		After:          res,
+	})
+}
+
+// checkBranchOfficeCityCode rejects a city code, when there is one, that is not the code
+// of a regency the city may stand for, so the two cannot drift apart. The code itself is
+// checked against the regions package by the validators.
+func checkBranchOfficeCityCode(city string, code string) error {
+	if code != "" && !regions.CityMatchesRegency(city, code) {
+		return &utilErrors.DBValidationError{Field: "city_code", Tag: "city"}
+	}
+	return nil
+}
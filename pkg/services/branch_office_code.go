@@ -0,0 +1,202 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/jangkartech/twin-branch-office/pkg/models"
+	"github.com/jangkartech/twin-branch-office/pkg/repos"
+)
+
+// DefaultBranchCodePattern gives codes like JKT-001.
+const DefaultBranchCodePattern = "{CITY}-{SEQ:3}"
+
+// maxBranchCodeLength is the width of the code column.
+const maxBranchCodeLength = 30
+
+var ErrInvalidBranchCodePattern = errors.New("invalid branch code pattern")
+
+var branchCodeTokenPattern = regexp.MustCompile(`\{(CITY|SEQ)(?::([1-9]))?\}`)
+
+// BranchCodePattern renders branch codes from a pattern in which {CITY} stands for the
+// three letter abbreviation of the city and {SEQ:n} for the sequence number of the code,
+// zero padded to n digits. Codes are numbered per scope, which is the pattern rendered
+// for the city, so two cities with the same abbreviation share a sequence.
+type BranchCodePattern struct {
+	pattern string
+	digits  int
+}
+
+// ParseBranchCodePattern checks that pattern holds exactly one {SEQ} and nothing but
+// {CITY} besides, and leaves room in the code column for the number.
+func ParseBranchCodePattern(pattern string) (BranchCodePattern, error) {
+	res := BranchCodePattern{pattern: pattern}
+	sequences := 0
+	for _, match := range branchCodeTokenPattern.FindAllStringSubmatch(pattern, -1) {
+		if match[1] == "SEQ" {
+			sequences++
+			res.digits = 1
+			if match[2] != "" {
+				res.digits, _ = strconv.Atoi(match[2])
+			}
+		} else if match[2] != "" {
+			return BranchCodePattern{}, fmt.Errorf("%w: %s", ErrInvalidBranchCodePattern, match[0])
+		}
+	}
+	if sequences != 1 {
+		return BranchCodePattern{}, fmt.Errorf("%w: %q needs one {SEQ}", ErrInvalidBranchCodePattern, pattern)
+	}
+	if rest := branchCodeTokenPattern.ReplaceAllString(pattern, ""); strings.ContainsAny(rest, "{}") {
+		return BranchCodePattern{}, fmt.Errorf("%w: %q has an unknown token", ErrInvalidBranchCodePattern, pattern)
+	}
+	if len(res.scope("XXX"))-1+res.digits > maxBranchCodeLength {
+		return BranchCodePattern{}, fmt.Errorf("%w: %q is too long", ErrInvalidBranchCodePattern, pattern)
+	}
+	return res, nil
+}
+
+// MustParseBranchCodePattern is ParseBranchCodePattern for patterns known to be valid.
+func MustParseBranchCodePattern(pattern string) BranchCodePattern {
+	res, err := ParseBranchCodePattern(pattern)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+// scope renders the pattern for city, with # in place of the sequence number.
+func (p BranchCodePattern) scope(city string) string {
+	abbreviation := cityAbbreviation(city)
+	return branchCodeTokenPattern.ReplaceAllStringFunc(p.pattern, func(token string) string {
+		if strings.HasPrefix(token, "{CITY") {
+			return abbreviation
+		}
+		return "#"
+	})
+}
+
+// code renders the code numbered sequence in scope.
+func (p BranchCodePattern) code(scope string, sequence int64) string {
+	return strings.Replace(scope, "#", fmt.Sprintf("%0*d", p.digits, sequence), 1)
+}
+
+// cityAbbreviation shortens city to its first letter, its next consonant and its last
+// consonant, so Jakarta becomes JKT and Surabaya SRB, topped up with its other letters
+// when it has too few consonants.
+func cityAbbreviation(city string) string {
+	var letters []rune
+	for _, r := range strings.ToUpper(city) {
+		if unicode.IsLetter(r) {
+			letters = append(letters, r)
+		}
+	}
+	if len(letters) == 0 {
+		return "XXX"
+	}
+
+	isConsonant := func(r rune) bool { return !strings.ContainsRune("AEIOUY", r) }
+	res := []rune{letters[0]}
+	used := map[int]bool{0: true}
+	for i := 1; i < len(letters); i++ {
+		if isConsonant(letters[i]) {
+			res = append(res, letters[i])
+			used[i] = true
+			break
+		}
+	}
+	for i := len(letters) - 1; i > 0 && len(res) == 2; i-- {
+		if !used[i] && isConsonant(letters[i]) {
+			res = append(res, letters[i])
+			used[i] = true
+		}
+	}
+	for i := 1; i < len(letters) && len(res) < 3; i++ {
+		if !used[i] {
+			res = append(res, letters[i])
+			used[i] = true
+		}
+	}
+	for len(res) < 3 {
+		res = append(res, 'X')
+	}
+	return string(res)
+}
+
+// MigrateBranchOfficeCodesResult counts the branch offices a code migration coded, or
+// would have coded in a dry run.
+type MigrateBranchOfficeCodesResult struct {
+	Total    int
+	Assigned int
+}
+
+// MigrateBranchOfficeCodes gives the live branch offices stored before codes existed the
+// next code of their city, oldest first, so they are numbered in the order they were
+// created. Every coded branch office gets a new version and an audit entry, all in one
+// unit of work; a dry run writes nothing and takes no numbers. The sequences are not
+// given back if the unit of work fails, which only leaves a gap in the codes. Branch
+// offices already coded are skipped, so the migration can run again.
+func (s *branchOfficeService) MigrateBranchOfficeCodes(ctx context.Context, dryRun bool) (*MigrateBranchOfficeCodesResult, error) {
+	res := &MigrateBranchOfficeCodesResult{}
+	hasCode := false
+	err := s.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		list, err := s.branchOfficeRepo.GetBranchOfficeList(ctx, repos.GetBranchOfficeListFilter{
+			HasCode: &hasCode,
+			Sort:    []repos.SortField{{Column: "created_at"}, {Column: "id"}},
+			Select:  []string{"city", "created_at"},
+		})
+		if err != nil {
+			return err
+		}
+		res.Total = len(list)
+		if dryRun {
+			res.Assigned = len(list)
+			return nil
+		}
+
+		for _, branchOffice := range list {
+			if err := s.setBranchOfficeCode(ctx, branchOffice.Id); err != nil {
+				return err
+			}
+			res.Assigned++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// setBranchOfficeCode gives a branch office the next code of its city and records the
+// audit entry, in the unit of work of the caller.
+func (s *branchOfficeService) setBranchOfficeCode(ctx context.Context, id string) error {
+	current, err := s.lockBranchOffice(ctx, id, false, nil)
+	if err != nil {
+		return err
+	}
+	scope := s.codePattern.scope(current.City)
+	sequence, err := s.branchOfficeRepo.NextBranchOfficeCodeSequence(ctx, scope)
+	if err != nil {
+		return err
+	}
+	branchOffice := models.BranchOffice{
+		Code:      s.codePattern.code(scope, sequence),
+		Version:   current.Version + 1,
+		UpdatedBy: actorFromContext(ctx),
+	}
+	res, err := s.branchOfficeRepo.UpdateBranchOfficeById(ctx, id, branchOffice, []string{"code", "version", "updated_by"})
+	if err != nil {
+		return err
+	}
+	return s.branchOfficeAuditService.RecordBranchOfficeAudit(ctx, RecordBranchOfficeAuditInput{
+		Action:         models.BranchOfficeAuditActionUpdate,
+		BranchOfficeId: id,
+		Before:         current,
+		After:          res,
+	})
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseBranchCodePattern(t *testing.T) {
+	for _, tc := range []struct {
+		pattern string
+		valid   bool
+	}{
+		{DefaultBranchCodePattern, true},
+		{"BO-{SEQ:5}", true},
+		{"{SEQ}{CITY}", true},
+		{"{CITY}-{CITY}-{SEQ:2}", true},
+		{"{CITY}", false},
+		{"{CITY}-{SEQ}-{SEQ}", false},
+		{"{CITY}-{NAME}-{SEQ}", false},
+		{"{CITY}-{SEQ:0}", false},
+		{"{CITY:3}-{SEQ}", false},
+		{"{CITY}-{SEQ", false},
+		{"BRANCH-OFFICE-OF-{CITY}-{SEQ:9}", true},
+		{"BRANCH-OFFICES-OF-{CITY}-{SEQ:9}", false},
+	} {
+		t.Run(tc.pattern, func(t *testing.T) {
+			_, err := ParseBranchCodePattern(tc.pattern)
+			if tc.valid && err != nil {
+				t.Errorf("ParseBranchCodePattern(%q) error = %v, want none", tc.pattern, err)
+			}
+			if !tc.valid && !errors.Is(err, ErrInvalidBranchCodePattern) {
+				t.Errorf("ParseBranchCodePattern(%q) error = %v, want ErrInvalidBranchCodePattern", tc.pattern, err)
+			}
+		})
+	}
+}
+
+func TestBranchCodePatternCode(t *testing.T) {
+	for _, tc := range []struct {
+		pattern  string
+		city     string
+		sequence int64
+		scope    string
+		code     string
+	}{
+		{DefaultBranchCodePattern, "Jakarta", 1, "JKT-#", "JKT-001"},
+		{DefaultBranchCodePattern, "Bandung", 1234, "BNG-#", "BNG-1234"},
+		{"BO-{SEQ:5}", "Jakarta", 42, "BO-#", "BO-00042"},
+		{"{SEQ}{CITY}", "Surabaya", 7, "#SRB", "7SRB"},
+	} {
+		t.Run(tc.code, func(t *testing.T) {
+			pattern := MustParseBranchCodePattern(tc.pattern)
+			scope := pattern.scope(tc.city)
+			if scope != tc.scope {
+				t.Errorf("scope(%q) = %q, want %q", tc.city, scope, tc.scope)
+			}
+			if code := pattern.code(scope, tc.sequence); code != tc.code {
+				t.Errorf("code(%q, %d) = %q, want %q", scope, tc.sequence, code, tc.code)
+			}
+		})
+	}
+}
+
+func TestCityAbbreviation(t *testing.T) {
+	for _, tc := range []struct {
+		city string
+		want string
+	}{
+		{"Jakarta", "JKT"},
+		{"Surabaya", "SRB"},
+		{"Bandung", "BNG"},
+		{"jakarta pusat", "JKT"},
+		{"Kota Medan", "KTN"},
+		{"Aceh", "ACH"},
+		{"Bau-Bau", "BBA"},
+		{"Ia", "IAX"},
+		{"Yogyakarta", "YGT"},
+		{"Ōsaka", "ŌSK"},
+		{"", "XXX"},
+		{"123", "XXX"},
+	} {
+		t.Run(tc.city, func(t *testing.T) {
+			if got := cityAbbreviation(tc.city); got != tc.want {
+				t.Errorf("cityAbbreviation(%q) = %q, want %q", tc.city, got, tc.want)
+			}
+		})
+	}
+}
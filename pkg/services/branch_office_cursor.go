@@ -0,0 +1,72 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+
+	"github.com/jangkartech/twin-branch-office/pkg/models"
+	"github.com/jangkartech/twin-branch-office/pkg/repos"
+)
+
+const BranchOfficePaginationCursor = "cursor"
+
+var ErrInvalidBranchOfficeCursor = errors.New("invalid branch office cursor")
+
+// branchOfficeCursor is the payload behind the opaque next_cursor and prev_cursor. It
+// pins the sort it was issued for, so a cursor cannot be replayed with another order.
+type branchOfficeCursor struct {
+	Sort   string                 `json:"s"`
+	Before bool                   `json:"b,omitempty"`
+	Row    map[string]interface{} `json:"r"`
+}
+
+func encodeBranchOfficeCursor(sort string, row *models.BranchOffice, before bool) (*string, error) {
+	fields, _ := ParseBranchOfficeSort(sort)
+
+	raw, err := json.Marshal(row)
+	if err != nil {
+		return nil, err
+	}
+	var values map[string]interface{}
+	if err := json.Unmarshal(raw, &values); err != nil {
+		return nil, err
+	}
+	cursor := branchOfficeCursor{
+		Sort:   sort,
+		Before: before,
+		Row:    map[string]interface{}{"id": values["id"]},
+	}
+	for _, field := range fields {
+		cursor.Row[field.Column] = values[field.Column]
+	}
+
+	raw, err = json.Marshal(cursor)
+	if err != nil {
+		return nil, err
+	}
+	res := base64.RawURLEncoding.EncodeToString(raw)
+	return &res, nil
+}
+
+// DecodeBranchOfficeCursor turns a cursor issued for sort back into a keyset.
+func DecodeBranchOfficeCursor(cursor string, sort string) (*repos.Keyset, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidBranchOfficeCursor
+	}
+	var payload branchOfficeCursor
+	if err := json.Unmarshal(raw, &payload); err != nil || payload.Sort != sort {
+		return nil, ErrInvalidBranchOfficeCursor
+	}
+
+	raw, err = json.Marshal(payload.Row)
+	if err != nil {
+		return nil, ErrInvalidBranchOfficeCursor
+	}
+	var row models.BranchOffice
+	if err := json.Unmarshal(raw, &row); err != nil || row.Id == "" {
+		return nil, ErrInvalidBranchOfficeCursor
+	}
+	return &repos.Keyset{Row: &row, Before: payload.Before}, nil
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jangkartech/twin-branch-office/pkg/geocode"
+	"github.com/jangkartech/twin-branch-office/pkg/models"
+	"github.com/jangkartech/twin-branch-office/pkg/repos"
+	"github.com/jangkartech/twin-util/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// Pacing of the background geocoding. A failed attempt is retried after
+// GeocodeRetryDelay, doubled for every further failure, and the branch office is marked
+// failed after GeocodeMaxAttempts. A worker claims the branch offices it geocodes for
+// GeocodeClaimDuration, after which another may take them over, as when the first one
+// stopped midway.
+const (
+	GeocodePollInterval  = time.Minute
+	GeocodeRetryDelay    = time.Minute
+	GeocodeMaxAttempts   = 5
+	GeocodeClaimDuration = 10 * time.Minute
+	geocodeBatchSize     = 50
+)
+
+// GeocoderActor is the actor recorded for the changes the geocoder makes.
+const GeocoderActor = "geocoder"
+
+// BranchOfficeGeocodeServiceInterface geocodes the addresses of pending branch offices
+// in the background, outside the requests that queued them.
+type BranchOfficeGeocodeServiceInterface interface {
+	// Run geocodes due branch offices every GeocodePollInterval, and as soon as Notify is
+	// called, until ctx is done. A round that panics is logged and retried on the next.
+	Run(ctx context.Context)
+	Notify()
+	GeocodeDueBranchOffices(ctx context.Context) (int, error)
+}
+
+type branchOfficeGeocodeService struct {
+	branchOfficeRepo         repos.BranchOfficeRepoInterface
+	branchOfficeAuditService BranchOfficeAuditServiceInterface
+	unitOfWork               UnitOfWorkInterface
+	geocoder                 geocode.Geocoder
+	wake                     chan struct{}
+}
+
+func NewBranchOfficeGeocodeService(branchOfficeRepo repos.BranchOfficeRepoInterface, branchOfficeAuditService BranchOfficeAuditServiceInterface, unitOfWork UnitOfWorkInterface, geocoder geocode.Geocoder) BranchOfficeGeocodeServiceInterface {
+	return &branchOfficeGeocodeService{
+		branchOfficeRepo:         branchOfficeRepo,
+		branchOfficeAuditService: branchOfficeAuditService,
+		unitOfWork:               unitOfWork,
+		geocoder:                 geocoder,
+		wake:                     make(chan struct{}, 1),
+	}
+}
+
+func (s *branchOfficeGeocodeService) Run(ctx context.Context) {
+	ticker := time.NewTicker(GeocodePollInterval)
+	defer ticker.Stop()
+	for {
+		// A failing round leaves the branch offices pending for the next one.
+		for {
+			n, err := s.geocodeRound(ctx)
+			if err != nil || n < geocodeBatchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// geocodeRound runs GeocodeDueBranchOffices once, turning a panic into an error so Run
+// outlives it. The branch offices claimed by the round are taken up again once their
+// claim runs out.
+func (s *branchOfficeGeocodeService) geocodeRound(ctx context.Context) (n int, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("geocode: %v", r)
+			logger.Log.Error(err.Error())
+		}
+	}()
+	return s.GeocodeDueBranchOffices(ctx)
+}
+
+// Notify wakes Run without blocking. Call it once the unit of work that queued branch
+// offices has committed, or Run may not see them yet.
+func (s *branchOfficeGeocodeService) Notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// GeocodeDueBranchOffices claims the next batch of pending branch offices whose attempt
+// is due, makes one attempt at geocoding each, and returns how many it tried. Workers on
+// several instances claim different branch offices, so they can run side by side.
+func (s *branchOfficeGeocodeService) GeocodeDueBranchOffices(ctx context.Context) (int, error) {
+	now := time.Now()
+	var list []*models.BranchOffice
+	err := s.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		var err error
+		list, err = s.branchOfficeRepo.ClaimBranchOfficesForGeocoding(ctx, now, now.Add(GeocodeClaimDuration), geocodeBatchSize)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	ctx = context.WithValue(ctx, ActorContextKey, GeocoderActor)
+	for i, branchOffice := range list {
+		if err := s.geocodeBranchOffice(ctx, branchOffice); err != nil {
+			return i, err
+		}
+	}
+	return len(list), nil
+}
+
+// geocodeBranchOffice looks up the address of branchOffice and records the outcome,
+// unless the branch office was deleted or its address edited during the lookup.
+func (s *branchOfficeGeocodeService) geocodeBranchOffice(ctx context.Context, branchOffice *models.BranchOffice) error {
+	// The lookup may be slow, so it runs before the row is locked.
+	result, geocodeErr := s.geocoder.Geocode(ctx, geocode.Query{
+		Address:    branchOffice.Address,
+		City:       branchOffice.City,
+		Province:   branchOffice.Province,
+		PostalCode: branchOffice.PostalCode,
+		Country:    branchOffice.Country,
+	})
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return s.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		current, err := s.branchOfficeRepo.LockBranchOfficeById(ctx, branchOffice.Id, false)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+		if current.GeocodeStatus != models.BranchOfficeGeocodePending || current.StructuredAddress() != branchOffice.StructuredAddress() || current.City != branchOffice.City {
+			return nil
+		}
+
+		update := models.BranchOffice{
+			GeocodeAttempts: current.GeocodeAttempts + 1,
+		}
+		columns := []string{"geocode_status", "geocode_attempts", "geocode_next_attempt_at"}
+		switch {
+		case geocodeErr == nil:
+			update.GeocodeStatus = models.BranchOfficeGeocodeSucceeded
+			update.Latitude = &result.Point.Lat
+			update.Longitude = &result.Point.Lng
+			update.GeocodedAddress = result.Address
+			columns = append(columns, "latitude", "longitude", "geocoded_address")
+		case errors.Is(geocodeErr, geocode.ErrNoMatch):
+			update.GeocodeStatus = models.BranchOfficeGeocodeNotFound
+		case update.GeocodeAttempts >= GeocodeMaxAttempts:
+			update.GeocodeStatus = models.BranchOfficeGeocodeFailed
+		default:
+			// A retry is bookkeeping only: the branch office stays pending at its version.
+			next := time.Now().Add(GeocodeRetryDelay << (update.GeocodeAttempts - 1))
+			update.GeocodeStatus = models.BranchOfficeGeocodePending
+			update.GeocodeNextAttemptAt = &next
+			_, err := s.branchOfficeRepo.UpdateBranchOfficeById(ctx, current.Id, update, columns)
+			return err
+		}
+
+		update.Version = current.Version + 1
+		update.UpdatedBy = actorFromContext(ctx)
+		columns = append(columns, "version", "updated_by")
+		res, err := s.branchOfficeRepo.UpdateBranchOfficeById(ctx, current.Id, update, columns)
+		if err != nil {
+			return err
+		}
+		return s.branchOfficeAuditService.RecordBranchOfficeAudit(ctx, RecordBranchOfficeAuditInput{
+			Action:         models.BranchOfficeAuditActionUpdate,
+			BranchOfficeId: current.Id,
+			Before:         current,
+			After:          res,
+		})
+	})
+}
@@ -0,0 +1,34 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jangkartech/twin-branch-office/pkg/geocode"
+	"github.com/jangkartech/twin-branch-office/pkg/models"
+	"github.com/jangkartech/twin-branch-office/pkg/repos"
+)
+
+type panicGeocoder struct{}
+
+func (panicGeocoder) Geocode(ctx context.Context, query geocode.Query) (*geocode.Result, error) {
+	panic("geocoder broke")
+}
+
+func TestGeocodeRoundRecovers(t *testing.T) {
+	ctx := context.Background()
+	store := repos.NewMemoryStore()
+	branchOfficeRepo := repos.NewMemoryBranchOfficeRepo(store)
+	s := NewBranchOfficeGeocodeService(branchOfficeRepo, NewBranchOfficeAuditService(repos.NewMemoryBranchOfficeAuditRepo(store)), NewUnitOfWork(repos.NewMemoryTransactionRepo(store)), panicGeocoder{}).(*branchOfficeGeocodeService)
+	_, err := branchOfficeRepo.CreateBranchOffice(ctx, models.BranchOffice{Id: "bo-1", Name: "Kantor Pusat", City: "Jakarta Pusat", GeocodeStatus: models.BranchOfficeGeocodePending})
+	if err != nil {
+		t.Fatalf("CreateBranchOffice: %v", err)
+	}
+
+	if _, err := s.geocodeRound(ctx); err == nil {
+		t.Error("geocodeRound = nil, want the panic as an error")
+	}
+	if res, err := branchOfficeRepo.GetBranchOfficeById(ctx, "bo-1", false); err != nil || res.GeocodeStatus != models.BranchOfficeGeocodePending {
+		t.Errorf("GetBranchOfficeById = %+v, %v, want it still pending", res, err)
+	}
+}
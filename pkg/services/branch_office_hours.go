@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jangkartech/twin-branch-office/pkg/dto"
+	"github.com/jangkartech/twin-branch-office/pkg/models"
+	"gorm.io/gorm"
+)
+
+// availabilityLookahead is how many days ahead an availability looks for the next
+// opening.
+const availabilityLookahead = 14
+
+// BranchOfficeAvailability tells whether a branch office is open at At. Hours are the
+// intervals of the local date of At, set by Exception when it is not nil. ClosesAt is set
+// while open, and NextOpensAt while closed when it opens within two weeks.
+type BranchOfficeAvailability struct {
+	BranchOffice *models.BranchOffice
+	At           time.Time
+	Open         bool
+	Hours        []models.BranchOfficeHoursInterval
+	Exception    *models.BranchOfficeHoursException
+	ClosesAt     *time.Time
+	NextOpensAt  *time.Time
+}
+
+// UpdateBranchOfficeHoursById replaces the timezone, weekly opening hours and exceptions
+// of a live branch office.
+func (s *branchOfficeService) UpdateBranchOfficeHoursById(ctx context.Context, id string, req dto.UpdateBranchOfficeHoursRequest, version *int64) (*models.BranchOffice, error) {
+	var res *models.BranchOffice
+	err := s.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		current, err := s.lockBranchOffice(ctx, id, false, version)
+		if err != nil {
+			return err
+		}
+
+		branchOffice := models.BranchOffice{
+			Timezone:     req.Timezone,
+			OpeningHours: models.OpeningHoursFromDto(req.OpeningHours),
+			Exceptions:   models.HoursExceptionsFromDto(req.Exceptions),
+			Version:      current.Version + 1,
+			UpdatedBy:    actorFromContext(ctx),
+		}
+		columns := []string{"timezone", "opening_hours", "exceptions", "version", "updated_by"}
+		res, err = s.branchOfficeRepo.UpdateBranchOfficeById(ctx, id, branchOffice, columns)
+		if err != nil {
+			return err
+		}
+		return s.branchOfficeAuditService.RecordBranchOfficeAudit(ctx, RecordBranchOfficeAuditInput{
+			Action:         models.BranchOfficeAuditActionUpdate,
+			BranchOfficeId: id,
+			Before:         current,
+			After:          res,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// GetBranchOfficeAvailability works out whether a live branch office is open at the
+// instant at, in its own timezone. Only active branch offices are ever open.
+func (s *branchOfficeService) GetBranchOfficeAvailability(ctx context.Context, id string, at time.Time) (*BranchOfficeAvailability, error) {
+	branchOffice, err := s.branchOfficeRepo.GetBranchOfficeById(ctx, id, false)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBranchOfficeNotFound
+		}
+		return nil, err
+	}
+
+	local := at.In(branchOffice.Location())
+	res := &BranchOfficeAvailability{
+		BranchOffice: branchOffice,
+		At:           local,
+		Open:         branchOffice.OpenAt(at),
+	}
+	res.Hours, res.Exception = branchOffice.HoursOn(local)
+	if res.Open {
+		res.ClosesAt = branchOffice.ClosesAfter(at)
+	} else {
+		res.NextOpensAt = branchOffice.NextOpening(at, availabilityLookahead)
+	}
+	return res, nil
+}
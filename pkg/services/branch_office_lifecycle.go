@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/jangkartech/twin-branch-office/pkg/dto"
+	"github.com/jangkartech/twin-branch-office/pkg/models"
+	utilErrors "github.com/jangkartech/twin-util/pkg/errors"
+)
+
+// BranchOfficeTransitions maps each lifecycle status to the statuses a branch office may
+// move to from it. A planned branch office that never opens is closed; closed is final.
+var BranchOfficeTransitions = map[string][]string{
+	models.BranchOfficeStatusPlanned:   {models.BranchOfficeStatusActive, models.BranchOfficeStatusClosed},
+	models.BranchOfficeStatusActive:    {models.BranchOfficeStatusSuspended, models.BranchOfficeStatusClosed},
+	models.BranchOfficeStatusSuspended: {models.BranchOfficeStatusActive, models.BranchOfficeStatusClosed},
+	models.BranchOfficeStatusClosed:    {},
+}
+
+var ErrBranchOfficeInvalidTransition = errors.New("branch office cannot make this lifecycle transition")
+
+// CanTransitionBranchOffice tells whether BranchOfficeTransitions allows a branch office
+// to move from one lifecycle status to another.
+func CanTransitionBranchOffice(from string, to string) bool {
+	return slices.Contains(BranchOfficeTransitions[from], to)
+}
+
+// TransitionBranchOfficeById moves a live branch office to req.Status, recording the
+// reason and the time it took effect. It fails with ErrBranchOfficeInvalidTransition
+// when BranchOfficeTransitions does not allow the move, with a validation error on
+// effective_at when it predates the current status, and with a BranchOfficeConflictError
+// when it would activate a branch office serving what another active one serves.
+func (s *branchOfficeService) TransitionBranchOfficeById(ctx context.Context, id string, req dto.TransitionBranchOfficeRequest, version *int64) (*models.BranchOffice, error) {
+	effectiveAt := time.Now()
+	if req.EffectiveAt != nil {
+		effectiveAt = time.Unix(*req.EffectiveAt, 0)
+	}
+
+	var res *models.BranchOffice
+	err := s.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		current, err := s.lockBranchOffice(ctx, id, false, version)
+		if err != nil {
+			return err
+		}
+		if !CanTransitionBranchOffice(current.LifecycleStatus, req.Status) {
+			return fmt.Errorf("%w: %s to %s", ErrBranchOfficeInvalidTransition, current.LifecycleStatus, req.Status)
+		}
+		if current.LifecycleStatusEffectiveAt != nil && effectiveAt.Before(*current.LifecycleStatusEffectiveAt) {
+			return &utilErrors.DBValidationError{Field: "effective_at", Tag: "gtefield"}
+		}
+		if req.Status == models.BranchOfficeStatusActive {
+			activated := *current
+			activated.LifecycleStatus = req.Status
+			if err := s.checkBranchOfficeServiceAreaFree(ctx, &activated); err != nil {
+				return err
+			}
+		}
+
+		branchOffice := models.BranchOffice{
+			LifecycleStatus:            req.Status,
+			LifecycleStatusReason:      req.Reason,
+			LifecycleStatusEffectiveAt: &effectiveAt,
+			Version:                    current.Version + 1,
+			UpdatedBy:                  actorFromContext(ctx),
+		}
+		columns := []string{"lifecycle_status", "lifecycle_status_reason", "lifecycle_status_effective_at", "version", "updated_by"}
+		res, err = s.branchOfficeRepo.UpdateBranchOfficeById(ctx, id, branchOffice, columns)
+		if err != nil {
+			return err
+		}
+		return s.branchOfficeAuditService.RecordBranchOfficeAudit(ctx, RecordBranchOfficeAuditInput{
+			Action:         models.BranchOfficeAuditActionTransition,
+			BranchOfficeId: id,
+			Before:         current,
+			After:          res,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
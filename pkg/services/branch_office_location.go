@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+
+	"github.com/jangkartech/twin-branch-office/pkg/dto"
+	"github.com/jangkartech/twin-branch-office/pkg/geo"
+	"github.com/jangkartech/twin-branch-office/pkg/models"
+	"github.com/jangkartech/twin-branch-office/pkg/repos"
+)
+
+// UpdateBranchOfficeLocationById sets or clears the coordinates of a live branch office.
+// Coordinates set this way are never geocoded over; clearing them hands the branch
+// office back to the geocoder.
+func (s *branchOfficeService) UpdateBranchOfficeLocationById(ctx context.Context, id string, req dto.UpdateBranchOfficeLocationRequest, version *int64) (*models.BranchOffice, error) {
+	var res *models.BranchOffice
+	err := s.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		current, err := s.lockBranchOffice(ctx, id, false, version)
+		if err != nil {
+			return err
+		}
+
+		branchOffice := models.BranchOffice{
+			Latitude:  req.Latitude,
+			Longitude: req.Longitude,
+			Version:   current.Version + 1,
+			UpdatedBy: actorFromContext(ctx),
+		}
+		if req.Latitude != nil {
+			branchOffice.GeocodeStatus = models.BranchOfficeGeocodeManual
+		} else {
+			s.queueGeocode(&branchOffice)
+		}
+		columns := []string{"latitude", "longitude", "geocode_status", "geocoded_address", "geocode_attempts", "geocode_next_attempt_at", "version", "updated_by"}
+		res, err = s.branchOfficeRepo.UpdateBranchOfficeById(ctx, id, branchOffice, columns)
+		if err != nil {
+			return err
+		}
+		return s.branchOfficeAuditService.RecordBranchOfficeAudit(ctx, RecordBranchOfficeAuditInput{
+			Action:         models.BranchOfficeAuditActionUpdate,
+			BranchOfficeId: id,
+			Before:         current,
+			After:          res,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.notifyGeocoder()
+	return res, nil
+}
+
+// GetNearbyBranchOfficeList returns the live branch offices nearest to the requested
+// point, by great-circle distance, narrowed down by city and lifecycle status.
+func (s *branchOfficeService) GetNearbyBranchOfficeList(ctx context.Context, req dto.GetNearbyBranchOfficeRequest) ([]*repos.BranchOfficeDistance, error) {
+	filter := repos.GetBranchOfficeListFilter{
+		Cities:            splitListParam(req.City),
+		LifecycleStatuses: splitListParam(req.LifecycleStatus),
+		Limit:             req.Limit,
+	}
+	near := repos.Near{
+		Point:    geo.Point{Lat: *req.Lat, Lng: *req.Lng},
+		RadiusKm: req.RadiusKm,
+	}
+
+	res, err := s.branchOfficeRepo.GetNearbyBranchOfficeList(ctx, filter, near)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"slices"
+
+	"github.com/jangkartech/twin-branch-office/pkg/dto"
+	"github.com/jangkartech/twin-branch-office/pkg/geo"
+	"github.com/jangkartech/twin-branch-office/pkg/models"
+	"github.com/jangkartech/twin-branch-office/pkg/repos"
+)
+
+// UpdateBranchOfficeServiceAreaById replaces the service area and postal codes of a live
+// branch office. It fails with a BranchOfficeConflictError when the branch office is
+// active and another active one already serves part of them.
+func (s *branchOfficeService) UpdateBranchOfficeServiceAreaById(ctx context.Context, id string, req dto.UpdateBranchOfficeServiceAreaRequest, version *int64) (*models.BranchOffice, error) {
+	var res *models.BranchOffice
+	err := s.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		current, err := s.lockBranchOffice(ctx, id, false, version)
+		if err != nil {
+			return err
+		}
+
+		updated := *current
+		updated.ServiceArea = req.ServiceArea
+		updated.PostalCodes = models.PostalCodesFromDto(req.PostalCodes)
+		if err := s.checkBranchOfficeServiceAreaFree(ctx, &updated); err != nil {
+			return err
+		}
+
+		branchOffice := models.BranchOffice{
+			ServiceArea: updated.ServiceArea,
+			PostalCodes: updated.PostalCodes,
+			Version:     current.Version + 1,
+			UpdatedBy:   actorFromContext(ctx),
+		}
+		columns := []string{"service_area", "postal_codes", "version", "updated_by"}
+		res, err = s.branchOfficeRepo.UpdateBranchOfficeById(ctx, id, branchOffice, columns)
+		if err != nil {
+			return err
+		}
+		return s.branchOfficeAuditService.RecordBranchOfficeAudit(ctx, RecordBranchOfficeAuditInput{
+			Action:         models.BranchOfficeAuditActionUpdate,
+			BranchOfficeId: id,
+			Before:         current,
+			After:          res,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// ResolveBranchOffice returns the active branch office responsible for the requested
+// postal code, or for the requested point by its service area, or
+// ErrBranchOfficeNotFound when none is. Only the branch offices whose service area has
+// bounds around the point are loaded to be checked.
+func (s *branchOfficeService) ResolveBranchOffice(ctx context.Context, req dto.ResolveBranchOfficeRequest) (*models.BranchOffice, error) {
+	filter := repos.GetBranchOfficeListFilter{
+		LifecycleStatuses: []string{models.BranchOfficeStatusActive},
+		Sort:              []repos.SortField{{Column: "code"}},
+	}
+	if req.PostalCode != nil {
+		postalCode := models.PostalCodeKey(*req.PostalCode)
+		filter.PostalCode = &postalCode
+	} else {
+		filter.Serving = &repos.Serving{Bounds: &geo.Box{MinLat: *req.Lat, MaxLat: *req.Lat, MinLng: *req.Lng, MaxLng: *req.Lng}}
+	}
+
+	list, err := s.branchOfficeRepo.GetBranchOfficeList(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	for _, branchOffice := range list {
+		if req.PostalCode != nil || branchOffice.ServiceArea.Contains(geo.Point{Lat: *req.Lat, Lng: *req.Lng}) {
+			return branchOffice, nil
+		}
+	}
+	return nil, ErrBranchOfficeNotFound
+}
+
+// checkBranchOfficeServiceAreaFree returns a BranchOfficeConflictError when branchOffice
+// is active and another live active branch office serves part of its service area or
+// one of its postal codes, so that a point or postal code never resolves to two branch
+// offices. Service areas that only share a border do not conflict. Only the branch
+// offices sharing a postal code or the bounds of the area are loaded, and the check
+// holds the service area lock until the unit of work of the caller ends, so concurrent
+// writes cannot both pass it.
+func (s *branchOfficeService) checkBranchOfficeServiceAreaFree(ctx context.Context, branchOffice *models.BranchOffice) error {
+	if branchOffice.LifecycleStatus != models.BranchOfficeStatusActive || (len(branchOffice.ServiceArea) == 0 && len(branchOffice.PostalCodes) == 0) {
+		return nil
+	}
+	if err := s.branchOfficeRepo.LockBranchOfficeServiceAreas(ctx); err != nil {
+		return err
+	}
+	serving := &repos.Serving{PostalCodes: branchOffice.PostalCodes}
+	if bounds, ok := branchOffice.ServiceArea.Bounds(); ok {
+		serving.Bounds = &bounds
+	}
+	others, err := s.branchOfficeRepo.GetBranchOfficeList(ctx, repos.GetBranchOfficeListFilter{
+		LifecycleStatuses: []string{models.BranchOfficeStatusActive},
+		Serving:           serving,
+	})
+	if err != nil {
+		return err
+	}
+	for _, other := range others {
+		if other.Id == branchOffice.Id {
+			continue
+		}
+		if slices.ContainsFunc(branchOffice.PostalCodes, func(code string) bool { return slices.Contains(other.PostalCodes, code) }) {
+			return &BranchOfficeConflictError{Field: "postal_codes", BranchOffice: other}
+		}
+		if branchOffice.ServiceArea.Overlaps(other.ServiceArea) {
+			return &BranchOfficeConflictError{Field: "service_area", BranchOffice: other}
+		}
+	}
+	return nil
+}
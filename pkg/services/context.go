@@ -0,0 +1,30 @@
+package services
+
+import (
+	"context"
+	"fmt"
+)
+
+// Keys under which the host application stores the authenticated principal and the
+// request id on the gin context. Override them to match the auth middleware in use.
+var (
+	ActorContextKey     = "user_id"
+	RequestIdContextKey = "request_id"
+)
+
+func contextString(ctx context.Context, key string) string {
+	value := ctx.Value(key)
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// actorFromContext returns the authenticated principal performing the request.
+func actorFromContext(ctx context.Context) string {
+	return contextString(ctx, ActorContextKey)
+}
+
+func requestIdFromContext(ctx context.Context) string {
+	return contextString(ctx, RequestIdContextKey)
+}
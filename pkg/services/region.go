@@ -0,0 +1,63 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/jangkartech/twin-branch-office/pkg/dto"
+	"github.com/jangkartech/twin-branch-office/pkg/regions"
+)
+
+var ErrRegionNotFound = errors.New("region not found")
+
+// RegionServiceInterface browses the administrative regions of the regions package.
+// A keyword keeps the regions whose name holds it, ignoring case.
+type RegionServiceInterface interface {
+	GetProvinces(req dto.GetRegionRequest) []regions.Region
+	// GetRegencies fails with ErrRegionNotFound unless provinceCode is a known province.
+	GetRegencies(provinceCode string, req dto.GetRegionRequest) ([]regions.Region, error)
+	// GetDistricts fails with ErrRegionNotFound unless regencyCode is a known regency.
+	GetDistricts(regencyCode string, req dto.GetRegionRequest) ([]regions.Region, error)
+}
+
+type regionService struct{}
+
+func NewRegionService() RegionServiceInterface {
+	return &regionService{}
+}
+
+func (s *regionService) GetProvinces(req dto.GetRegionRequest) []regions.Region {
+	return filterRegions(regions.Provinces(), req.Keyword)
+}
+
+func (s *regionService) GetRegencies(provinceCode string, req dto.GetRegionRequest) ([]regions.Region, error) {
+	return childRegions(provinceCode, regions.LevelProvince, req.Keyword)
+}
+
+func (s *regionService) GetDistricts(regencyCode string, req dto.GetRegionRequest) ([]regions.Region, error) {
+	return childRegions(regencyCode, regions.LevelRegency, req.Keyword)
+}
+
+// childRegions returns the regions below the region with the given code, which must be
+// of the given level.
+func childRegions(code string, level string, keyword *string) ([]regions.Region, error) {
+	if region, ok := regions.Lookup(code); !ok || region.Level != level {
+		return nil, ErrRegionNotFound
+	}
+	list, _ := regions.Children(code)
+	return filterRegions(list, keyword), nil
+}
+
+func filterRegions(list []regions.Region, keyword *string) []regions.Region {
+	if keyword == nil || strings.TrimSpace(*keyword) == "" {
+		return list
+	}
+	needle := strings.ToLower(strings.TrimSpace(*keyword))
+	var res []regions.Region
+	for _, region := range list {
+		if strings.Contains(strings.ToLower(region.Name), needle) {
+			res = append(res, region)
+		}
+	}
+	return res
+}
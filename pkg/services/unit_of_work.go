@@ -0,0 +1,28 @@
+package services
+
+import (
+	"context"
+
+	"github.com/jangkartech/twin-branch-office/pkg/repos"
+)
+
+// UnitOfWorkInterface runs several service or repo calls as one atomic unit. Every call
+// made with the ctx handed to fn, including nested Do calls, joins the same database
+// transaction, which commits when fn returns nil and rolls back otherwise.
+type UnitOfWorkInterface interface {
+	Do(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type unitOfWork struct {
+	transactionRepo repos.TransactionRepoInterface
+}
+
+func NewUnitOfWork(transactionRepo repos.TransactionRepoInterface) UnitOfWorkInterface {
+	return &unitOfWork{
+		transactionRepo: transactionRepo,
+	}
+}
+
+func (u *unitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	return u.transactionRepo.Transaction(ctx, fn)
+}
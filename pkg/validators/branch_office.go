@@ -1,9 +1,20 @@
 package validators
 
 import (
+	"encoding/json"
+	stdErrors "errors"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"github.com/jangkartech/twin-branch-office/pkg/dto"
+	"github.com/jangkartech/twin-branch-office/pkg/exports"
+	"github.com/jangkartech/twin-branch-office/pkg/geo"
+	"github.com/jangkartech/twin-branch-office/pkg/models"
+	"github.com/jangkartech/twin-branch-office/pkg/regions"
 	"github.com/jangkartech/twin-branch-office/pkg/services"
 	"github.com/jangkartech/twin-util/pkg/constant"
 	"github.com/jangkartech/twin-util/pkg/errors"
@@ -31,10 +42,112 @@ func ValidateGetBranchOfficeRequest(ctx *gin.Context) (*dto.GetBranchOfficeReque
 		req.Limit = &defaultLimit
 	}
 
-	util.EnsureStatusAllowed(req.Status, []string{constant.StatusDeleted})
+	if err := normalizeGetBranchOfficeRequest(&req); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// ValidateExportBranchOfficeRequest binds the format of an export along with the list
+// filters, which are checked as for GET /branch-offices. The format defaults to CSV.
+func ValidateExportBranchOfficeRequest(ctx *gin.Context) (*dto.ExportBranchOfficeRequest, error) {
+	validate := validator.New()
+	var req dto.ExportBranchOfficeRequest
+
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		return nil, err
+	}
+	if err := validate.Struct(req); err != nil {
+		return nil, err
+	}
+
+	if req.Format == nil {
+		format := exports.FormatCSV
+		req.Format = &format
+	}
+	if err := normalizeGetBranchOfficeRequest(&req.GetBranchOfficeRequest); err != nil {
+		return nil, err
+	}
 	return &req, nil
 }
 
+// normalizeGetBranchOfficeRequest checks the list parameters that need more than tags
+// and fills in their defaults.
+func normalizeGetBranchOfficeRequest(req *dto.GetBranchOfficeRequest) error {
+	sort := services.DefaultBranchOfficeSort
+	if req.Sort != nil && *req.Sort != "" {
+		fields, ok := services.ParseBranchOfficeSort(*req.Sort)
+		if !ok {
+			return &errors.DBValidationError{Field: "sort", Tag: "oneof"}
+		}
+		sort = services.FormatBranchOfficeSort(fields)
+	}
+	req.Sort = &sort
+
+	if req.Select != nil {
+		fields, ok := services.ParseBranchOfficeSelect(*req.Select)
+		if !ok {
+			return &errors.DBValidationError{Field: "select", Tag: "oneof"}
+		}
+		req.Select = &fields
+	}
+
+	if req.Cursor != nil && *req.Cursor != "" {
+		if _, err := services.DecodeBranchOfficeCursor(*req.Cursor, sort); err != nil {
+			return &errors.DBValidationError{Field: "cursor", Tag: "invalid"}
+		}
+		pagination := services.BranchOfficePaginationCursor
+		req.Pagination = &pagination
+	}
+
+	if req.CreatedFrom != nil && req.CreatedTo != nil && *req.CreatedTo < *req.CreatedFrom {
+		return &errors.DBValidationError{Field: "created_to", Tag: "gtefield"}
+	}
+	if !validListValues(req.LifecycleStatus, models.BranchOfficeStatuses) {
+		return &errors.DBValidationError{Field: "lifecycle_status", Tag: "oneof"}
+	}
+	if !validListValues(req.GeocodeStatus, models.BranchOfficeGeocodeStatuses) {
+		return &errors.DBValidationError{Field: "geocode_status", Tag: "oneof"}
+	}
+	if req.OpenAt != nil && req.OpenNow != nil && *req.OpenNow {
+		return &errors.DBValidationError{Field: "open_now", Tag: "excluded_with"}
+	}
+
+	util.EnsureStatusAllowed(req.Status, []string{constant.StatusDeleted})
+	return nil
+}
+
+// validListValues tells whether every value of a repeated or comma separated filter is
+// one of allowed.
+func validListValues(values *[]string, allowed []string) bool {
+	if values == nil {
+		return true
+	}
+	for _, value := range *values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" && !slices.Contains(allowed, item) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// blankListItem tells whether a repeated or comma separated filter holds a blank value.
+func blankListItem(values *[]string) bool {
+	if values == nil {
+		return false
+	}
+	for _, value := range *values {
+		for _, item := range strings.Split(value, ",") {
+			if strings.TrimSpace(item) == "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func ValidateCreateBranchOfficeRequest(ctx *gin.Context, branchOfficeService services.BranchOfficeServiceInterface) (*dto.CreateBranchOfficeRequest, error) {
 	validate := validator.New()
 	var req dto.CreateBranchOfficeRequest
@@ -46,21 +159,464 @@ func ValidateCreateBranchOfficeRequest(ctx *gin.Context, branchOfficeService ser
 	if err := validate.Struct(req); err != nil {
 		return nil, err
 	}
+	if err := validateCreateBranchOffice(req); err != nil {
+		return nil, err
+	}
 
 	if name := req.Name; name != "" {
-		warehouseExists, err := branchOfficeService.ExistsBranchOfficeByField(ctx, services.ExistsBranchOfficeByFieldInput{
-			Field: "name",
-			Value: name,
-		})
-		if err != nil {
-			logger.Log.Error(err.Error())
+		if err := validateBranchOfficeNameAvailable(ctx, branchOfficeService, name, nil); err != nil {
 			return nil, err
 		}
-		if warehouseExists {
-			return nil, &errors.DBValidationError{Field: "name", Tag: "exists"}
+	}
+
+	return &req, nil
+}
+
+// createBranchOfficeFields maps the fields reported by validateCreateBranchOffice to
+// those of dto.CreateBranchOfficeRequest.
+var createBranchOfficeFields = map[string]string{
+	"address_detail": "AddressDetail",
+	"city_code":      "CityCode",
+	"opening_hours":  "OpeningHours",
+	"exceptions":     "Exceptions",
+	"latitude":       "Latitude",
+	"longitude":      "Longitude",
+	"service_area":   "ServiceArea",
+	"postal_codes":   "PostalCodes",
+}
+
+// validateCreateBranchOffice runs the checks of a create that struct tags cannot express.
+func validateCreateBranchOffice(req dto.CreateBranchOfficeRequest) error {
+	if err := validateBranchOfficeAddress(req.AddressDetail); err != nil {
+		return err
+	}
+	if err := validateBranchOfficeCityCode(req.CityCode); err != nil {
+		return err
+	}
+	if err := validateBranchOfficeHours(req.OpeningHours, req.Exceptions); err != nil {
+		return err
+	}
+	if err := validateBranchOfficeCoordinates(req.Latitude, req.Longitude); err != nil {
+		return err
+	}
+	return validateBranchOfficeServiceArea(req.ServiceArea, req.PostalCodes)
+}
+
+// validateBranchOfficeNameAvailable rejects name when a live branch office other than
+// exceptId already holds it, ignoring case and whitespace as models.BranchOfficeNameKey
+// does.
+func validateBranchOfficeNameAvailable(ctx *gin.Context, branchOfficeService services.BranchOfficeServiceInterface, name string, exceptId *string) error {
+	nameExists, err := branchOfficeService.ExistsBranchOfficeByField(ctx, services.ExistsBranchOfficeByFieldInput{
+		Field:    "name",
+		Value:    name,
+		ExceptId: exceptId,
+	})
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return err
+	}
+	if nameExists {
+		return &errors.DBValidationError{Field: "name", Tag: "exists"}
+	}
+	return nil
+}
+
+// ValidateBulkCreateBranchOfficeRequest binds a JSON array of branch offices and the
+// mode query parameter. Only a malformed request is returned as an error; the problems
+// of single items are reported per index, nil for the valid ones. Ids and names are
+// checked against the other items of the batch and against the stored branch offices,
+// with one query each for the whole batch.
+func ValidateBulkCreateBranchOfficeRequest(ctx *gin.Context, branchOfficeService services.BranchOfficeServiceInterface) (*dto.BulkCreateBranchOfficeRequest, []*dto.CreateBranchOfficeValidationResponse, error) {
+	validate := validator.New()
+	var req dto.BulkCreateBranchOfficeRequest
+
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		return nil, nil, err
+	}
+	if err := ctx.ShouldBindJSON(&req.Items); err != nil {
+		return nil, nil, err
+	}
+	if err := validate.Struct(req); err != nil {
+		return nil, nil, err
+	}
+
+	itemErrors := make([]*dto.CreateBranchOfficeValidationResponse, len(req.Items))
+	fail := func(i int, field string, tag string) {
+		if itemErrors[i] == nil {
+			itemErrors[i] = &dto.CreateBranchOfficeValidationResponse{}
+		}
+		setCreateBranchOfficeValidationField(itemErrors[i], field, tag)
+	}
+
+	var ids, names []string
+	for i, item := range req.Items {
+		if err := validate.Struct(item); err != nil {
+			fieldErrors, ok := err.(validator.ValidationErrors)
+			if !ok {
+				return nil, nil, err
+			}
+			for _, fieldError := range fieldErrors {
+				// Errors of opening hours, exceptions and address parts are reported against
+				// the list or the address.
+				field := strings.TrimPrefix(fieldError.StructNamespace(), "CreateBranchOfficeRequest.")
+				field, _, _ = strings.Cut(field, "[")
+				field, _, _ = strings.Cut(field, ".")
+				fail(i, field, fieldError.Tag())
+			}
+		} else if err := validateCreateBranchOffice(item); err != nil {
+			var validationErr *errors.DBValidationError
+			if !stdErrors.As(err, &validationErr) {
+				return nil, nil, err
+			}
+			fail(i, createBranchOfficeFields[validationErr.Field], validationErr.Tag)
+		}
+		ids = append(ids, item.Id)
+		names = append(names, item.Name)
+	}
+
+	// Ids clash with trashed branch offices too, names only with live ones.
+	withTrash := true
+	existingIds, err := branchOfficeService.ExistsBranchOfficesByField(ctx, services.ExistsBranchOfficesByFieldInput{
+		Field:     "id",
+		Values:    ids,
+		WithTrash: &withTrash,
+	})
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return nil, nil, err
+	}
+	existingNames, err := branchOfficeService.ExistsBranchOfficesByField(ctx, services.ExistsBranchOfficesByFieldInput{
+		Field:  "name",
+		Values: names,
+	})
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return nil, nil, err
+	}
+
+	seenIds := map[string]bool{}
+	seenNames := map[string]bool{}
+	for i, item := range req.Items {
+		if item.Id != "" {
+			if existingIds[item.Id] {
+				fail(i, "Id", "exists")
+			} else if seenIds[item.Id] {
+				fail(i, "Id", "duplicate")
+			}
+			seenIds[item.Id] = true
+		}
+		if item.Name != "" {
+			if existingNames[item.Name] {
+				fail(i, "Name", "exists")
+			} else if seenNames[models.BranchOfficeNameKey(item.Name)] {
+				fail(i, "Name", "duplicate")
+			}
+			seenNames[models.BranchOfficeNameKey(item.Name)] = true
 		}
 	}
 
+	if req.Mode == nil {
+		mode := services.BulkModeAtomic
+		req.Mode = &mode
+	}
+	return &req, itemErrors, nil
+}
+
+// ValidateBulkBranchOfficeRequest binds the targets of a bulk soft delete, restore or
+// hard delete, which are either a list of ids or a filter that narrows the branch
+// offices down by at least one criterion. Only writes that honour the status of the
+// filter accept it, as withStatus tells: a soft delete only ever targets live branch
+// offices and a restore trashed ones, so status would narrow nothing down for them.
+func ValidateBulkBranchOfficeRequest(ctx *gin.Context, withStatus bool) (*dto.BulkBranchOfficeRequest, error) {
+	validate := validator.New()
+	var req dto.BulkBranchOfficeRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		return nil, err
+	}
+	if err := validate.Struct(req); err != nil {
+		return nil, err
+	}
+
+	if len(req.Ids) == 0 && req.Filter == nil {
+		return nil, &errors.DBValidationError{Field: "ids", Tag: "required_without"}
+	}
+	if len(req.Ids) > 0 && req.Filter != nil {
+		return nil, &errors.DBValidationError{Field: "filter", Tag: "excluded_with"}
+	}
+
+	if filter := req.Filter; filter != nil {
+		if filter.Status != nil && !withStatus {
+			return nil, &errors.DBValidationError{Field: "filter", Tag: "excluded"}
+		}
+		// Dropping these would widen the filter, up to every branch office. Whether anything
+		// is left to filter on is checked once the service has converted it.
+		if blankListItem(filter.City) || blankListItem(filter.Code) || blankListItem(filter.LifecycleStatus) || blankListItem(filter.Fields) {
+			return nil, &errors.DBValidationError{Field: "filter", Tag: "required"}
+		}
+		if filter.Fields != nil && !validListValues(filter.Fields, services.BranchOfficeKeywordFields) {
+			return nil, &errors.DBValidationError{Field: "filter", Tag: "oneof"}
+		}
+		if filter.Keyword != nil && *filter.Keyword != "" && (util.ClearInvalidKeyword(*filter.Keyword) == "" || filter.Fields != nil && len(*filter.Fields) == 0) {
+			return nil, &errors.DBValidationError{Field: "filter", Tag: "required"}
+		}
+		if filter.CreatedFrom != nil && filter.CreatedTo != nil && *filter.CreatedTo < *filter.CreatedFrom {
+			return nil, &errors.DBValidationError{Field: "filter", Tag: "gtefield"}
+		}
+		if !validListValues(filter.LifecycleStatus, models.BranchOfficeStatuses) {
+			return nil, &errors.DBValidationError{Field: "filter", Tag: "oneof"}
+		}
+	}
+	return &req, nil
+}
+
+// setCreateBranchOfficeValidationField records tag against the field of
+// dto.CreateBranchOfficeRequest named field.
+func setCreateBranchOfficeValidationField(res *dto.CreateBranchOfficeValidationResponse, field string, tag string) {
+	switch field {
+	case "Id":
+		res.Id = &tag
+	case "Name":
+		res.Name = &tag
+	case "Address":
+		res.Address = &tag
+	case "AddressDetail":
+		res.AddressDetail = &tag
+	case "PhoneNumber":
+		res.PhoneNumber = &tag
+	case "City":
+		res.City = &tag
+	case "CityCode":
+		res.CityCode = &tag
+	case "FaxNumber":
+		res.FaxNumber = &tag
+	case "Latitude":
+		res.Latitude = &tag
+	case "Longitude":
+		res.Longitude = &tag
+	case "ServiceArea":
+		res.ServiceArea = &tag
+	case "PostalCodes":
+		res.PostalCodes = &tag
+	case "LifecycleStatus":
+		res.LifecycleStatus = &tag
+	case "Timezone":
+		res.Timezone = &tag
+	case "OpeningHours":
+		res.OpeningHours = &tag
+	case "Exceptions":
+		res.Exceptions = &tag
+	}
+}
+
+// validClock tells whether clock is a time of day as HH:MM, with 24:00 allowed as the
+// end of the day when end is set.
+func validClock(clock string, end bool) bool {
+	if end && clock == "24:00" {
+		return true
+	}
+	_, err := time.Parse(models.BranchOfficeClockLayout, clock)
+	return err == nil && len(clock) == len(models.BranchOfficeClockLayout)
+}
+
+// validHoursIntervals checks that each interval opens before it closes and that none of
+// them overlap, and returns the tag of the first problem.
+func validHoursIntervals(intervals []models.BranchOfficeHoursInterval) (string, bool) {
+	for i, interval := range intervals {
+		if !validClock(interval.Opens, false) || !validClock(interval.Closes, true) {
+			return "datetime", false
+		}
+		if interval.Closes <= interval.Opens {
+			return "gtfield", false
+		}
+		// The intervals come sorted by opening time.
+		if i > 0 && intervals[i-1].Closes > interval.Opens {
+			return "overlap", false
+		}
+	}
+	return "", true
+}
+
+// validateBranchOfficeHours checks the clock times of opening hours and exceptions, that
+// the intervals of a day do not overlap and that no date has two exceptions.
+func validateBranchOfficeHours(openingHours []dto.BranchOfficeOpeningHours, exceptions []dto.BranchOfficeHoursException) error {
+	days := map[int][]models.BranchOfficeHoursInterval{}
+	for _, hours := range models.OpeningHoursFromDto(openingHours) {
+		days[hours.Weekday] = append(days[hours.Weekday], models.BranchOfficeHoursInterval{Opens: hours.Opens, Closes: hours.Closes})
+	}
+	for _, intervals := range days {
+		if tag, ok := validHoursIntervals(intervals); !ok {
+			return &errors.DBValidationError{Field: "opening_hours", Tag: tag}
+		}
+	}
+
+	dates := map[string]bool{}
+	for _, exception := range models.HoursExceptionsFromDto(exceptions) {
+		if dates[exception.Date] {
+			return &errors.DBValidationError{Field: "exceptions", Tag: "unique"}
+		}
+		dates[exception.Date] = true
+		if tag, ok := validHoursIntervals(exception.Intervals); !ok {
+			return &errors.DBValidationError{Field: "exceptions", Tag: tag}
+		}
+	}
+	return nil
+}
+
+// validateBranchOfficeAddress checks the postal code of a structured address against its
+// country, which struct tags cannot express.
+func validateBranchOfficeAddress(address *dto.BranchOfficeAddress) error {
+	if address != nil && !models.BranchOfficeAddressFromDto(*address).ValidPostalCode() {
+		return &errors.DBValidationError{Field: "address_detail", Tag: "postcode_iso3166_alpha2"}
+	}
+	return nil
+}
+
+// validateBranchOfficeCityCode checks that a city code, when given, is the code of a
+// regency or city of the regions package.
+func validateBranchOfficeCityCode(code string) error {
+	if code != "" && !regions.IsRegency(code) {
+		return &errors.DBValidationError{Field: "city_code", Tag: "region"}
+	}
+	return nil
+}
+
+// validateBranchOfficeAddressPatch checks the members of a structured address present in
+// a merge patch. The street may be replaced but not cleared; the others may be cleared,
+// the country falling back to models.DefaultBranchOfficeCountry. The postal code is
+// checked against the country once the patch is merged.
+func validateBranchOfficeAddressPatch(validate *validator.Validate, patch dto.PatchBranchOfficeAddress) error {
+	if patch.Null || patch.Street.Set && (patch.Street.Null || patch.Street.Value == "") {
+		return &errors.DBValidationError{Field: "address_detail", Tag: "required"}
+	}
+	members := []struct {
+		value dto.PatchString
+		tag   string
+	}{
+		{patch.Street, "max=255"},
+		{patch.Rt, "omitempty,numeric,max=3"},
+		{patch.Rw, "omitempty,numeric,max=3"},
+		{patch.Kelurahan, "max=100"},
+		{patch.Kecamatan, "max=100"},
+		{patch.Province, "max=100"},
+		{patch.PostalCode, "max=10"},
+		{patch.Country, "omitempty,iso3166_1_alpha2"},
+	}
+	for _, member := range members {
+		if !member.value.Set {
+			continue
+		}
+		if err := validate.Var(member.value.Value, member.tag); err != nil {
+			var fieldErrors validator.ValidationErrors
+			if stdErrors.As(err, &fieldErrors) {
+				return &errors.DBValidationError{Field: "address_detail", Tag: fieldErrors[0].Tag()}
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// validateBranchOfficeCoordinates checks that a latitude and a longitude come together,
+// as neither locates a branch office without the other.
+func validateBranchOfficeCoordinates(latitude *float64, longitude *float64) error {
+	if latitude != nil && longitude == nil {
+		return &errors.DBValidationError{Field: "longitude", Tag: "required_with"}
+	}
+	if latitude == nil && longitude != nil {
+		return &errors.DBValidationError{Field: "latitude", Tag: "required_with"}
+	}
+	return nil
+}
+
+// maxServiceAreaPositions bounds the size of a service area, as checking it for
+// crossing edges takes time quadratic in its number of positions.
+const maxServiceAreaPositions = 5000
+
+// validateBranchOfficeServiceArea checks that a service area is a valid area of bounded
+// size, as geo.MultiPolygon.Validate describes, and that no postal code is listed twice.
+func validateBranchOfficeServiceArea(area geo.MultiPolygon, postalCodes []string) error {
+	if area.NumPositions() > maxServiceAreaPositions {
+		return &errors.DBValidationError{Field: "service_area", Tag: "max"}
+	}
+	var tag string
+	switch err := area.Validate(); {
+	case err == nil:
+	case stdErrors.Is(err, geo.ErrPositionOutOfRange):
+		tag = "range"
+	case stdErrors.Is(err, geo.ErrRingTooShort):
+		tag = "min"
+	case stdErrors.Is(err, geo.ErrRingNotClosed):
+		tag = "closed"
+	case stdErrors.Is(err, geo.ErrSelfIntersection):
+		tag = "self_intersection"
+	case stdErrors.Is(err, geo.ErrHoleOutside):
+		tag = "within"
+	case stdErrors.Is(err, geo.ErrPolygonsOverlap):
+		tag = "overlap"
+	default:
+		return err
+	}
+	if tag != "" {
+		return &errors.DBValidationError{Field: "service_area", Tag: tag}
+	}
+
+	seen := map[string]bool{}
+	for _, code := range postalCodes {
+		if seen[models.PostalCodeKey(code)] {
+			return &errors.DBValidationError{Field: "postal_codes", Tag: "unique"}
+		}
+		seen[models.PostalCodeKey(code)] = true
+	}
+	return nil
+}
+
+// MergePatchContentType is the media type of a JSON merge patch (RFC 7396).
+const MergePatchContentType = "application/merge-patch+json"
+
+// ValidatePatchBranchOfficeRequest binds a JSON merge patch of a branch office, which
+// the controller only passes on when sent as MergePatchContentType. Only the fax number, the city code and the
+// optional parts of address_detail may be cleared with null; the other fields are
+// required, so a patch can replace them but not clear them.
+func ValidatePatchBranchOfficeRequest(ctx *gin.Context, branchOfficeService services.BranchOfficeServiceInterface, id string) (*dto.PatchBranchOfficeRequest, error) {
+	var req dto.PatchBranchOfficeRequest
+	decoder := json.NewDecoder(ctx.Request.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		return nil, err
+	}
+
+	required := []struct {
+		field string
+		value dto.PatchString
+	}{
+		{"name", req.Name},
+		{"address", req.Address},
+		{"phone_number", req.PhoneNumber},
+		{"city", req.City},
+	}
+	for _, member := range required {
+		if member.value.Set && (member.value.Null || member.value.Value == "") {
+			return nil, &errors.DBValidationError{Field: member.field, Tag: "required"}
+		}
+	}
+	if req.Address.Set && req.AddressDetail.Set {
+		return nil, &errors.DBValidationError{Field: "address_detail", Tag: "excluded_with"}
+	}
+	if req.AddressDetail.Set {
+		if err := validateBranchOfficeAddressPatch(validator.New(), req.AddressDetail); err != nil {
+			return nil, err
+		}
+	}
+	if err := validateBranchOfficeCityCode(req.CityCode.Value); err != nil {
+		return nil, err
+	}
+
+	if req.Name.Set {
+		if err := validateBranchOfficeNameAvailable(ctx, branchOfficeService, req.Name.Value, &id); err != nil {
+			return nil, err
+		}
+	}
 	return &req, nil
 }
 
@@ -75,7 +631,232 @@ func ValidateUpdateBranchOfficeRequest(ctx *gin.Context, branchOfficeService ser
 	if err := validate.Struct(req); err != nil {
 		return nil, err
 	}
+	if err := validateBranchOfficeAddress(req.AddressDetail); err != nil {
+		return nil, err
+	}
+	if err := validateBranchOfficeCityCode(req.CityCode); err != nil {
+		return nil, err
+	}
+
+	if err := validateBranchOfficeNameAvailable(ctx, branchOfficeService, req.Name, &id); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// ValidateRestoreBranchOfficeRequest binds the body of a restore, which may be left out.
+// Whether the name is free is checked by the restore itself, as it runs.
+func ValidateRestoreBranchOfficeRequest(ctx *gin.Context) (*dto.RestoreBranchOfficeRequest, error) {
+	var req dto.RestoreBranchOfficeRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil && !stdErrors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil && *req.Name == "" {
+		return nil, &errors.DBValidationError{Field: "name", Tag: "required"}
+	}
+	return &req, nil
+}
+
+// ValidateTransitionBranchOfficeRequest binds a lifecycle transition. Suspending or
+// closing a branch office needs a reason, and a transition cannot take effect in the
+// future. Whether the branch office may make the transition is checked by the service.
+func ValidateTransitionBranchOfficeRequest(ctx *gin.Context) (*dto.TransitionBranchOfficeRequest, error) {
+	var req dto.TransitionBranchOfficeRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		return nil, err
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		return nil, err
+	}
+
+	if (req.Status == models.BranchOfficeStatusSuspended || req.Status == models.BranchOfficeStatusClosed) && strings.TrimSpace(req.Reason) == "" {
+		return nil, &errors.DBValidationError{Field: "reason", Tag: "required_if"}
+	}
+	if req.EffectiveAt != nil && *req.EffectiveAt > time.Now().Unix() {
+		return nil, &errors.DBValidationError{Field: "effective_at", Tag: "lte"}
+	}
+	return &req, nil
+}
+
+// ValidateUpdateBranchOfficeHoursRequest binds the timezone, opening hours and
+// exceptions that replace those of a branch office.
+func ValidateUpdateBranchOfficeHoursRequest(ctx *gin.Context) (*dto.UpdateBranchOfficeHoursRequest, error) {
+	var req dto.UpdateBranchOfficeHoursRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		return nil, err
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		return nil, err
+	}
+	if err := validateBranchOfficeHours(req.OpeningHours, req.Exceptions); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// ValidateGetBranchOfficeAvailabilityRequest binds the time to check the availability
+// at, which defaults to now.
+func ValidateGetBranchOfficeAvailabilityRequest(ctx *gin.Context) (*dto.GetBranchOfficeAvailabilityRequest, error) {
+	validate := validator.New()
+	var req dto.GetBranchOfficeAvailabilityRequest
+
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		return nil, err
+	}
+	if err := validate.Struct(req); err != nil {
+		return nil, err
+	}
+
+	if req.At == nil {
+		at := time.Now().Unix()
+		req.At = &at
+	}
+	return &req, nil
+}
+
+// ValidateUpdateBranchOfficeLocationRequest binds the coordinates of a branch office,
+// which are set together or cleared together.
+func ValidateUpdateBranchOfficeLocationRequest(ctx *gin.Context) (*dto.UpdateBranchOfficeLocationRequest, error) {
+	var req dto.UpdateBranchOfficeLocationRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		return nil, err
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		return nil, err
+	}
+	if err := validateBranchOfficeCoordinates(req.Latitude, req.Longitude); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// ValidateUpdateBranchOfficeServiceAreaRequest binds the service area and postal codes
+// of a branch office.
+func ValidateUpdateBranchOfficeServiceAreaRequest(ctx *gin.Context) (*dto.UpdateBranchOfficeServiceAreaRequest, error) {
+	var req dto.UpdateBranchOfficeServiceAreaRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		return nil, err
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		return nil, err
+	}
+	if err := validateBranchOfficeServiceArea(req.ServiceArea, req.PostalCodes); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// ValidateResolveBranchOfficeRequest binds either a point, as lat and lng, or a postal
+// code to resolve to a branch office.
+func ValidateResolveBranchOfficeRequest(ctx *gin.Context) (*dto.ResolveBranchOfficeRequest, error) {
+	validate := validator.New()
+	var req dto.ResolveBranchOfficeRequest
+
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		return nil, err
+	}
+	if err := validate.Struct(req); err != nil {
+		return nil, err
+	}
+
+	if req.PostalCode != nil {
+		if req.Lat != nil || req.Lng != nil {
+			return nil, &errors.DBValidationError{Field: "postal_code", Tag: "excluded_with"}
+		}
+		return &req, nil
+	}
+	switch {
+	case req.Lat == nil && req.Lng == nil:
+		return nil, &errors.DBValidationError{Field: "lat", Tag: "required_without"}
+	case req.Lat == nil:
+		return nil, &errors.DBValidationError{Field: "lat", Tag: "required_with"}
+	case req.Lng == nil:
+		return nil, &errors.DBValidationError{Field: "lng", Tag: "required_with"}
+	}
+	return &req, nil
+}
+
+// ValidateGetNearbyBranchOfficeRequest binds the point and radius of a nearby search,
+// returning constant.PaginationLimit branch offices unless told otherwise.
+func ValidateGetNearbyBranchOfficeRequest(ctx *gin.Context) (*dto.GetNearbyBranchOfficeRequest, error) {
+	validate := validator.New()
+	var req dto.GetNearbyBranchOfficeRequest
+
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		return nil, err
+	}
+	if err := validate.Struct(req); err != nil {
+		return nil, err
+	}
+	if !validListValues(req.LifecycleStatus, models.BranchOfficeStatuses) {
+		return nil, &errors.DBValidationError{Field: "lifecycle_status", Tag: "oneof"}
+	}
+
+	if req.Limit == nil {
+		defaultLimit := constant.PaginationLimit
+		req.Limit = &defaultLimit
+	}
+	return &req, nil
+}
+
+func ValidateMigrateBranchOfficeCityCodesRequest(ctx *gin.Context) (*dto.MigrateBranchOfficeCityCodesRequest, error) {
+	validate := validator.New()
+	var req dto.MigrateBranchOfficeCityCodesRequest
 
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		return nil, err
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+func ValidateMigrateBranchOfficeCodesRequest(ctx *gin.Context) (*dto.MigrateBranchOfficeCodesRequest, error) {
+	validate := validator.New()
+	var req dto.MigrateBranchOfficeCodesRequest
+
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		return nil, err
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+func ValidateMigrateBranchOfficeAddressesRequest(ctx *gin.Context) (*dto.MigrateBranchOfficeAddressesRequest, error) {
+	validate := validator.New()
+	var req dto.MigrateBranchOfficeAddressesRequest
+
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		return nil, err
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return nil, err
+	}
 	return &req, nil
 }
 
@@ -92,3 +873,25 @@ func ValidateGetSimpleBranchOfficeRequest(ctx *gin.Context) (*dto.GetSimpleBranc
 	}
 	return &req, nil
 }
+
+func ValidateGetBranchOfficeHistoryRequest(ctx *gin.Context) (*dto.GetBranchOfficeHistoryRequest, error) {
+	validate := validator.New()
+	var req dto.GetBranchOfficeHistoryRequest
+
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		return nil, err
+	}
+	if err := validate.Struct(req); err != nil {
+		return nil, err
+	}
+
+	if req.Page == nil {
+		defaultPage := constant.PaginationPage
+		req.Page = &defaultPage
+	}
+	if req.Limit == nil {
+		defaultLimit := constant.PaginationLimit
+		req.Limit = &defaultLimit
+	}
+	return &req, nil
+}
@@ -0,0 +1,218 @@
+package validators
+
+import (
+	"context"
+	stdErrors "errors"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/jangkartech/twin-branch-office/pkg/dto"
+	"github.com/jangkartech/twin-branch-office/pkg/imports"
+	"github.com/jangkartech/twin-branch-office/pkg/models"
+	"github.com/jangkartech/twin-branch-office/pkg/services"
+	"github.com/jangkartech/twin-util/pkg/errors"
+	"github.com/jangkartech/twin-util/pkg/logger"
+)
+
+// branchOfficeImportColumns maps the fields of the create and update requests to the
+// import columns they are read from.
+var branchOfficeImportColumns = map[string]string{
+	"Id":          "id",
+	"Name":        "name",
+	"Address":     "address",
+	"PhoneNumber": "phone_number",
+	"FaxNumber":   "fax_number",
+	"City":        "city",
+}
+
+// ValidateImportBranchOfficeRequest binds an uploaded CSV or XLSX file and validates its
+// rows. A row updates the live branch office with its id or, without an id, with its
+// name, and creates a branch office otherwise. Each row is validated like the matching
+// create or update request, names must stay unique, and no branch office may be the
+// target of two rows. Only problems with the upload as a whole are returned as an
+// error, among them an upload larger than imports.MaxBranchOfficeFileSize or a file of
+// more than imports.MaxBranchOfficeRows rows; the rest are reported per cell.
+func ValidateImportBranchOfficeRequest(ctx *gin.Context, branchOfficeService services.BranchOfficeServiceInterface) (*dto.ImportBranchOfficeRequest, []services.ImportBranchOfficeItem, []*dto.ImportBranchOfficeError, error) {
+	validate := validator.New()
+	var req dto.ImportBranchOfficeRequest
+
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		return nil, nil, nil, err
+	}
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, imports.MaxBranchOfficeFileSize)
+	if err := ctx.ShouldBind(&req); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if stdErrors.As(err, &maxBytesErr) {
+			return nil, nil, nil, &errors.DBValidationError{Field: "file", Tag: "max"}
+		}
+		return nil, nil, nil, err
+	}
+	if err := validate.Struct(req); err != nil {
+		return nil, nil, nil, err
+	}
+
+	format := strings.TrimPrefix(strings.ToLower(filepath.Ext(req.File.Filename)), ".")
+	if format != imports.FormatCSV && format != imports.FormatXLSX {
+		return nil, nil, nil, &errors.DBValidationError{Field: "file", Tag: "extension"}
+	}
+	file, err := req.File.Open()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer file.Close()
+
+	rows, err := imports.ReadBranchOfficeRows(file, format)
+	switch {
+	case stdErrors.Is(err, imports.ErrUnknownColumn), stdErrors.Is(err, imports.ErrDuplicateColumn), stdErrors.Is(err, imports.ErrMissingHeader):
+		return nil, nil, nil, &errors.DBValidationError{Field: "file", Tag: "header"}
+	case stdErrors.Is(err, imports.ErrTooManyRows):
+		return nil, nil, nil, &errors.DBValidationError{Field: "file", Tag: "max"}
+	case err != nil:
+		return nil, nil, nil, &errors.DBValidationError{Field: "file", Tag: "format"}
+	}
+	if len(rows) == 0 {
+		return nil, nil, nil, &errors.DBValidationError{Field: "file", Tag: "required"}
+	}
+
+	items, rowErrors, err := validateImportBranchOfficeRows(ctx, rows, branchOfficeService)
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return nil, nil, nil, err
+	}
+	return &req, items, rowErrors, nil
+}
+
+func validateImportBranchOfficeRows(ctx context.Context, rows []imports.BranchOfficeRow, branchOfficeService services.BranchOfficeServiceInterface) ([]services.ImportBranchOfficeItem, []*dto.ImportBranchOfficeError, error) {
+	validate := validator.New()
+
+	var ids, names []string
+	for _, row := range rows {
+		if id := row.Values["id"]; id != "" {
+			ids = append(ids, id)
+		}
+		if name := row.Values["name"]; name != "" {
+			names = append(names, name)
+		}
+	}
+
+	withTrash := true
+	storedIds, err := branchOfficeService.ExistsBranchOfficesByField(ctx, services.ExistsBranchOfficesByFieldInput{
+		Field:     "id",
+		Values:    ids,
+		WithTrash: &withTrash,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	liveIds, err := branchOfficeService.ExistsBranchOfficesByField(ctx, services.ExistsBranchOfficesByFieldInput{
+		Field:  "id",
+		Values: ids,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	nameIds, err := branchOfficeService.GetBranchOfficeIdsByField(ctx, services.ExistsBranchOfficesByFieldInput{
+		Field:  "name",
+		Values: names,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var items []services.ImportBranchOfficeItem
+	var rowErrors []*dto.ImportBranchOfficeError
+	seenIds := map[string]bool{}
+	seenNames := map[string]bool{}
+	for _, row := range rows {
+		var cellErrors []*dto.ImportBranchOfficeError
+		fail := func(column string, reason string) {
+			cellErrors = append(cellErrors, &dto.ImportBranchOfficeError{Row: row.Line, Column: column, Reason: reason})
+		}
+		failFields := func(err error) {
+			fieldErrors, ok := err.(validator.ValidationErrors)
+			if !ok {
+				fail("", err.Error())
+				return
+			}
+			for _, fieldError := range fieldErrors {
+				fail(branchOfficeImportColumns[fieldError.StructField()], fieldError.Tag())
+			}
+		}
+
+		id, name := row.Values["id"], row.Values["name"]
+		item := services.ImportBranchOfficeItem{Line: row.Line}
+		switch {
+		case id != "" && liveIds[id]:
+			item.Id = id
+		case id != "" && storedIds[id]:
+			// A soft-deleted branch office keeps its id until it is restored.
+			fail("id", "deleted")
+		case id == "" && nameIds[name] != "":
+			item.Id = nameIds[name]
+		}
+
+		if item.Id != "" {
+			req := dto.PatchBranchOfficeRequest{
+				Name:        importValue(row, "name"),
+				Address:     importValue(row, "address"),
+				PhoneNumber: importValue(row, "phone_number"),
+				FaxNumber:   importValue(row, "fax_number"),
+				City:        importValue(row, "city"),
+			}
+			if seenIds[item.Id] {
+				if id != "" {
+					fail("id", "duplicate")
+				} else {
+					fail("name", "duplicate")
+				}
+			}
+			seenIds[item.Id] = true
+			item.Update = &req
+		} else if len(cellErrors) == 0 {
+			req := dto.CreateBranchOfficeRequest{
+				Id:          id,
+				Name:        name,
+				Address:     row.Values["address"],
+				PhoneNumber: row.Values["phone_number"],
+				FaxNumber:   row.Values["fax_number"],
+				City:        row.Values["city"],
+			}
+			if err := validate.Struct(req); err != nil {
+				failFields(err)
+			}
+			if id != "" {
+				if seenIds[id] {
+					fail("id", "duplicate")
+				}
+				seenIds[id] = true
+			}
+			item.Create = &req
+		}
+
+		if name != "" {
+			if holder := nameIds[name]; holder != "" && holder != item.Id {
+				fail("name", "exists")
+			} else if seenNames[models.BranchOfficeNameKey(name)] {
+				fail("name", "duplicate")
+			}
+			seenNames[models.BranchOfficeNameKey(name)] = true
+		}
+
+		if len(cellErrors) > 0 {
+			rowErrors = append(rowErrors, cellErrors...)
+			continue
+		}
+		items = append(items, item)
+	}
+	return items, rowErrors, nil
+}
+
+// importValue returns the cell of row in column as a patch member, absent when the cell
+// is empty, which leaves the field of an updated branch office as it is.
+func importValue(row imports.BranchOfficeRow, column string) dto.PatchString {
+	value, ok := row.Values[column]
+	return dto.PatchString{Set: ok, Value: value}
+}
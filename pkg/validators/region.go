@@ -0,0 +1,21 @@
+package validators
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/jangkartech/twin-branch-office/pkg/dto"
+)
+
+func ValidateGetRegionRequest(ctx *gin.Context) (*dto.GetRegionRequest, error) {
+	validate := validator.New()
+	var req dto.GetRegionRequest
+
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		return nil, err
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}